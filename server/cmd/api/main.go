@@ -53,15 +53,22 @@ func main() {
 	}
 	log.Info().Msg("Migrations completed successfully")
 
+	// Chunk sizing is tunable per deployment; only affects chunks created from now on
+	if err := db.SetChunkInterval(ctx, cfg.HypertableChunkInterval); err != nil {
+		log.Error().Err(err).Msg("Failed to apply hypertable chunk interval")
+	}
+
 	// Setup router
 	r := chi.NewRouter()
 
 	// Middleware
 	r.Use(middleware.RequestID)
 	r.Use(middleware.RealIP)
+	// Browsers pass the JWT as ?token= on /ws since they can't set headers;
+	// lift it into Authorization before the logger can record the URL
+	r.Use(handlers.QueryTokenMiddleware("/api/v1/ws"))
 	r.Use(middleware.Logger)
 	r.Use(middleware.Recoverer)
-	r.Use(middleware.Timeout(30 * time.Second))
 
 	// CORS
 	r.Use(func(next http.Handler) http.Handler {
@@ -90,99 +97,186 @@ func main() {
 	settingsService := services.NewSettingsService(db.Pool)
 	seedSettings(ctx, settingsService, cfg)
 
-	alertService := services.NewAlertService(db.Pool, settingsService, cfg.AlertCooldown, cfg.PriceThreshold, cfg.DiscordBotToken)
+	emailSender := services.NewEmailSender(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUser, cfg.SMTPPass, cfg.SMTPFrom)
+	alertService := services.NewAlertService(db.Pool, settingsService, cfg.AlertCooldown, cfg.AlertDedupWindow, cfg.PriceThreshold, cfg.RecordLookback, cfg.DiscordBotToken, emailSender)
 
 	// Initialize Torn API Client for Inventory Fetch
-	client := tornapi.NewClient(cfg.TornAPIKeys, cfg.RedisURL)
+	apiLimiter, err := tornapi.NewLimiterWithFallback(cfg.RedisURL, 100, tornapi.DefaultWindow, "torn_api:rate_limit", cfg.RequireRedis) // Default 100 req/min
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to create Torn API RateLimiter")
+	}
+	defer apiLimiter.Close()
+	client := tornapi.NewClient(cfg.TornAPIKeys, apiLimiter, cfg.TornAPITimeout)
+	client.SetKeySource(keyManager) // Fall back to user-contributed keys when system keys are missing or limited
 
 	// Initialize Rate Limiter for Poller
 	// Base limit is usually 100/min per key public, but we set safe defaults in config
-	limiter, err := tornapi.NewRateLimiter(cfg.RedisURL, cfg.BazaarRateLimit, "torn_api:rate_limit")
+	limiter, err := tornapi.NewLimiterWithFallback(cfg.RedisURL, cfg.BazaarRateLimit, tornapi.DefaultWindow, "torn_api:rate_limit", cfg.RequireRedis)
 	if err != nil {
-		log.Warn().Err(err).Msg("Failed to initialize RateLimiter, proceeding without it (unsafe for high load)")
+		log.Fatal().Err(err).Msg("Failed to initialize RateLimiter")
 	}
 
+	// Shared by every price insert path so they agree on the last stored point
+	priceThrottle := services.NewPriceThrottle(cfg.MinPriceChangePct, cfg.PriceMaxGap)
+
 	// Initialize and Start Workers
 	globalSync := workers.NewGlobalSync(db.Pool, client, cfg)
 	go globalSync.Start(ctx)
 
-	bazaarPoller := workers.NewBazaarPoller(db.Pool, cfg, alertService, limiter)
+	bazaarPoller := workers.NewBazaarPoller(db.Pool, cfg, alertService, priceThrottle, limiter)
 	go bazaarPoller.Start(ctx)
 
-	crawler := workers.NewBackgroundCrawler(db.Pool, client, keyManager, cfg)
+	crawler := workers.NewBackgroundCrawler(db.Pool, client, keyManager, priceThrottle, cfg)
 	go crawler.Start(ctx)
 
-	wsService := services.NewTornWebSocketService(cfg, db.Pool, alertService)
+	// Live price updates from the Torn WebSocket are pushed to /api/v1/ws clients
+	priceHub := services.NewPriceHub()
+	wsService := services.NewTornWebSocketService(cfg, db.Pool, alertService, settingsService, priceThrottle, priceHub)
 	go wsService.Start(ctx)
 
 	// Initialize handlers
-	priceHandler := handlers.NewPriceHandler(db)
+	// Bounded queue for fire-and-forget writes from request handlers
+	writeQueue := services.NewWriteQueue(256, 5*time.Second)
+	writeQueue.Start(4)
+
+	externalPrices := services.NewExternalPriceClient(cfg.TornExchangeCacheTTL, cfg.TornExchangeInterval, cfg.ExternalAPITimeout)
+	priceHandler := handlers.NewPriceHandler(db, settingsService, writeQueue, externalPrices)
+	globalSync.OnSync(priceHandler.InvalidateTrackedItems)
+	refreshHandler := handlers.NewRefreshHandler(db, client, keyManager, externalPrices, limiter, priceThrottle, priceHandler.InvalidateTrackedItems)
 	webhookHandler := handlers.NewWebhookHandler(db)
 	settingsHandler := handlers.NewSettingsHandler(settingsService)
-	keyHandler := handlers.NewKeyHandler(keyManager, client)
+	keyHandler := handlers.NewKeyHandler(keyManager, client, db, externalPrices, cfg.InventoryExternalConcurrency, cfg.InventoryExternalMax)
 	authHandler := handlers.NewAuthHandler(db, cfg)
-	botInternalHandler := handlers.NewBotInternalHandler(db)
-
-	// API routes
-	r.Route("/api/v1", func(r chi.Router) {
-		// Public Routes
-		r.With(handlers.OptionalAuthMiddleware).Post("/auth/login", authHandler.Login)
-		r.Get("/auth/discord/login", authHandler.DiscordOAuthLogin)
-		r.Get("/auth/discord/callback", authHandler.DiscordOAuthCallback)
-
-		// Items (Public Read)
-		r.With(handlers.OptionalAuthMiddleware).Get("/items", priceHandler.ListTracked)
-		r.With(handlers.OptionalAuthMiddleware).Get("/items/search", priceHandler.SearchItems)
-		r.Get("/items/{id}/history", priceHandler.GetItemHistory)
-		r.With(handlers.OptionalAuthMiddleware).Get("/items/{id}/latest", priceHandler.GetLatest)
-		r.Get("/items/{id}/external-prices", priceHandler.GetExternalPrices)
-		r.Get("/items/{id}/listings", priceHandler.GetTopListings)
-		r.Get("/market/summary", priceHandler.GetMarketSummary)
-
-		// Internal Bot Routes (Could be secured by an API key or internal network only)
-		// For now, these are internal API endpoints intended to be called by the bot container
-		r.Route("/bot", func(r chi.Router) {
-			r.Get("/alerts/{discord_id}", botInternalHandler.GetUserAlerts)
-			r.Post("/alerts/{discord_id}", botInternalHandler.AddOrUpdateAlert)
-			r.Delete("/alerts/{discord_id}/items/{item_id}", botInternalHandler.DeleteAlert)
-		})
-
-		// Protected Routes
-		r.Group(func(r chi.Router) {
-			r.Use(handlers.AuthMiddleware)
-
-			// Auth
-			r.Get("/auth/me", authHandler.GetMe)
-
-			// User Watchlist & Alerts
-			r.Get("/items/watched", priceHandler.ListWatched) // Now returns user-specific list
-			r.Post("/items/{id}/watch", priceHandler.ToggleWatchlist)
-			r.Put("/items/{id}/alerts", priceHandler.UpdateAlertSettings)
+	botInternalHandler := handlers.NewBotInternalHandler(db, settingsService)
+	debugHandler := handlers.NewDebugHandler(bazaarPoller)
+	liveHandler := handlers.NewLiveHandler(priceHub)
+	rateLimitHandler := handlers.NewRateLimitHandler(map[string]tornapi.Limiter{
+		"torn_api": apiLimiter,
+		"poller":   limiter,
+	})
+	alertHandler := handlers.NewAlertHandler(db)
+	statsHandler := handlers.NewStatsHandler(db, globalSync, wsService)
 
-			// User Inventory
-			r.Get("/user/inventory", keyHandler.GetInventory)
+	// Admin routes are limited to ADMIN_USER_IDS
+	adminOnly := handlers.AdminMiddleware(cfg.AdminUserIDs)
+	if len(cfg.AdminUserIDs) == 0 {
+		log.Warn().Msg("ADMIN_USER_IDS not set, admin routes are disabled")
+	}
 
-			// User Settings
-			r.Get("/user/settings", settingsHandler.GetUserSettings)
-			r.Put("/user/settings", settingsHandler.UpdateUserSetting)
+	// Live price push; long-lived, so it sits outside the request timeout
+	r.With(handlers.AuthMiddleware).Get("/api/v1/ws", liveHandler.ServeWS)
+
+	r.Group(func(r chi.Router) {
+		r.Use(middleware.Timeout(30 * time.Second))
+
+		// API routes
+		r.Route("/api/v1", func(r chi.Router) {
+			// Public Routes
+			r.With(handlers.OptionalAuthMiddleware).Post("/auth/login", authHandler.Login)
+			r.Get("/auth/discord/login", authHandler.DiscordOAuthLogin)
+			r.Get("/auth/discord/callback", authHandler.DiscordOAuthCallback)
+
+			// Items (Public Read)
+			r.With(handlers.OptionalAuthMiddleware).Get("/items", priceHandler.ListTracked)
+			r.With(handlers.OptionalAuthMiddleware).Get("/items/search", priceHandler.SearchItems)
+			r.Get("/items/arbitrage", priceHandler.GetArbitrage)
+			r.With(handlers.OptionalAuthMiddleware).Get("/items/{id}/history", priceHandler.GetHistory)
+			r.With(handlers.OptionalAuthMiddleware).Get("/items/{id}/history/combined", priceHandler.GetCombinedHistory)
+			r.With(handlers.OptionalAuthMiddleware).Get("/items/{id}/latest", priceHandler.GetLatest)
+			r.With(handlers.OptionalAuthMiddleware).Post("/items/latest", priceHandler.GetLatestBulk)
+			r.Get("/items/{id}/external-prices", priceHandler.GetExternalPrices)
+			r.Get("/items/{id}/external-prices/history", priceHandler.GetExternalPriceHistory)
+			r.Post("/items/external-prices", priceHandler.GetExternalPricesBulk)
+			r.Post("/items/metadata", priceHandler.GetItemsMetadata)
+			r.Get("/items/{id}/listings", priceHandler.GetTopListings)
+			r.Get("/items/{id}/buy-cost", priceHandler.GetBuyCost)
+			r.Get("/items/{id}/price-tiers", priceHandler.GetPriceTiers)
+			r.Get("/items/{id}/sales", priceHandler.GetRecentSales)
+			r.Get("/items/{id}/value", priceHandler.GetItemValue)
+			r.Get("/items/{id}/stats", priceHandler.GetItemStats)
+			r.Get("/market/summary", priceHandler.GetMarketSummary)
+			r.Get("/stats", statsHandler.GetStats)
+			r.Get("/meta", handlers.GetMeta)
+
+			// Internal Bot Routes, called by the bot container on behalf of Discord users
+			r.Route("/bot", func(r chi.Router) {
+				// Every bot route acts as or looks up a user: require the shared bot secret
+				r.Use(handlers.BotSecretMiddleware)
+				r.Get("/alerts/{discord_id}", botInternalHandler.GetUserAlerts)
+				r.Post("/alerts/{discord_id}", botInternalHandler.AddOrUpdateAlert)
+				r.Delete("/alerts/{discord_id}/items/{item_id}", botInternalHandler.DeleteAlert)
+				r.Post("/settings/{discord_id}/webhook", botInternalHandler.SetWebhook)
+				r.Get("/settings/{discord_id}/timezone", botInternalHandler.GetTimezone)
+				r.Get("/guilds/{guild_id}/commands", botInternalHandler.GetGuildCommands)
+				r.Put("/guilds/{guild_id}/commands/{command}", botInternalHandler.SetGuildCommand)
+			})
 
-			// Settings (Admin/System - could be further restricted later)
-			r.Route("/settings", func(r chi.Router) {
-				r.Get("/", settingsHandler.GetSettings)
-				r.Put("/", settingsHandler.UpdateSetting)
+			// Protected Routes
+			r.Group(func(r chi.Router) {
+				r.Use(handlers.AuthMiddleware)
+
+				// Auth
+				r.Get("/auth/me", authHandler.GetMe)
+				r.Get("/auth/me/export", authHandler.ExportMe)
+
+				// User Watchlist & Alerts
+				r.Get("/items/watched", priceHandler.ListWatched) // Now returns user-specific list
+				r.Post("/items/{id}/watch", priceHandler.ToggleWatchlist)
+				r.Get("/items/{id}/alerts", priceHandler.GetAlertSettings)
+				r.Put("/items/{id}/alerts", priceHandler.UpdateAlertSettings)
+				r.Post("/items/{id}/alerts/backtest", priceHandler.BacktestAlert)
+				r.Put("/items/{id}/note", priceHandler.UpdateItemNote)
+				r.Post("/items/{id}/refresh", refreshHandler.RefreshItem)
+
+				// Pinned items (quick access, independent of watchlist/alerts)
+				r.Get("/items/pinned", priceHandler.ListPinned)
+				r.Post("/items/{id}/pin", priceHandler.TogglePin)
+
+				// User Inventory
+				r.Get("/user/inventory", keyHandler.GetInventory)
+
+				// User Settings
+				r.Get("/user/settings", settingsHandler.GetUserSettings)
+				r.Put("/user/settings", settingsHandler.UpdateUserSetting)
+
+				// Alert history inbox
+				r.Get("/user/alerts/unread/count", alertHandler.GetUnreadCount)
+				r.Post("/user/alerts/seen", alertHandler.MarkAllSeen)
+
+				r.Route("/settings", func(r chi.Router) {
+					// System settings hold the Torn API keys the workers reload
+					r.With(adminOnly).Get("/", settingsHandler.GetSettings)
+					r.With(adminOnly).Put("/", settingsHandler.UpdateSetting)
+
+					// Key Management
+					r.Route("/keys", func(r chi.Router) {
+						r.Get("/", keyHandler.ListKeys)
+						r.Post("/", keyHandler.RegisterKey)
+						r.Delete("/{id}", keyHandler.DeleteKey)
+					})
+				})
 
-				// Key Management
-				r.Route("/keys", func(r chi.Router) {
-					r.Get("/", keyHandler.ListKeys)
-					r.Post("/", keyHandler.RegisterKey)
-					r.Delete("/{id}", keyHandler.DeleteKey)
+				// Maintenance (Admin)
+				r.Route("/admin", func(r chi.Router) {
+					r.Use(adminOnly)
+					// Scans the full price hypertables
+					r.Post("/items/recompute-cache", priceHandler.RecomputeItemCache)
+					// Shared Torn limiter state; resetting it can push keys past Torn's limits
+					r.Get("/rate-limits", rateLimitHandler.GetRateLimits)
+					r.Post("/rate-limits/{name}/reset", rateLimitHandler.ResetRateLimit)
+					// Every user's key health
+					r.Get("/keys/usage", keyHandler.GetKeyUsage)
+					// Item coverage applies to every user
+					r.Put("/items/{id}/tracked", priceHandler.SetItemTracked)
+					// Worker diagnostics
+					r.Get("/debug/bazaar-poller", debugHandler.GetBazaarPollerState)
 				})
 			})
 		})
-	})
 
-	// Webhook endpoint (separate from versioned API)
-	r.Post("/api/webhook/update", webhookHandler.HandleUpdate)
+		// Webhook endpoint (separate from versioned API)
+		r.Post("/api/webhook/update", webhookHandler.HandleUpdate)
+	})
 
 	// Start server
 	server := &http.Server{
@@ -214,6 +308,16 @@ func main() {
 		log.Fatal().Err(err).Msg("Server error")
 	}
 
+	// Let pending alert notifications and queued writes finish
+	drainCtx, drainCancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer drainCancel()
+	if err := alertService.Shutdown(drainCtx); err != nil {
+		log.Warn().Err(err).Msg("Timed out waiting for alert deliveries")
+	}
+	if err := writeQueue.Close(drainCtx); err != nil {
+		log.Warn().Err(err).Msg("Timed out draining background write queue")
+	}
+
 	log.Info().Msg("Server stopped")
 }
 
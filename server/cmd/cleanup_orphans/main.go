@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"os"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+
+	"github.com/akagifreeez/torn-market-chart/internal/config"
+	"github.com/akagifreeez/torn-market-chart/pkg/database"
+)
+
+// orphanCheck finds rows in table whose item or user no longer exists
+type orphanCheck struct {
+	table string
+	where string
+}
+
+// alert_states.user_id has no foreign key, and the user_alerts/user_watchlists
+// references are nullable, so all three can drift from items/users.
+var orphanChecks = []orphanCheck{
+	{
+		table: "alert_states",
+		where: `NOT EXISTS (SELECT 1 FROM items i WHERE i.id = t.item_id)
+			OR NOT EXISTS (SELECT 1 FROM users u WHERE u.id = t.user_id)`,
+	},
+	{
+		table: "user_alerts",
+		where: `t.item_id IS NULL OR t.user_id IS NULL
+			OR NOT EXISTS (SELECT 1 FROM items i WHERE i.id = t.item_id)
+			OR NOT EXISTS (SELECT 1 FROM users u WHERE u.id = t.user_id)`,
+	},
+	{
+		table: "user_watchlists",
+		where: `t.item_id IS NULL OR t.user_id IS NULL
+			OR NOT EXISTS (SELECT 1 FROM items i WHERE i.id = t.item_id)
+			OR NOT EXISTS (SELECT 1 FROM users u WHERE u.id = t.user_id)`,
+	},
+}
+
+// cleanup_orphans reports alert/watchlist rows that reference missing items or
+// users and, with --confirm, deletes them in a single transaction.
+//
+//	cleanup_orphans             report orphan counts per table
+//	cleanup_orphans --confirm   delete the orphans
+func main() {
+	confirm := flag.Bool("confirm", false, "Delete the orphaned rows")
+	flag.Parse()
+
+	// Setup logger
+	zerolog.TimeFieldFormat = zerolog.TimeFormatUnix
+	log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stderr})
+
+	// Load configuration
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to load configuration")
+	}
+
+	ctx := context.Background()
+
+	// Connect to database
+	db, err := database.New(ctx, cfg.DatabaseURL)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to connect to database")
+	}
+	defer db.Close()
+
+	total := int64(0)
+	for _, check := range orphanChecks {
+		var count int64
+		query := "SELECT COUNT(*) FROM " + check.table + " t WHERE " + check.where
+		if err := db.Pool.QueryRow(ctx, query).Scan(&count); err != nil {
+			log.Fatal().Err(err).Str("table", check.table).Msg("Failed to count orphaned rows")
+		}
+		log.Info().Str("table", check.table).Int64("orphans", count).Msg("Orphan check")
+		total += count
+	}
+
+	if total == 0 {
+		log.Info().Msg("No orphaned rows found")
+		return
+	}
+	if !*confirm {
+		log.Warn().Int64("orphans", total).Msg("Re-run with --confirm to delete the orphaned rows")
+		return
+	}
+
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to begin transaction")
+	}
+	defer tx.Rollback(ctx)
+
+	for _, check := range orphanChecks {
+		tag, err := tx.Exec(ctx, "DELETE FROM "+check.table+" t WHERE "+check.where)
+		if err != nil {
+			log.Fatal().Err(err).Str("table", check.table).Msg("Failed to delete orphaned rows; nothing was changed")
+		}
+		log.Info().Str("table", check.table).Int64("deleted", tag.RowsAffected()).Msg("Deleted orphaned rows")
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		log.Fatal().Err(err).Msg("Failed to commit cleanup")
+	}
+	log.Info().Msg("Orphan cleanup complete")
+}
@@ -1,9 +1,13 @@
 package main
 
 import (
+	"context"
+	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
+	"time"
 
 	"github.com/bwmarrin/discordgo"
 	"github.com/joho/godotenv"
@@ -11,6 +15,7 @@ import (
 	"github.com/rs/zerolog/log"
 
 	"github.com/akagifreeez/torn-market-chart/internal/discordbot"
+	"github.com/akagifreeez/torn-market-chart/internal/services"
 )
 
 func main() {
@@ -38,14 +43,38 @@ func main() {
 		apiBaseURL = "http://localhost:8080" // Fallback for local testing
 	}
 
+	// Must match the API's BOT_API_SECRET; /bot routes reject requests without it
+	apiSecret := os.Getenv("BOT_API_SECRET")
+	if apiSecret == "" {
+		log.Warn().Msg("BOT_API_SECRET not set, alert and settings commands will fail")
+	}
+
 	// Create a new Discord session using the provided bot token.
 	dg, err := discordgo.New("Bot " + token)
 	if err != nil {
 		log.Fatal().Err(err).Msg("error creating Discord session")
 	}
 
+	// Chart rendering is CPU-bound: cap concurrent renders and queue the rest
+	chartRenders := 2
+	if raw := os.Getenv("CHART_RENDER_CONCURRENCY"); raw != "" {
+		if n, err := strconv.Atoi(raw); err == nil && n > 0 {
+			chartRenders = n
+		} else {
+			log.Warn().Str("value", raw).Msg("Invalid CHART_RENDER_CONCURRENCY, using default")
+		}
+	}
+	chartQueueTimeout := 10 * time.Second
+	if raw := os.Getenv("CHART_RENDER_QUEUE_TIMEOUT"); raw != "" {
+		if d, err := time.ParseDuration(raw); err == nil && d > 0 {
+			chartQueueTimeout = d
+		} else {
+			log.Warn().Str("value", raw).Msg("Invalid CHART_RENDER_QUEUE_TIMEOUT, using default")
+		}
+	}
+
 	// Initialize bot handler
-	botHandler := discordbot.NewBotHandler(apiBaseURL)
+	botHandler := discordbot.NewBotHandler(apiBaseURL, apiSecret, services.NewChartService(chartRenders, chartQueueTimeout))
 	botHandler.RegisterHandlers(dg)
 
 	// Open a websocket connection to Discord and begin listening.
@@ -55,11 +84,32 @@ func main() {
 	}
 
 	// Register commands
-	log.Info().Msg("Registering commands...")
-	_, err = botHandler.RegisterCommands(dg, appID, guildID)
+	log.Info().Str("guild_id", guildID).Msg("Registering commands...")
+	registered, err := botHandler.RegisterCommands(dg, appID, guildID)
 	if err != nil {
 		log.Fatal().Err(err).Msg("error registering commands")
 	}
+	log.Info().Int("count", len(registered)).Msg("Commands registered")
+
+	// Health endpoint for orchestrators
+	healthPort := os.Getenv("HEALTH_PORT")
+	if healthPort == "" {
+		healthPort = "8081"
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/health", discordbot.HealthHandler(dg))
+	healthServer := &http.Server{
+		Addr:         ":" + healthPort,
+		Handler:      mux,
+		ReadTimeout:  5 * time.Second,
+		WriteTimeout: 5 * time.Second,
+	}
+	go func() {
+		log.Info().Str("port", healthPort).Msg("Health server listening")
+		if err := healthServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Error().Err(err).Msg("Health server error")
+		}
+	}()
 
 	log.Info().Msg("Bot is now running. Press CTRL-C to exit.")
 	sc := make(chan os.Signal, 1)
@@ -68,6 +118,10 @@ func main() {
 
 	log.Info().Msg("Gracefully shutting down.")
 
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	healthServer.Shutdown(shutdownCtx)
+
 	// Cleanly close down the Discord session.
 	dg.Close()
 }
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"os"
 
@@ -12,7 +13,18 @@ import (
 	"github.com/akagifreeez/torn-market-chart/pkg/database"
 )
 
+// migrate_volume drops the continuous aggregates so they can be rebuilt with a new
+// definition (e.g. after changing the volume column).
+//
+//	migrate_volume --dry-run              list views and dependent objects that would be dropped
+//	migrate_volume --confirm              drop the views
+//	migrate_volume --confirm --recreate   drop, recreate and backfill the views in one go
 func main() {
+	dryRun := flag.Bool("dry-run", false, "List what would be dropped without changing anything")
+	confirm := flag.Bool("confirm", false, "Actually drop the materialized views")
+	recreate := flag.Bool("recreate", false, "Recreate the views and refresh them after dropping")
+	flag.Parse()
+
 	// Setup logger
 	zerolog.TimeFieldFormat = zerolog.TimeFormatUnix
 	log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stderr})
@@ -32,13 +44,25 @@ func main() {
 	}
 	defer db.Close()
 
-	views := []string{
-		"market_prices_1m",
-		"market_prices_1h",
-		"market_prices_1d",
-		"bazaar_prices_1m",
-		"bazaar_prices_1h",
-		"bazaar_prices_1d",
+	views := database.ContinuousAggregateViews
+
+	// Preview: views and objects that DROP ... CASCADE would take with them
+	for _, view := range views {
+		dependents, err := dependentObjects(ctx, db, view)
+		if err != nil {
+			log.Error().Err(err).Str("view", view).Msg("Failed to inspect dependent objects")
+			continue
+		}
+		log.Info().Str("view", view).Strs("dependents", dependents).Msg("Would drop materialized view")
+	}
+
+	if *dryRun {
+		log.Info().Msg("Dry run: nothing was dropped")
+		return
+	}
+	if !*confirm {
+		log.Warn().Msg("Refusing to drop views without --confirm (use --dry-run to preview)")
+		os.Exit(1)
 	}
 
 	for _, view := range views {
@@ -51,5 +75,49 @@ func main() {
 		}
 	}
 
-	log.Info().Msg("Migration completed. Please restart the API server to recreate views.")
+	if !*recreate {
+		log.Info().Msg("Views dropped. Re-run with --recreate (or use --confirm --recreate next time) to rebuild them.")
+		return
+	}
+
+	log.Info().Msg("Recreating continuous aggregates...")
+	if err := db.EnsureContinuousAggregates(ctx); err != nil {
+		log.Fatal().Err(err).Msg("Failed to recreate continuous aggregates")
+	}
+
+	for _, view := range views {
+		log.Info().Str("view", view).Msg("Refreshing continuous aggregate")
+		if _, err := db.Pool.Exec(ctx, "CALL refresh_continuous_aggregate($1, NULL, NULL)", view); err != nil {
+			log.Error().Err(err).Str("view", view).Msg("Failed to refresh view")
+		}
+	}
+
+	log.Info().Msg("Migration completed. Views recreated and refreshed.")
+}
+
+// dependentObjects returns views depending on the given relation (dropped by CASCADE)
+func dependentObjects(ctx context.Context, db *database.DB, relation string) ([]string, error) {
+	rows, err := db.Pool.Query(ctx, `
+		SELECT DISTINCT dependent.relname
+		FROM pg_depend d
+		JOIN pg_rewrite rw ON d.objid = rw.oid
+		JOIN pg_class dependent ON rw.ev_class = dependent.oid
+		JOIN pg_class source ON d.refobjid = source.oid
+		WHERE source.relname = $1 AND dependent.relname <> $1
+		ORDER BY 1
+	`, relation)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	dependents := make([]string, 0)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		dependents = append(dependents, name)
+	}
+	return dependents, rows.Err()
 }
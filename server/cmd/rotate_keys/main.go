@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"os"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+
+	"github.com/akagifreeez/torn-market-chart/internal/config"
+	"github.com/akagifreeez/torn-market-chart/pkg/crypto"
+	"github.com/akagifreeez/torn-market-chart/pkg/database"
+)
+
+// rotate_keys re-encrypts every stored user API key from OLD_ENCRYPTION_KEY to
+// NEW_ENCRYPTION_KEY in a single transaction. Rows that already decrypt with the
+// new key are skipped, so the command can be re-run safely on partially rotated data.
+// After it succeeds, set ENCRYPTION_KEY to the new key and restart the services.
+func main() {
+	// Setup logger
+	zerolog.TimeFieldFormat = zerolog.TimeFormatUnix
+	log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stderr})
+
+	oldKey := os.Getenv("OLD_ENCRYPTION_KEY")
+	newKey := os.Getenv("NEW_ENCRYPTION_KEY")
+	if len(oldKey) != 32 || len(newKey) != 32 {
+		log.Fatal().Msg("OLD_ENCRYPTION_KEY and NEW_ENCRYPTION_KEY must both be set to 32-byte keys")
+	}
+	if oldKey == newKey {
+		log.Fatal().Msg("OLD_ENCRYPTION_KEY and NEW_ENCRYPTION_KEY are identical")
+	}
+
+	// Load configuration
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to load configuration")
+	}
+
+	ctx := context.Background()
+
+	// Connect to database
+	db, err := database.New(ctx, cfg.DatabaseURL)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to connect to database")
+	}
+	defer db.Close()
+
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to begin transaction")
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, "SELECT id, encrypted_api_key FROM users WHERE encrypted_api_key IS NOT NULL FOR UPDATE")
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to query encrypted keys")
+	}
+
+	type row struct {
+		id        int64
+		encrypted string
+	}
+	var pending []row
+	for rows.Next() {
+		var r row
+		if err := rows.Scan(&r.id, &r.encrypted); err != nil {
+			rows.Close()
+			log.Fatal().Err(err).Msg("Failed to scan row")
+		}
+		pending = append(pending, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		log.Fatal().Err(err).Msg("Failed to read encrypted keys")
+	}
+
+	rotated, skipped := 0, 0
+	for _, r := range pending {
+		// Already rotated by a previous (partial) run
+		if _, err := crypto.Decrypt(newKey, r.encrypted); err == nil {
+			skipped++
+			continue
+		}
+
+		plaintext, err := crypto.Decrypt(oldKey, r.encrypted)
+		if err != nil {
+			log.Fatal().Err(err).Int64("user_id", r.id).Msg("Failed to decrypt with old key, aborting without changes")
+		}
+
+		reencrypted, err := crypto.Encrypt(newKey, plaintext)
+		if err != nil {
+			log.Fatal().Err(err).Int64("user_id", r.id).Msg("Failed to encrypt with new key, aborting without changes")
+		}
+
+		if _, err := tx.Exec(ctx, "UPDATE users SET encrypted_api_key = $1 WHERE id = $2", reencrypted, r.id); err != nil {
+			log.Fatal().Err(err).Int64("user_id", r.id).Msg("Failed to update key, aborting without changes")
+		}
+		rotated++
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		log.Fatal().Err(err).Msg("Failed to commit rotation")
+	}
+
+	log.Info().
+		Int("rotated", rotated).
+		Int("already_rotated", skipped).
+		Msg("Key rotation completed. Set ENCRYPTION_KEY to the new key and restart the services.")
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
@@ -43,14 +44,21 @@ func main() {
 	defer db.Close()
 
 	// Create Torn API client (for GlobalSync and BackgroundCrawler)
-	// NewClient now initializes its own RateLimiter internally using "torn_api:rate_limit"
-	client := tornapi.NewClient(cfg.TornAPIKeys, cfg.RedisURL)
+	// The limiter is shared via Redis under "torn_api:rate_limit" (in-process fallback in dev)
+	apiLimiter, err := tornapi.NewLimiterWithFallback(cfg.RedisURL, 100, tornapi.DefaultWindow, "torn_api:rate_limit", cfg.RequireRedis) // Default 100 req/min
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to create Torn API RateLimiter")
+	}
+	defer apiLimiter.Close()
+	client := tornapi.NewClient(cfg.TornAPIKeys, apiLimiter, cfg.TornAPITimeout)
 
 	// Create services
 	keyManager := services.NewKeyManager(db, cfg)
 	keyManager.StartAutoRefresh(ctx)
+	client.SetKeySource(keyManager) // Fall back to user-contributed keys when system keys are missing or limited
 	settingsService := services.NewSettingsService(db.Pool)
-	alertService := services.NewAlertService(db.Pool, settingsService, cfg.AlertCooldown, cfg.PriceThreshold, cfg.DiscordBotToken)
+	emailSender := services.NewEmailSender(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUser, cfg.SMTPPass, cfg.SMTPFrom)
+	alertService := services.NewAlertService(db.Pool, settingsService, cfg.AlertCooldown, cfg.AlertDedupWindow, cfg.PriceThreshold, cfg.RecordLookback, cfg.DiscordBotToken, emailSender)
 
 	// Start a goroutine to update rate limits dynamically
 	go func() {
@@ -72,24 +80,66 @@ func main() {
 		}
 	}()
 
+	// Start a goroutine to pick up system API key changes made in the admin settings.
+	// Read straight from the DB: the settings cache only sees this process's writes.
+	go func() {
+		ticker := time.NewTicker(1 * time.Minute)
+		defer ticker.Stop()
+
+		applied := strings.Join(cfg.TornAPIKeys, ",")
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				raw, err := settingsService.GetRaw(ctx, "TORN_API_KEY")
+				if err != nil {
+					log.Warn().Err(err).Msg("Failed to read TORN_API_KEY from settings")
+					continue
+				}
+				var keys []string
+				for _, k := range strings.Split(raw, ",") {
+					if k = strings.TrimSpace(k); k != "" {
+						keys = append(keys, k)
+					}
+				}
+				// An empty setting keeps the current keys rather than disabling the client
+				if len(keys) == 0 || strings.Join(keys, ",") == applied {
+					continue
+				}
+				client.SetKeys(keys)
+				applied = strings.Join(keys, ",")
+				log.Info().Int("keys", len(keys)).Msg("Reloaded Torn API keys from settings")
+			}
+		}
+	}()
+
 	// Create Bazaar RateLimiter (separate from API key limits)
-	bazaarLimiter, err := tornapi.NewRateLimiter(cfg.RedisURL, cfg.BazaarRateLimit, "bazaar:rate_limit")
+	bazaarLimiter, err := tornapi.NewLimiterWithFallback(cfg.RedisURL, cfg.BazaarRateLimit, tornapi.DefaultWindow, "bazaar:rate_limit", cfg.RequireRedis)
 	if err != nil {
-		log.Warn().Err(err).Msg("Failed to create Bazaar RateLimiter")
-		bazaarLimiter = nil
+		log.Fatal().Err(err).Msg("Failed to create Bazaar RateLimiter")
 	}
 
+	// Shared by every price insert path so they agree on the last stored point
+	priceThrottle := services.NewPriceThrottle(cfg.MinPriceChangePct, cfg.PriceMaxGap)
+
 	// Create workers
 	globalSync := workers.NewGlobalSync(db.Pool, client, cfg)
-	bazaarPoller := workers.NewBazaarPoller(db.Pool, cfg, alertService, bazaarLimiter)  // Uses Weav3r.dev
-	backgroundCrawler := workers.NewBackgroundCrawler(db.Pool, client, keyManager, cfg) // Uses Official API v2
-	wsService := services.NewTornWebSocketService(cfg, db.Pool, alertService)
+	bazaarPoller := workers.NewBazaarPoller(db.Pool, cfg, alertService, priceThrottle, bazaarLimiter)  // Uses Weav3r.dev
+	backgroundCrawler := workers.NewBackgroundCrawler(db.Pool, client, keyManager, priceThrottle, cfg) // Uses Official API v2
+	wsService := services.NewTornWebSocketService(cfg, db.Pool, alertService, settingsService, priceThrottle, nil)
+	alertStatePruner := workers.NewAlertStatePruner(db.Pool, cfg)
+	soldListingPruner := workers.NewSoldListingPruner(db.Pool, cfg)
+	caggRefresher := workers.NewCaggRefresher(db.Pool, cfg)
 
 	// Start workers in goroutines
 	go globalSync.Start(ctx)
 	go bazaarPoller.Start(ctx)
 	go backgroundCrawler.Start(ctx)
 	go wsService.Start(ctx)
+	go alertStatePruner.Start(ctx)
+	go soldListingPruner.Start(ctx)
+	go caggRefresher.Start(ctx)
 
 	log.Info().Msg("All workers started")
 
@@ -101,5 +151,12 @@ func main() {
 	log.Info().Msg("Shutdown signal received, stopping workers...")
 	cancel()
 
+	// Let pending alert notifications finish delivering
+	drainCtx, drainCancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer drainCancel()
+	if err := alertService.Shutdown(drainCtx); err != nil {
+		log.Warn().Err(err).Msg("Timed out waiting for alert deliveries")
+	}
+
 	log.Info().Msg("Workers stopped")
 }
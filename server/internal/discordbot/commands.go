@@ -3,6 +3,7 @@ package discordbot
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -21,14 +22,41 @@ type BotHandler struct {
 	chartService *services.ChartService
 }
 
-func NewBotHandler(apiBaseURL string) *BotHandler {
+// NewBotHandler creates a handler whose API requests carry apiSecret, which
+// the API requires on its /bot routes
+func NewBotHandler(apiBaseURL, apiSecret string, chartService *services.ChartService) *BotHandler {
 	return &BotHandler{
-		apiBaseURL:   apiBaseURL,
-		httpClient:   &http.Client{Timeout: 10 * time.Second},
-		chartService: services.NewChartService(),
+		apiBaseURL: apiBaseURL,
+		httpClient: &http.Client{
+			Timeout:   10 * time.Second,
+			Transport: &secretTransport{secret: apiSecret, base: http.DefaultTransport},
+		},
+		chartService: chartService,
+	}
+}
+
+// secretTransport adds the bot secret header to every API request
+type secretTransport struct {
+	secret string
+	base   http.RoundTripper
+}
+
+func (t *secretTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.secret == "" {
+		return t.base.RoundTrip(req)
 	}
+	req = req.Clone(req.Context())
+	req.Header.Set("X-Bot-Secret", t.secret)
+	return t.base.RoundTrip(req)
 }
 
+// Values of the /price source option
+const (
+	priceSourceMarket = "market"
+	priceSourceBazaar = "bazaar"
+	priceSourceBoth   = "both"
+)
+
 var commands = []*discordgo.ApplicationCommand{
 	{
 		Name:        "price",
@@ -41,6 +69,17 @@ var commands = []*discordgo.ApplicationCommand{
 				Required:     true,
 				Autocomplete: true,
 			},
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        "source",
+				Description: "Which price to chart (default: market)",
+				Required:    false,
+				Choices: []*discordgo.ApplicationCommandOptionChoice{
+					{Name: "Market", Value: priceSourceMarket},
+					{Name: "Bazaar", Value: priceSourceBazaar},
+					{Name: "Both", Value: priceSourceBoth},
+				},
+			},
 		},
 	},
 	{
@@ -65,18 +104,27 @@ var commands = []*discordgo.ApplicationCommand{
 			{
 				Type:        discordgo.ApplicationCommandOptionString,
 				Name:        "condition",
-				Description: "Trigger when price is above or below",
+				Description: "Trigger when price is above/below, moves by an amount, or hits a record",
 				Required:    true,
 				Choices: []*discordgo.ApplicationCommandOptionChoice{
 					{Name: "Above", Value: "above"},
 					{Name: "Below", Value: "below"},
+					{Name: "Moves by (absolute $)", Value: "move"},
+					{Name: "Record low/high", Value: "record"},
 				},
 			},
 			{
 				Type:        discordgo.ApplicationCommandOptionInteger,
 				Name:        "price",
-				Description: "The price threshold",
-				Required:    true,
+				Description: "The price threshold or move amount (not needed for record alerts)",
+				Required:    false,
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionInteger,
+				Name:        "cooldown",
+				Description: "Minutes between repeat alerts (0 = none, -1 = server setting, omit to keep current)",
+				Required:    false,
+				MinValue:    func() *float64 { v := -1.0; return &v }(),
 			},
 		},
 	},
@@ -93,17 +141,39 @@ var commands = []*discordgo.ApplicationCommand{
 			},
 		},
 	},
+	{
+		Name:        "setwebhook",
+		Description: "Set the Discord webhook your alerts are delivered to",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        "url",
+				Description: "Discord webhook URL (https://discord.com/api/webhooks/...)",
+				Required:    true,
+			},
+		},
+	},
+	{
+		Name:        "status",
+		Description: "Show bot status and tracking stats",
+	},
 	{
 		Name:        "help",
 		Description: "Display help information about Torn Market Chart Bot",
 	},
+	configCommand,
 }
 
 func (h *BotHandler) RegisterHandlers(s *discordgo.Session) {
 	s.AddHandler(func(s *discordgo.Session, i *discordgo.InteractionCreate) {
 		switch i.Type {
 		case discordgo.InteractionApplicationCommand:
-			switch i.ApplicationCommandData().Name {
+			name := i.ApplicationCommandData().Name
+			if h.commandDisabled(i.GuildID, name) {
+				respondEphemeral(s, i, "This command is disabled in this server.")
+				return
+			}
+			switch name {
 			case "price":
 				h.handlePrice(s, i)
 			case "summary":
@@ -114,8 +184,14 @@ func (h *BotHandler) RegisterHandlers(s *discordgo.Session) {
 				h.handleAlertAdd(s, i)
 			case "alert_remove":
 				h.handleAlertRemove(s, i)
+			case "setwebhook":
+				h.handleSetWebhook(s, i)
+			case "status":
+				h.handleStatus(s, i)
 			case "help":
 				h.handleHelp(s, i)
+			case "config":
+				h.handleConfig(s, i)
 			}
 		case discordgo.InteractionApplicationCommandAutocomplete:
 			h.handleAutocomplete(s, i)
@@ -124,13 +200,15 @@ func (h *BotHandler) RegisterHandlers(s *discordgo.Session) {
 }
 
 func (h *BotHandler) RegisterCommands(s *discordgo.Session, appID, guildID string) ([]*discordgo.ApplicationCommand, error) {
-	registeredCommands := make([]*discordgo.ApplicationCommand, len(commands))
-	var err error
-	for idx, cmd := range commands {
-		registeredCommands[idx], err = s.ApplicationCommandCreate(appID, guildID, cmd)
-		if err != nil {
-			return nil, fmt.Errorf("cannot create '%v' command: %w", cmd.Name, err)
+	// Overwrite the whole set so commands removed from code are unregistered too.
+	// An empty guildID registers globally (slower to propagate).
+	registeredCommands, err := s.ApplicationCommandBulkOverwrite(appID, guildID, commands)
+	if err != nil {
+		scope := "global"
+		if guildID != "" {
+			scope = "guild " + guildID
 		}
+		return nil, fmt.Errorf("cannot overwrite %s commands: %w", scope, err)
 	}
 	return registeredCommands, nil
 }
@@ -143,10 +221,13 @@ func (h *BotHandler) handlePrice(s *discordgo.Session, i *discordgo.InteractionC
 
 	data := i.ApplicationCommandData()
 	var query string
+	source := priceSourceMarket
 	for _, opt := range data.Options {
-		if opt.Name == "item" {
+		switch opt.Name {
+		case "item":
 			query = opt.StringValue()
-			break
+		case "source":
+			source = opt.StringValue()
 		}
 	}
 
@@ -169,36 +250,39 @@ func (h *BotHandler) handlePrice(s *discordgo.Session, i *discordgo.InteractionC
 	}
 
 	item := items[0] // take the best match
+	loc := h.userLocation(i)
 
 	p := message.NewPrinter(language.English)
 	marketPrice := "N/A"
 	if item.LastMarketPrice > 0 {
-		marketPrice = p.Sprintf("$%d", item.LastMarketPrice)
+		marketPrice = formatPrice(p, item.LastMarketPrice)
 	}
 
 	bazaarPrice := "N/A"
 	if item.LastBazaarPrice > 0 {
-		bazaarPrice = p.Sprintf("$%d", item.LastBazaarPrice)
+		bazaarPrice = formatPrice(p, item.LastBazaarPrice)
+	}
+
+	marketField := &discordgo.MessageEmbedField{Name: "Market Price", Value: marketPrice, Inline: true}
+	bazaarField := &discordgo.MessageEmbedField{Name: "Bazaar Price", Value: bazaarPrice, Inline: true}
+	fields := []*discordgo.MessageEmbedField{marketField, bazaarField}
+	color := 0x0099ff
+	switch source {
+	case priceSourceMarket:
+		marketField.Value = "**" + marketPrice + "**"
+	case priceSourceBazaar:
+		bazaarField.Value = "**" + bazaarPrice + "**"
+		fields = []*discordgo.MessageEmbedField{bazaarField, marketField}
+		color = 0xf0a030
 	}
 
 	embed := &discordgo.MessageEmbed{
 		Title:       fmt.Sprintf("Price for %s", item.Name),
 		Description: fmt.Sprintf("[View on Torn Official Market](https://www.torn.com/page.php?sid=ItemMarket#/market/view=search&itemID=%d)", item.ID),
-		Color:       0x0099ff,
-		Fields: []*discordgo.MessageEmbedField{
-			{
-				Name:   "Market Price",
-				Value:  marketPrice,
-				Inline: true,
-			},
-			{
-				Name:   "Bazaar Price",
-				Value:  bazaarPrice,
-				Inline: true,
-			},
-		},
+		Color:       color,
+		Fields:      fields,
 		Footer: &discordgo.MessageEmbedFooter{
-			Text: fmt.Sprintf("Last updated: %s", item.LastUpdatedAt.Format("2006-01-02 15:04:05 UTC")),
+			Text: fmt.Sprintf("Last updated: %s", item.LastUpdatedAt.In(loc).Format("2006-01-02 15:04:05 MST")),
 		},
 	}
 
@@ -207,33 +291,127 @@ func (h *BotHandler) handlePrice(s *discordgo.Session, i *discordgo.InteractionC
 	// ---------------------------------------------------------
 	// Fetch History & Generate Chart
 	// ---------------------------------------------------------
-	historyReqURL := fmt.Sprintf("%s/api/v1/items/%d/history", h.apiBaseURL, item.ID)
-	hResp, hErr := h.httpClient.Get(historyReqURL)
-	if hErr == nil && hResp.StatusCode == http.StatusOK {
-		defer hResp.Body.Close()
-		var history []models.Item
-		if err := json.NewDecoder(hResp.Body).Decode(&history); err == nil && len(history) > 1 {
-			// Generate PNG
-			chartBytes, err := h.chartService.GeneratePriceChartPNG(item.Name, history)
-			if err == nil {
-				// Attach the image
-				files = append(files, &discordgo.File{
-					Name:        fmt.Sprintf("chart_%d.png", item.ID),
-					ContentType: "image/png",
-					Reader:      bytes.NewReader(chartBytes),
-				})
-				// Reference the attachment in the embed
-				embed.Image = &discordgo.MessageEmbedImage{
-					URL: fmt.Sprintf("attachment://chart_%d.png", item.ID),
-				}
-			}
+	var chartBytes []byte
+	var chartErr error
+	if source == priceSourceBoth {
+		chartBytes, chartErr = h.chartService.GenerateSeriesChartPNG(item.Name, h.combinedSeries(item.ID, loc), loc)
+	} else {
+		chartBytes, chartErr = h.chartService.GenerateCandlestickPNG(item.Name, h.priceCandles(item.ID, source, loc), loc)
+	}
+	if chartErr == nil {
+		// Attach the image
+		files = append(files, &discordgo.File{
+			Name:        fmt.Sprintf("chart_%d.png", item.ID),
+			ContentType: "image/png",
+			Reader:      bytes.NewReader(chartBytes),
+		})
+		// Reference the attachment in the embed
+		embed.Image = &discordgo.MessageEmbedImage{
+			URL: fmt.Sprintf("attachment://chart_%d.png", item.ID),
 		}
 	}
 
-	s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+	edit := &discordgo.WebhookEdit{
 		Embeds: &[]*discordgo.MessageEmbed{embed},
 		Files:  files,
-	})
+	}
+	if errors.Is(chartErr, services.ErrChartBusy) {
+		busy := "Charts are busy right now, try again in a moment."
+		edit.Content = &busy
+	}
+	s.InteractionResponseEdit(i.Interaction, edit)
+}
+
+// combinedSeries fetches the last 24h of hourly market and bazaar closes from
+// the combined-history endpoint for the "both" /price chart
+func (h *BotHandler) combinedSeries(itemID int64, loc *time.Location) []services.PriceSeries {
+	reqURL := fmt.Sprintf("%s/api/v1/items/%d/history/combined?interval=1h&days=1&tz=%s", h.apiBaseURL, itemID, url.QueryEscape(loc.String()))
+	resp, err := h.httpClient.Get(reqURL)
+	if err != nil {
+		return nil
+	}
+	defer resp.Body.Close()
+
+	var combined struct {
+		Buckets []time.Time           `json:"buckets"`
+		Market  []*models.PriceCandle `json:"market"`
+		Bazaar  []*models.PriceCandle `json:"bazaar"`
+	}
+	if resp.StatusCode != http.StatusOK || json.NewDecoder(resp.Body).Decode(&combined) != nil {
+		return nil
+	}
+
+	market := services.PriceSeries{Name: "Market Price", Color: "5865F2"} // Blurple
+	bazaar := services.PriceSeries{Name: "Bazaar Price", Color: "F0A030"}
+	for idx, bucket := range combined.Buckets {
+		if idx < len(combined.Market) && combined.Market[idx] != nil {
+			market.Times = append(market.Times, bucket)
+			market.Prices = append(market.Prices, float64(combined.Market[idx].Close))
+		}
+		if idx < len(combined.Bazaar) && combined.Bazaar[idx] != nil {
+			bazaar.Times = append(bazaar.Times, bucket)
+			bazaar.Prices = append(bazaar.Prices, float64(combined.Bazaar[idx].Close))
+		}
+	}
+	return []services.PriceSeries{market, bazaar}
+}
+
+// priceCandles fetches the last 7 days of hourly candles for a single /price source.
+// The window ends at the current hour and skips the realtime tail, so repeated
+// renders within the 1h aggregate's refresh window show the same candles.
+func (h *BotHandler) priceCandles(itemID int64, source string, loc *time.Location) []models.PriceCandle {
+	end := time.Now().UTC().Truncate(time.Hour).Format(time.RFC3339)
+	reqURL := fmt.Sprintf("%s/api/v1/items/%d/history?interval=1h&days=7&type=%s&tz=%s&realtime=false&end=%s",
+		h.apiBaseURL, itemID, source, url.QueryEscape(loc.String()), url.QueryEscape(end))
+	resp, err := h.httpClient.Get(reqURL)
+	if err != nil {
+		return nil
+	}
+	defer resp.Body.Close()
+
+	var candles []models.PriceCandle
+	if resp.StatusCode != http.StatusOK || json.NewDecoder(resp.Body).Decode(&candles) != nil {
+		return nil
+	}
+	return candles
+}
+
+// interactionUserID returns the invoking user's Discord ID. Guild interactions
+// carry it in Member, DMs in User; empty when neither is set.
+func interactionUserID(i *discordgo.InteractionCreate) string {
+	switch {
+	case i.Member != nil && i.Member.User != nil:
+		return i.Member.User.ID
+	case i.User != nil:
+		return i.User.ID
+	}
+	return ""
+}
+
+// userLocation looks up the invoking user's timezone setting, falling back to UTC
+func (h *BotHandler) userLocation(i *discordgo.InteractionCreate) *time.Location {
+	discordID := interactionUserID(i)
+	if discordID == "" {
+		return time.UTC
+	}
+
+	resp, err := h.httpClient.Get(fmt.Sprintf("%s/api/v1/bot/settings/%s/timezone", h.apiBaseURL, discordID))
+	if err != nil {
+		return time.UTC
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		Timezone string `json:"timezone"`
+	}
+	if resp.StatusCode != http.StatusOK || json.NewDecoder(resp.Body).Decode(&body) != nil {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(body.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
 }
 
 func (h *BotHandler) handleHelp(s *discordgo.Session, i *discordgo.InteractionCreate) {
@@ -243,8 +421,12 @@ func (h *BotHandler) handleHelp(s *discordgo.Session, i *discordgo.InteractionCr
 		Color:       0x00ff00,
 		Fields: []*discordgo.MessageEmbedField{
 			{
-				Name:  "/price <item>",
-				Value: "Search for an item and get its current Market and Bazaar prices.",
+				Name:  "/price <item> [source]",
+				Value: "Search for an item and get its current Market and Bazaar prices. `source` picks the chart: market (default), bazaar or both.",
+			},
+			{
+				Name:  "/config [command] [enabled]",
+				Value: "Server managers can switch bot commands on or off for this server. Run without options to list disabled commands.",
 			},
 		},
 	}
@@ -256,6 +438,79 @@ func (h *BotHandler) handleHelp(s *discordgo.Session, i *discordgo.InteractionCr
 	})
 }
 
+type statsResponse struct {
+	TotalItems   int64      `json:"total_items"`
+	TrackedItems int64      `json:"tracked_items"`
+	TotalAlerts  int64      `json:"total_alerts"`
+	LastSyncAt   *time.Time `json:"last_sync_at"`
+	WSConnected  bool       `json:"ws_connected"`
+}
+
+func (h *BotHandler) handleStatus(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+	})
+
+	reqURL := fmt.Sprintf("%s/api/v1/stats", h.apiBaseURL)
+	resp, err := h.httpClient.Get(reqURL)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		if err == nil {
+			resp.Body.Close()
+		}
+		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content: func() *string { str := "⚠️ Unable to reach the Torn Market Chart API."; return &str }(),
+		})
+		return
+	}
+	defer resp.Body.Close()
+
+	var stats statsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content: func() *string { str := "Error reading stats from API."; return &str }(),
+		})
+		return
+	}
+
+	lastSync := "Not yet"
+	if stats.LastSyncAt != nil {
+		lastSync = fmt.Sprintf("<t:%d:R>", stats.LastSyncAt.Unix())
+	}
+	wsStatus := "🟢 Connected"
+	color := 0x00ff00
+	if !stats.WSConnected {
+		wsStatus = "🔴 Disconnected"
+		color = 0xffa500
+	}
+
+	p := message.NewPrinter(language.English)
+	embed := &discordgo.MessageEmbed{
+		Title: "Torn Market Chart Status",
+		Color: color,
+		Fields: []*discordgo.MessageEmbedField{
+			{Name: "Items", Value: p.Sprintf("%d", stats.TotalItems), Inline: true},
+			{Name: "Tracked", Value: p.Sprintf("%d", stats.TrackedItems), Inline: true},
+			{Name: "Alerts", Value: p.Sprintf("%d", stats.TotalAlerts), Inline: true},
+			{Name: "Last Catalog Sync", Value: lastSync, Inline: true},
+			{Name: "Live Feed", Value: wsStatus, Inline: true},
+		},
+	}
+
+	s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+		Embeds: &[]*discordgo.MessageEmbed{embed},
+	})
+}
+
+// formatPrice renders a price abbreviated for scanning, followed by the full
+// localized value when the two differ: "$1.23B ($1,234,567,890)"
+func formatPrice(p *message.Printer, v int64) string {
+	short := services.AbbreviatePrice(float64(v))
+	if v < 1000 && v > -1000 {
+		return short
+	}
+	return p.Sprintf("%s ($%d)", short, v)
+}
+
 type summaryItem struct {
 	ID            int64   `json:"id"`
 	Name          string  `json:"name"`
@@ -302,7 +557,10 @@ func (h *BotHandler) handleSummary(s *discordgo.Session, i *discordgo.Interactio
 		}
 
 		changeStr := fmt.Sprintf("%s %.2f%%", emoji, it.ChangePercent)
-		priceStr := p.Sprintf("$%d -> $%d", it.OldPrice, it.CurrentPrice)
+		priceStr := fmt.Sprintf("%s -> %s", services.AbbreviatePrice(float64(it.OldPrice)), services.AbbreviatePrice(float64(it.CurrentPrice)))
+		if it.OldPrice >= 1000 || it.CurrentPrice >= 1000 {
+			priceStr += p.Sprintf("\n($%d -> $%d)", it.OldPrice, it.CurrentPrice)
+		}
 
 		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
 			Name:   fmt.Sprintf("%s (%s)", it.Name, changeStr),
@@ -321,11 +579,13 @@ func (h *BotHandler) handleSummary(s *discordgo.Session, i *discordgo.Interactio
 // ----------------------------------------------------------------------
 
 type UserAlert struct {
-	ItemID             int64    `json:"item_id"`
-	ItemName           string   `json:"item_name"`
-	AlertPriceAbove    *int64   `json:"alert_price_above"`
-	AlertPriceBelow    *int64   `json:"alert_price_below"`
-	AlertChangePercent *float64 `json:"alert_change_percent"`
+	ItemID              int64    `json:"item_id"`
+	ItemName            string   `json:"item_name"`
+	AlertPriceAbove     *int64   `json:"alert_price_above"`
+	AlertPriceBelow     *int64   `json:"alert_price_below"`
+	AlertChangePercent  *float64 `json:"alert_change_percent"`
+	AlertChangeAbsolute *int64   `json:"alert_change_absolute"`
+	AlertOnRecord       bool     `json:"alert_on_record"`
 }
 
 func (h *BotHandler) handleAlerts(s *discordgo.Session, i *discordgo.InteractionCreate) {
@@ -380,6 +640,15 @@ func (h *BotHandler) handleAlerts(s *discordgo.Session, i *discordgo.Interaction
 		if a.AlertPriceBelow != nil {
 			conditions = append(conditions, p.Sprintf("**Below:** $%d", *a.AlertPriceBelow))
 		}
+		if a.AlertChangePercent != nil {
+			conditions = append(conditions, fmt.Sprintf("**Change:** ±%.1f%%", *a.AlertChangePercent))
+		}
+		if a.AlertChangeAbsolute != nil {
+			conditions = append(conditions, p.Sprintf("**Change:** ±$%d", *a.AlertChangeAbsolute))
+		}
+		if a.AlertOnRecord {
+			conditions = append(conditions, "**Record:** low/high")
+		}
 		val := "No conditions set"
 		if len(conditions) > 0 {
 			val = ""
@@ -434,6 +703,7 @@ func (h *BotHandler) handleAlertAdd(s *discordgo.Session, i *discordgo.Interacti
 
 	var itemName, condition string
 	var price int64
+	var cooldownMinutes *int64
 	for _, opt := range i.ApplicationCommandData().Options {
 		switch opt.Name {
 		case "item":
@@ -442,9 +712,19 @@ func (h *BotHandler) handleAlertAdd(s *discordgo.Session, i *discordgo.Interacti
 			condition = opt.StringValue()
 		case "price":
 			price = opt.IntValue()
+		case "cooldown":
+			v := opt.IntValue()
+			cooldownMinutes = &v
 		}
 	}
 
+	if condition != "record" && price <= 0 {
+		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content: func() *string { str := "Please provide a price for above/below/move alerts."; return &str }(),
+		})
+		return
+	}
+
 	discordID := i.Member.User.ID
 
 	// Resolve Item ID
@@ -460,11 +740,24 @@ func (h *BotHandler) handleAlertAdd(s *discordgo.Session, i *discordgo.Interacti
 	payload := map[string]interface{}{
 		"item_id": item.ID,
 	}
-	if condition == "above" {
+	switch condition {
+	case "above":
 		payload["alert_price_above"] = price
-	} else {
+	case "move":
+		payload["alert_change_absolute"] = price
+	case "record":
+		payload["alert_on_record"] = true
+	default:
 		payload["alert_price_below"] = price
 	}
+	if cooldownMinutes != nil {
+		if *cooldownMinutes < 0 {
+			// Clears the per-alert cooldown back to the server setting
+			payload["cooldown_seconds"] = -1
+		} else {
+			payload["cooldown_seconds"] = *cooldownMinutes * 60
+		}
+	}
 
 	body, _ := json.Marshal(payload)
 	reqURL := fmt.Sprintf("%s/api/v1/bot/alerts/%s", h.apiBaseURL, discordID)
@@ -489,6 +782,12 @@ func (h *BotHandler) handleAlertAdd(s *discordgo.Session, i *discordgo.Interacti
 	s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
 		Content: func() *string {
 			str := p.Sprintf("✅ Alert added for **%s** when price goes %s $%d", item.Name, condition, price)
+			switch condition {
+			case "record":
+				str = p.Sprintf("✅ Alert added for **%s** on a new record low or high", item.Name)
+			case "move":
+				str = p.Sprintf("✅ Alert added for **%s** when price moves by $%d", item.Name, price)
+			}
 			return &str
 		}(),
 	})
@@ -574,3 +873,66 @@ func (h *BotHandler) handleAutocomplete(s *discordgo.Session, i *discordgo.Inter
 		},
 	})
 }
+
+func (h *BotHandler) handleSetWebhook(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Flags: discordgo.MessageFlagsEphemeral, // The webhook URL is a secret
+		},
+	})
+
+	var webhookURL string
+	for _, opt := range i.ApplicationCommandData().Options {
+		if opt.Name == "url" {
+			webhookURL = opt.StringValue()
+		}
+	}
+
+	discordID := interactionUserID(i)
+	if discordID == "" {
+		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content: func() *string { str := "Could not identify the invoking user."; return &str }(),
+		})
+		return
+	}
+
+	body, _ := json.Marshal(map[string]string{"url": webhookURL})
+	reqURL := fmt.Sprintf("%s/api/v1/bot/settings/%s/webhook", h.apiBaseURL, discordID)
+	req, _ := http.NewRequest("POST", reqURL, bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := h.httpClient.Do(req)
+	if err != nil {
+		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content: func() *string { str := "Internal API error."; return &str }(),
+		})
+		return
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content: func() *string { str := "✅ Webhook saved. A test message was sent to it."; return &str }(),
+		})
+	case http.StatusNotFound:
+		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content: func() *string { str := "You are not linked. Login on the Web Dashboard first."; return &str }(),
+		})
+	case http.StatusBadRequest:
+		var apiErr struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		json.NewDecoder(resp.Body).Decode(&apiErr)
+		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content: func() *string { str := "❌ " + apiErr.Error.Message; return &str }(),
+		})
+	default:
+		s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{
+			Content: func() *string { str := "Failed to save webhook. Internal Server Error."; return &str }(),
+		})
+	}
+}
@@ -0,0 +1,163 @@
+package discordbot
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// toggleableCommands are the commands guild admins may switch off with /config.
+// /help and /config itself always stay available.
+var toggleableCommands = []string{"price", "summary", "alerts", "alert_add", "alert_remove", "setwebhook", "status"}
+
+var manageGuildPermission int64 = discordgo.PermissionManageGuild
+var configDMPermission = false
+
+var configCommand = func() *discordgo.ApplicationCommand {
+	choices := make([]*discordgo.ApplicationCommandOptionChoice, 0, len(toggleableCommands))
+	for _, name := range toggleableCommands {
+		choices = append(choices, &discordgo.ApplicationCommandOptionChoice{Name: "/" + name, Value: name})
+	}
+	return &discordgo.ApplicationCommand{
+		Name:                     "config",
+		Description:              "Enable or disable bot commands in this server (server managers only)",
+		DefaultMemberPermissions: &manageGuildPermission,
+		DMPermission:             &configDMPermission,
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        "command",
+				Description: "Command to toggle (omit to list disabled commands)",
+				Required:    false,
+				Choices:     choices,
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionBoolean,
+				Name:        "enabled",
+				Description: "Whether the command is available in this server",
+				Required:    false,
+			},
+		},
+	}
+}()
+
+// respondEphemeral replies immediately with a message only the invoking user sees
+func respondEphemeral(s *discordgo.Session, i *discordgo.InteractionCreate, content string) {
+	s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: content,
+			Flags:   discordgo.MessageFlagsEphemeral,
+		},
+	})
+}
+
+// guildDisabledCommands fetches the commands switched off in a guild
+func (h *BotHandler) guildDisabledCommands(guildID string) ([]string, error) {
+	resp, err := h.httpClient.Get(fmt.Sprintf("%s/api/v1/bot/guilds/%s/commands", h.apiBaseURL, guildID))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	var body struct {
+		Disabled []string `json:"disabled"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+	return body.Disabled, nil
+}
+
+// commandDisabled reports whether name is switched off in the guild. DMs and
+// API errors leave commands enabled so an outage doesn't silence the bot.
+func (h *BotHandler) commandDisabled(guildID, name string) bool {
+	if guildID == "" || name == "config" || name == "help" {
+		return false
+	}
+	disabled, err := h.guildDisabledCommands(guildID)
+	if err != nil {
+		return false
+	}
+	for _, d := range disabled {
+		if d == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *BotHandler) handleConfig(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	if i.GuildID == "" || i.Member == nil {
+		respondEphemeral(s, i, "/config can only be used in a server.")
+		return
+	}
+	if i.Member.Permissions&discordgo.PermissionManageGuild == 0 {
+		respondEphemeral(s, i, "You need the Manage Server permission to use /config.")
+		return
+	}
+
+	var command string
+	var enabled *bool
+	for _, opt := range i.ApplicationCommandData().Options {
+		switch opt.Name {
+		case "command":
+			command = opt.StringValue()
+		case "enabled":
+			v := opt.BoolValue()
+			enabled = &v
+		}
+	}
+
+	// Without a full toggle, just show the current state
+	if command == "" || enabled == nil {
+		disabled, err := h.guildDisabledCommands(i.GuildID)
+		if err != nil {
+			respondEphemeral(s, i, "Internal API error.")
+			return
+		}
+		if len(disabled) == 0 {
+			respondEphemeral(s, i, "All commands are enabled in this server.")
+			return
+		}
+		respondEphemeral(s, i, "Disabled in this server: /"+strings.Join(disabled, ", /"))
+		return
+	}
+
+	body, _ := json.Marshal(map[string]bool{"enabled": *enabled})
+	reqURL := fmt.Sprintf("%s/api/v1/bot/guilds/%s/commands/%s", h.apiBaseURL, i.GuildID, command)
+	req, _ := http.NewRequest(http.MethodPut, reqURL, bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	// The API re-checks the member's permissions before saving
+	req.Header.Set("X-Discord-Permissions", strconv.FormatInt(i.Member.Permissions, 10))
+
+	resp, err := h.httpClient.Do(req)
+	if err != nil {
+		respondEphemeral(s, i, "Internal API error.")
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusForbidden {
+		respondEphemeral(s, i, "You need the Manage Server permission to use /config.")
+		return
+	}
+	if resp.StatusCode != http.StatusOK {
+		respondEphemeral(s, i, "Failed to update server settings.")
+		return
+	}
+
+	state := "disabled"
+	if *enabled {
+		state = "enabled"
+	}
+	respondEphemeral(s, i, fmt.Sprintf("✅ /%s is now %s in this server.", command, state))
+}
@@ -0,0 +1,52 @@
+package discordbot
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// maxHeartbeatAge is how long the gateway may go without acking a heartbeat
+// before the bot reports itself unhealthy. Discord heartbeats every ~41s.
+const maxHeartbeatAge = 2 * time.Minute
+
+// HealthStatus describes the bot's gateway connection
+type HealthStatus struct {
+	Status           string     `json:"status"` // ok or disconnected
+	Connected        bool       `json:"connected"`
+	LastHeartbeatAck *time.Time `json:"last_heartbeat_ack,omitempty"`
+	LatencyMs        int64      `json:"latency_ms"`
+}
+
+// HealthHandler reports the session's connection state, returning 503 when
+// the gateway is down or has stopped acking heartbeats
+func HealthHandler(s *discordgo.Session) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.RLock()
+		ready := s.DataReady
+		lastAck := s.LastHeartbeatAck
+		lastSent := s.LastHeartbeatSent
+		s.RUnlock()
+
+		resp := HealthStatus{
+			Status:    "ok",
+			Connected: ready && !lastAck.IsZero() && time.Since(lastAck) < maxHeartbeatAge,
+			LatencyMs: lastAck.Sub(lastSent).Milliseconds(),
+		}
+		if !lastAck.IsZero() {
+			resp.LastHeartbeatAck = &lastAck
+		}
+
+		status := http.StatusOK
+		if !resp.Connected {
+			resp.Status = "disconnected"
+			status = http.StatusServiceUnavailable
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(resp)
+	}
+}
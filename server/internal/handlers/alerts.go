@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/akagifreeez/torn-market-chart/pkg/database"
+)
+
+// AlertHandler serves the user's triggered-alert history
+type AlertHandler struct {
+	db *database.DB
+}
+
+func NewAlertHandler(db *database.DB) *AlertHandler {
+	return &AlertHandler{db: db}
+}
+
+// GetUnreadCount returns how many alerts the user hasn't seen yet
+// GET /api/v1/user/alerts/unread/count
+func (h *AlertHandler) GetUnreadCount(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, ok := GetUserIDFromContext(ctx)
+	if !ok {
+		writeError(w, http.StatusUnauthorized, ErrCodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	var count int64
+	err := h.db.Pool.QueryRow(ctx, "SELECT COUNT(*) FROM alert_history WHERE user_id = $1 AND unread", userID).Scan(&count)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeDatabase, "Database error")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"unread": count,
+	})
+}
+
+// MarkAllSeen clears the unread flag on all of the user's alerts
+// POST /api/v1/user/alerts/seen
+func (h *AlertHandler) MarkAllSeen(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, ok := GetUserIDFromContext(ctx)
+	if !ok {
+		writeError(w, http.StatusUnauthorized, ErrCodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	tag, err := h.db.Pool.Exec(ctx, "UPDATE alert_history SET unread = false WHERE user_id = $1 AND unread", userID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to update alerts")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"marked_seen": tag.RowsAffected(),
+	})
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -39,12 +40,12 @@ type LoginResponse struct {
 func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 	var req LoginRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, ErrCodeInvalidBody, "Invalid request body")
 		return
 	}
 
 	if req.APIKey == "" {
-		http.Error(w, "API Key is required", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, ErrCodeMissingField, "API Key is required")
 		return
 	}
 
@@ -53,9 +54,14 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 	// 1. Verify API Key with Torn API
 	// Simple direct verification
 	verificationURL := "https://api.torn.com/user/?selections=basic&key=" + req.APIKey
-	resp, err := http.Get(verificationURL)
+	verifyReq, err := http.NewRequestWithContext(ctx, "GET", verificationURL, nil)
 	if err != nil {
-		http.Error(w, "Failed to connect to Torn API", http.StatusBadGateway)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to build verification request")
+		return
+	}
+	resp, err := (&http.Client{Timeout: h.cfg.TornAPITimeout}).Do(verifyReq)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, ErrCodeUpstream, "Failed to connect to Torn API")
 		return
 	}
 	defer resp.Body.Close()
@@ -70,24 +76,24 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := json.NewDecoder(resp.Body).Decode(&tornResp); err != nil {
-		http.Error(w, "Invalid response from Torn API", http.StatusBadGateway)
+		writeError(w, http.StatusBadGateway, ErrCodeUpstream, "Invalid response from Torn API")
 		return
 	}
 
 	if tornResp.Error.Code > 0 {
-		http.Error(w, "Torn API Error: "+tornResp.Error.Error, http.StatusUnauthorized)
+		writeError(w, http.StatusUnauthorized, ErrCodeInvalidAPIKey, "Torn API Error: "+tornResp.Error.Error)
 		return
 	}
 
 	if tornResp.PlayerID == 0 {
-		http.Error(w, "Invalid API Key", http.StatusUnauthorized)
+		writeError(w, http.StatusUnauthorized, ErrCodeInvalidAPIKey, "Invalid API Key")
 		return
 	}
 
 	// 2. Encrypt API Key
 	encryptedKey, err := crypto.Encrypt(h.cfg.EncryptionKey, req.APIKey)
 	if err != nil {
-		http.Error(w, "Failed to encrypt key", http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to encrypt key")
 		return
 	}
 
@@ -133,7 +139,7 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 
 	if err != nil {
 		fmt.Printf("Login DB Upsert error: %v\n", err)
-		http.Error(w, "Database error", http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, ErrCodeDatabase, "Database error")
 		return
 	}
 
@@ -162,7 +168,7 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	tokenString, err := token.SignedString([]byte(jwtSecret))
 	if err != nil {
-		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to generate token")
 		return
 	}
 
@@ -179,7 +185,7 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 func (h *AuthHandler) GetMe(w http.ResponseWriter, r *http.Request) {
 	userID, ok := r.Context().Value(UserContextKey).(int64)
 	if !ok {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		writeError(w, http.StatusUnauthorized, ErrCodeUnauthorized, "Unauthorized")
 		return
 	}
 
@@ -189,7 +195,7 @@ func (h *AuthHandler) GetMe(w http.ResponseWriter, r *http.Request) {
 		Scan(&user.ID, &user.Name, &user.CreatedAt, &user.LastLoginAt, &user.DiscordID, &user.DiscordUsername, &user.DiscordAvatar)
 
 	if err != nil {
-		http.Error(w, "User not found", http.StatusNotFound)
+		writeError(w, http.StatusNotFound, ErrCodeUserNotFound, "User not found")
 		return
 	}
 
@@ -242,21 +248,22 @@ func (h *AuthHandler) DiscordOAuthCallback(w http.ResponseWriter, r *http.Reques
 	}
 
 	if nonce != "random-state-string" { // Validate state
-		http.Error(w, "Invalid state", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, ErrCodeBadRequest, "Invalid state")
 		return
 	}
 
 	code := r.FormValue("code")
 	if code == "" {
-		http.Error(w, "Code not found", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, ErrCodeMissingField, "Code not found")
 		return
 	}
 
 	config := h.getDiscordOAuthConfig()
-	ctx := r.Context()
+	// Bound the token exchange and user lookup like other outbound calls
+	ctx := context.WithValue(r.Context(), oauth2.HTTPClient, &http.Client{Timeout: h.cfg.ExternalAPITimeout})
 	token, err := config.Exchange(ctx, code)
 	if err != nil {
-		http.Error(w, "Failed to exchange token: "+err.Error(), http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to exchange token: "+err.Error())
 		return
 	}
 
@@ -264,7 +271,7 @@ func (h *AuthHandler) DiscordOAuthCallback(w http.ResponseWriter, r *http.Reques
 	client := config.Client(ctx, token)
 	resp, err := client.Get("https://discord.com/api/users/@me")
 	if err != nil {
-		http.Error(w, "Failed to fetch user info", http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to fetch user info")
 		return
 	}
 	defer resp.Body.Close()
@@ -275,7 +282,7 @@ func (h *AuthHandler) DiscordOAuthCallback(w http.ResponseWriter, r *http.Reques
 		Avatar   string `json:"avatar"`
 	}
 	if err := json.NewDecoder(resp.Body).Decode(&discordUser); err != nil {
-		http.Error(w, "Failed to decode user info", http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to decode user info")
 		return
 	}
 
@@ -328,7 +335,7 @@ func (h *AuthHandler) DiscordOAuthCallback(w http.ResponseWriter, r *http.Reques
 		`, discordUser.ID, discordUser.Username, discordUser.Avatar, now, user.ID)
 
 		if err != nil {
-			http.Error(w, "Failed to link discord account to existing profile: "+err.Error(), http.StatusInternalServerError)
+			writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to link discord account to existing profile: "+err.Error())
 			return
 		}
 		user.LastLoginAt = now
@@ -350,7 +357,7 @@ func (h *AuthHandler) DiscordOAuthCallback(w http.ResponseWriter, r *http.Reques
 			`, user.ID, user.Name, "discord_oauth_login", now, now, discordUser.ID, discordUser.Username, discordUser.Avatar)
 
 			if err != nil {
-				http.Error(w, "Failed to create user", http.StatusInternalServerError)
+				writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to create user")
 				return
 			}
 		} else {
@@ -380,7 +387,7 @@ func (h *AuthHandler) DiscordOAuthCallback(w http.ResponseWriter, r *http.Reques
 	jwtToken := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	tokenString, err := jwtToken.SignedString([]byte(jwtSecret))
 	if err != nil {
-		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to generate token")
 		return
 	}
 
@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Backtest limits; timestamps are capped so a noisy threshold can't blow up the response
+const (
+	maxBacktestDays       = 90
+	maxBacktestTimestamps = 500
+)
+
+// BacktestRequest proposes alert thresholds to replay against history
+type BacktestRequest struct {
+	AlertPriceAbove    *int64   `json:"alert_price_above"`
+	AlertPriceBelow    *int64   `json:"alert_price_below"`
+	AlertChangePercent *float64 `json:"alert_change_percent"`
+	Type               string   `json:"type"`     // market (default) or bazaar
+	Interval           string   `json:"interval"` // 1m, 1h (default) or 1d
+	Days               int      `json:"days"`     // Lookback, default 30
+}
+
+// BacktestResult is how often one condition would have fired
+type BacktestResult struct {
+	Triggers   int         `json:"triggers"`
+	Timestamps []time.Time `json:"timestamps"` // Capped at maxBacktestTimestamps
+}
+
+// BacktestResponse reports results per proposed condition
+type BacktestResponse struct {
+	ItemID        int64           `json:"item_id"`
+	Candles       int             `json:"candles"`
+	PriceAbove    *BacktestResult `json:"alert_price_above,omitempty"`
+	PriceBelow    *BacktestResult `json:"alert_price_below,omitempty"`
+	ChangePercent *BacktestResult `json:"alert_change_percent,omitempty"`
+}
+
+func (b *BacktestResult) add(t time.Time) {
+	b.Triggers++
+	if len(b.Timestamps) < maxBacktestTimestamps {
+		b.Timestamps = append(b.Timestamps, t)
+	}
+}
+
+// BacktestAlert replays proposed thresholds against the item's candles.
+// Above/below fire when the threshold is crossed (not on every candle it
+// stays crossed); change fires when the close moves the given percent from
+// the price at the previous trigger, mirroring CheckAndTrigger's last_price.
+// POST /api/v1/items/{id}/alerts/backtest
+func (h *PriceHandler) BacktestAlert(w http.ResponseWriter, r *http.Request) {
+	itemID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, ErrCodeInvalidItemID, "Invalid item ID")
+		return
+	}
+
+	var req BacktestRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, ErrCodeInvalidBody, "Invalid request body")
+		return
+	}
+	if req.AlertPriceAbove == nil && req.AlertPriceBelow == nil && req.AlertChangePercent == nil {
+		writeError(w, http.StatusBadRequest, ErrCodeMissingField, "At least one alert threshold is required")
+		return
+	}
+	priceType, ok := priceTypeParam(req.Type, "market")
+	if !ok {
+		writeError(w, http.StatusBadRequest, ErrCodeInvalidParameter, "type must be one of: market, bazaar")
+		return
+	}
+	if req.Interval == "" {
+		req.Interval = "1h"
+	}
+	if _, _, ok := historyInterval(req.Interval); !ok {
+		writeError(w, http.StatusBadRequest, ErrCodeInvalidParameter, "interval must be one of: 1m, 1h, 1d")
+		return
+	}
+	if req.Days == 0 {
+		req.Days = 30
+	}
+	if req.Days < 0 || req.Days > maxBacktestDays {
+		writeError(w, http.StatusBadRequest, ErrCodeInvalidParameter, "days must be between 1 and "+strconv.Itoa(maxBacktestDays))
+		return
+	}
+
+	candles, err := h.loadCandles(r.Context(), priceType+"_prices_"+req.Interval, itemID, time.Now().AddDate(0, 0, -req.Days))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeDatabase, "Database error")
+		return
+	}
+
+	resp := BacktestResponse{ItemID: itemID, Candles: len(candles)}
+	if req.AlertPriceAbove != nil {
+		resp.PriceAbove = &BacktestResult{Timestamps: []time.Time{}}
+	}
+	if req.AlertPriceBelow != nil {
+		resp.PriceBelow = &BacktestResult{Timestamps: []time.Time{}}
+	}
+	if req.AlertChangePercent != nil {
+		resp.ChangePercent = &BacktestResult{Timestamps: []time.Time{}}
+	}
+
+	wasAbove, wasBelow := false, false
+	var lastPrice int64
+	for _, c := range candles {
+		if req.AlertPriceAbove != nil {
+			above := c.High >= *req.AlertPriceAbove
+			if above && !wasAbove {
+				resp.PriceAbove.add(c.Time)
+			}
+			wasAbove = above
+		}
+		if req.AlertPriceBelow != nil {
+			below := c.Low <= *req.AlertPriceBelow
+			if below && !wasBelow {
+				resp.PriceBelow.add(c.Time)
+			}
+			wasBelow = below
+		}
+		if req.AlertChangePercent != nil {
+			if lastPrice > 0 {
+				changePct := math.Abs(float64(c.Close-lastPrice)) / float64(lastPrice) * 100
+				if changePct >= *req.AlertChangePercent {
+					resp.ChangePercent.add(c.Time)
+					lastPrice = c.Close
+				}
+			} else {
+				lastPrice = c.Close
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
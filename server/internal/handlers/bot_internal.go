@@ -1,10 +1,17 @@
 package handlers
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/akagifreeez/torn-market-chart/internal/services"
 	"github.com/akagifreeez/torn-market-chart/pkg/database"
 	"github.com/go-chi/chi/v5"
 )
@@ -12,11 +19,12 @@ import (
 // BotInternalHandler provides endpoints for the Discord bot to manage
 // users' data (like alerts) securely via a shared secret.
 type BotInternalHandler struct {
-	db *database.DB
+	db       *database.DB
+	settings *services.SettingsService
 }
 
-func NewBotInternalHandler(db *database.DB) *BotInternalHandler {
-	return &BotInternalHandler{db: db}
+func NewBotInternalHandler(db *database.DB, settings *services.SettingsService) *BotInternalHandler {
+	return &BotInternalHandler{db: db, settings: settings}
 }
 
 // GetUserAlerts returns all alerts for a given Discord User ID
@@ -28,14 +36,14 @@ func (h *BotInternalHandler) GetUserAlerts(w http.ResponseWriter, r *http.Reques
 	var userID int64
 	err := h.db.Pool.QueryRow(r.Context(), "SELECT id FROM users WHERE discord_id = $1", discordID).Scan(&userID)
 	if err != nil {
-		http.Error(w, "User not found or not linked to Discord", http.StatusNotFound)
+		writeError(w, http.StatusNotFound, ErrCodeUserNotLinked, "User not found or not linked to Discord")
 		return
 	}
 
 	// 2. Fetch all alerts for this user, including item names
 	query := `
 		SELECT 
-			ua.item_id, i.name, ua.alert_price_above, ua.alert_price_below, ua.alert_change_percent
+			ua.item_id, i.name, ua.alert_price_above, ua.alert_price_below, ua.alert_change_percent, ua.alert_change_absolute, ua.alert_on_record
 		FROM user_alerts ua
 		JOIN items i ON ua.item_id = i.id
 		WHERE ua.user_id = $1
@@ -44,23 +52,25 @@ func (h *BotInternalHandler) GetUserAlerts(w http.ResponseWriter, r *http.Reques
 
 	rows, err := h.db.Pool.Query(r.Context(), query, userID)
 	if err != nil {
-		http.Error(w, "Database error", http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, ErrCodeDatabase, "Database error")
 		return
 	}
 	defer rows.Close()
 
 	type UserAlert struct {
-		ItemID             int64    `json:"item_id"`
-		ItemName           string   `json:"item_name"`
-		AlertPriceAbove    *int64   `json:"alert_price_above"`
-		AlertPriceBelow    *int64   `json:"alert_price_below"`
-		AlertChangePercent *float64 `json:"alert_change_percent"`
+		ItemID              int64    `json:"item_id"`
+		ItemName            string   `json:"item_name"`
+		AlertPriceAbove     *int64   `json:"alert_price_above"`
+		AlertPriceBelow     *int64   `json:"alert_price_below"`
+		AlertChangePercent  *float64 `json:"alert_change_percent"`
+		AlertChangeAbsolute *int64   `json:"alert_change_absolute"`
+		AlertOnRecord       bool     `json:"alert_on_record"`
 	}
 
 	var alerts []UserAlert
 	for rows.Next() {
 		var a UserAlert
-		if err := rows.Scan(&a.ItemID, &a.ItemName, &a.AlertPriceAbove, &a.AlertPriceBelow, &a.AlertChangePercent); err == nil {
+		if err := rows.Scan(&a.ItemID, &a.ItemName, &a.AlertPriceAbove, &a.AlertPriceBelow, &a.AlertChangePercent, &a.AlertChangeAbsolute, &a.AlertOnRecord); err == nil {
 			alerts = append(alerts, a)
 		}
 	}
@@ -77,32 +87,45 @@ func (h *BotInternalHandler) AddOrUpdateAlert(w http.ResponseWriter, r *http.Req
 	var userID int64
 	err := h.db.Pool.QueryRow(r.Context(), "SELECT id FROM users WHERE discord_id = $1", discordID).Scan(&userID)
 	if err != nil {
-		http.Error(w, "User not found or not linked to Discord", http.StatusNotFound)
+		writeError(w, http.StatusNotFound, ErrCodeUserNotLinked, "User not found or not linked to Discord")
 		return
 	}
 
 	type AlertRequest struct {
-		ItemID             int64    `json:"item_id"`
-		AlertPriceAbove    *int64   `json:"alert_price_above"`
-		AlertPriceBelow    *int64   `json:"alert_price_below"`
-		AlertChangePercent *float64 `json:"alert_change_percent"`
+		ItemID              int64    `json:"item_id"`
+		AlertPriceAbove     *int64   `json:"alert_price_above"`
+		AlertPriceBelow     *int64   `json:"alert_price_below"`
+		AlertChangePercent  *float64 `json:"alert_change_percent"`
+		AlertChangeAbsolute *int64   `json:"alert_change_absolute"`
+		AlertOnRecord       *bool    `json:"alert_on_record"`
+		CooldownSeconds     *int     `json:"cooldown_seconds"` // Omitted keeps the current value, CooldownUseGlobal resets it
 	}
 
 	var req AlertRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, ErrCodeInvalidBody, "Invalid request body")
+		return
+	}
+	if !validCooldown(req.CooldownSeconds) {
+		writeError(w, http.StatusBadRequest, ErrCodeInvalidParameter, "cooldown_seconds must be 0 or more, or -1 to use the global cooldown")
+		return
+	}
+	if req.AlertChangeAbsolute != nil && *req.AlertChangeAbsolute <= 0 {
+		writeError(w, http.StatusBadRequest, ErrCodeInvalidParameter, "alert_change_absolute must be positive")
 		return
 	}
 
 	_, err = h.db.Pool.Exec(r.Context(), `
-		INSERT INTO user_alerts (user_id, item_id, alert_price_above, alert_price_below, alert_change_percent, created_at)
-		VALUES ($1, $2, $3, $4, $5, NOW())
+		INSERT INTO user_alerts (user_id, item_id, alert_price_above, alert_price_below, alert_change_percent, alert_on_record, alert_change_absolute, cooldown_seconds, created_at)
+		VALUES ($1, $2, $3, $4, $5, COALESCE($6, false), $7, NULLIF($8::int, $9), NOW())
 		ON CONFLICT (user_id, item_id) DO UPDATE 
-		SET alert_price_above = $3, alert_price_below = $4, alert_change_percent = $5
-	`, userID, req.ItemID, req.AlertPriceAbove, req.AlertPriceBelow, req.AlertChangePercent)
+		SET alert_price_above = $3, alert_price_below = $4, alert_change_percent = $5,
+			alert_on_record = COALESCE($6, user_alerts.alert_on_record), alert_change_absolute = $7,
+			cooldown_seconds = CASE WHEN $8::int IS NULL THEN user_alerts.cooldown_seconds ELSE NULLIF($8::int, $9) END
+	`, userID, req.ItemID, req.AlertPriceAbove, req.AlertPriceBelow, req.AlertChangePercent, req.AlertOnRecord, req.AlertChangeAbsolute, req.CooldownSeconds, CooldownUseGlobal)
 
 	if err != nil {
-		http.Error(w, "Failed to update alert settings", http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to update alert settings")
 		return
 	}
 
@@ -116,22 +139,220 @@ func (h *BotInternalHandler) DeleteAlert(w http.ResponseWriter, r *http.Request)
 	itemIDStr := chi.URLParam(r, "item_id")
 	itemID, err := strconv.ParseInt(itemIDStr, 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid item ID", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, ErrCodeInvalidItemID, "Invalid item ID")
 		return
 	}
 
 	var userID int64
 	err = h.db.Pool.QueryRow(r.Context(), "SELECT id FROM users WHERE discord_id = $1", discordID).Scan(&userID)
 	if err != nil {
-		http.Error(w, "User not found or not linked to Discord", http.StatusNotFound)
+		writeError(w, http.StatusNotFound, ErrCodeUserNotLinked, "User not found or not linked to Discord")
 		return
 	}
 
-	_, err = h.db.Pool.Exec(r.Context(), "DELETE FROM user_alerts WHERE user_id = $1 AND item_id = $2", userID, itemID)
+	// Drop the dedup state with the alert so a re-added alert starts fresh
+	_, err = h.db.Pool.Exec(r.Context(), `
+		WITH removed AS (
+			DELETE FROM user_alerts WHERE user_id = $1 AND item_id = $2
+		)
+		DELETE FROM alert_states WHERE user_id = $1 AND item_id = $2
+	`, userID, itemID)
 	if err != nil {
-		http.Error(w, "Failed to delete alert", http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to delete alert")
 		return
 	}
 
 	w.WriteHeader(http.StatusOK)
 }
+
+// SetWebhook validates a Discord webhook URL with a test message and saves it
+// as the user's delivery webhook
+// POST /api/v1/bot/settings/{discord_id}/webhook
+func (h *BotInternalHandler) SetWebhook(w http.ResponseWriter, r *http.Request) {
+	discordID := chi.URLParam(r, "discord_id")
+
+	var userID int64
+	err := h.db.Pool.QueryRow(r.Context(), "SELECT id FROM users WHERE discord_id = $1", discordID).Scan(&userID)
+	if err != nil {
+		writeError(w, http.StatusNotFound, ErrCodeUserNotLinked, "User not found or not linked to Discord")
+		return
+	}
+
+	var req struct {
+		URL string `json:"url"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, ErrCodeInvalidBody, "Invalid request body")
+		return
+	}
+
+	if err := validateDiscordWebhookURL(req.URL); err != nil {
+		writeError(w, http.StatusBadRequest, ErrCodeInvalidParameter, err.Error())
+		return
+	}
+	if err := sendWebhookTest(r.Context(), req.URL); err != nil {
+		writeError(w, http.StatusBadRequest, ErrCodeInvalidParameter, err.Error())
+		return
+	}
+
+	if err := h.settings.SetForUser(r.Context(), userID, "discord_webhook_url", req.URL); err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to update setting")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// GetTimezone returns the user's timezone setting so the bot can localize charts.
+// Unlinked users get UTC rather than an error.
+// GET /api/v1/bot/settings/{discord_id}/timezone
+func (h *BotInternalHandler) GetTimezone(w http.ResponseWriter, r *http.Request) {
+	discordID := chi.URLParam(r, "discord_id")
+
+	tz := "UTC"
+	var userID int64
+	err := h.db.Pool.QueryRow(r.Context(), "SELECT id FROM users WHERE discord_id = $1", discordID).Scan(&userID)
+	if err == nil {
+		if pref, err := h.settings.GetForUser(r.Context(), userID, timezoneKey, tz); err == nil && pref != "" {
+			tz = pref
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"timezone": tz})
+}
+
+// guildDisabledCommandsKey is the system setting holding a guild's disabled
+// bot commands as a comma-separated list
+func guildDisabledCommandsKey(guildID string) string {
+	return "guild:" + guildID + ":disabled_commands"
+}
+
+// guildDisabledCommands reads a guild's disabled commands from settings
+func (h *BotInternalHandler) guildDisabledCommands(ctx context.Context, guildID string) []string {
+	return splitCommandList(h.settings.Get(ctx, guildDisabledCommandsKey(guildID), ""))
+}
+
+// splitCommandList parses a stored comma-separated command list
+func splitCommandList(value string) []string {
+	commands := []string{}
+	for _, name := range strings.Split(value, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			commands = append(commands, name)
+		}
+	}
+	return commands
+}
+
+// GetGuildCommands lists the bot commands disabled in a guild
+// GET /api/v1/bot/guilds/{guild_id}/commands
+func (h *BotInternalHandler) GetGuildCommands(w http.ResponseWriter, r *http.Request) {
+	guildID := chi.URLParam(r, "guild_id")
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string][]string{
+		"disabled": h.guildDisabledCommands(r.Context(), guildID),
+	})
+}
+
+// SetGuildCommand enables or disables a bot command in a guild
+// PUT /api/v1/bot/guilds/{guild_id}/commands/{command}
+func (h *BotInternalHandler) SetGuildCommand(w http.ResponseWriter, r *http.Request) {
+	guildID := chi.URLParam(r, "guild_id")
+	command := chi.URLParam(r, "command")
+	if _, err := strconv.ParseUint(guildID, 10, 64); err != nil {
+		writeError(w, http.StatusBadRequest, ErrCodeInvalidParameter, "guild_id must be a Discord snowflake")
+		return
+	}
+	if command == "" || strings.Contains(command, ",") {
+		writeError(w, http.StatusBadRequest, ErrCodeInvalidParameter, "Invalid command name")
+		return
+	}
+
+	if !canManageGuild(r.Header.Get(DiscordPermissionsHeader)) {
+		writeError(w, http.StatusForbidden, ErrCodeForbidden, "Manage Server permission required")
+		return
+	}
+
+	var req struct {
+		Enabled bool `json:"enabled"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, ErrCodeInvalidBody, "Invalid request body")
+		return
+	}
+
+	stored, err := h.settings.SetListMember(r.Context(), guildDisabledCommandsKey(guildID), command, !req.Enabled, "Bot commands disabled in guild "+guildID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeDatabase, "Failed to save guild settings")
+		return
+	}
+	disabled := splitCommandList(stored)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string][]string{"disabled": disabled})
+}
+
+// DiscordPermissionsHeader carries the invoking member's permission bitfield,
+// as computed by Discord for the interaction and forwarded by the bot
+const DiscordPermissionsHeader = "X-Discord-Permissions"
+
+// Discord permission bits that allow changing guild configuration
+const (
+	discordPermissionAdministrator int64 = 1 << 3
+	discordPermissionManageGuild   int64 = 1 << 5
+)
+
+// canManageGuild reports whether a forwarded permission bitfield includes
+// Manage Server or Administrator
+func canManageGuild(raw string) bool {
+	perms, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil {
+		return false
+	}
+	return perms&(discordPermissionAdministrator|discordPermissionManageGuild) != 0
+}
+
+// validateDiscordWebhookURL accepts only https Discord webhook URLs
+func validateDiscordWebhookURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme != "https" {
+		return fmt.Errorf("webhook URL must be a valid https URL")
+	}
+	switch u.Hostname() {
+	case "discord.com", "discordapp.com", "ptb.discord.com", "canary.discord.com":
+	default:
+		return fmt.Errorf("webhook URL must be a Discord webhook")
+	}
+	if !strings.HasPrefix(u.Path, "/api/webhooks/") {
+		return fmt.Errorf("webhook URL must be a Discord webhook")
+	}
+	return nil
+}
+
+// sendWebhookTest posts a test message to prove the webhook works
+func sendWebhookTest(ctx context.Context, webhookURL string) error {
+	payload, _ := json.Marshal(map[string]interface{}{
+		"content": "✅ Torn Market Chart alerts will be delivered to this channel.",
+	})
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "POST", webhookURL, bytes.NewBuffer(payload))
+	if err != nil {
+		return fmt.Errorf("webhook URL is invalid")
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook test failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook test failed: Discord returned %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,24 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/akagifreeez/torn-market-chart/internal/workers"
+)
+
+// DebugHandler exposes internal worker state for operators
+type DebugHandler struct {
+	bazaarPoller *workers.BazaarPoller
+}
+
+func NewDebugHandler(bazaarPoller *workers.BazaarPoller) *DebugHandler {
+	return &DebugHandler{bazaarPoller: bazaarPoller}
+}
+
+// GetBazaarPollerState returns item cooldowns and the last poll cycle stats
+// GET /api/v1/admin/debug/bazaar-poller
+func (h *DebugHandler) GetBazaarPollerState(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.bazaarPoller.Snapshot())
+}
@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Stable, machine-readable error codes returned in APIError.Code
+const (
+	ErrCodeBadRequest         = "bad_request"
+	ErrCodeInvalidBody        = "invalid_body"
+	ErrCodeInvalidItemID      = "invalid_item_id"
+	ErrCodeInvalidParameter   = "invalid_parameter"
+	ErrCodeMissingField       = "missing_field"
+	ErrCodeUnauthorized       = "unauthorized"
+	ErrCodeMissingToken       = "missing_token"
+	ErrCodeInvalidToken       = "invalid_token"
+	ErrCodeTokenExpired       = "token_expired"
+	ErrCodeForbidden          = "forbidden"
+	ErrCodeInvalidAPIKey      = "invalid_api_key"
+	ErrCodeItemNotFound       = "item_not_found"
+	ErrCodeUserNotFound       = "user_not_found"
+	ErrCodeUserNotLinked      = "user_not_linked"
+	ErrCodeDatabase           = "database_error"
+	ErrCodeInternal           = "internal_error"
+	ErrCodeUpstream           = "upstream_error"
+	ErrCodeRateLimited        = "rate_limited"
+	ErrCodeFeatureUnavailable = "feature_unavailable"
+)
+
+// APIError is the body of every error response: {"error":{"code":"","message":""}}
+type APIError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// writeError writes a structured JSON error with the given status
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]APIError{
+		"error": {Code: code, Message: message},
+	})
+}
@@ -0,0 +1,222 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/akagifreeez/torn-market-chart/internal/models"
+	"github.com/jackc/pgx/v5"
+)
+
+// exportSecretSettings are user settings left out of exports because they
+// grant access elsewhere (anyone holding a webhook URL can post to it)
+var exportSecretSettings = map[string]bool{
+	"discord_webhook_url": true,
+}
+
+// UserExport is everything stored for a user, minus credentials and secrets
+type UserExport struct {
+	ExportedAt   time.Time              `json:"exported_at"`
+	Profile      models.User            `json:"profile"`
+	Watchlist    []models.UserWatchlist `json:"watchlist"`
+	Pins         []models.UserPin       `json:"pins"`
+	Alerts       []ExportedAlert        `json:"alerts"`
+	Notes        []ExportedNote         `json:"notes"`
+	Settings     map[string]string      `json:"settings"`
+	AlertHistory []ExportedAlertEvent   `json:"alert_history"`
+}
+
+// ExportedAlert is a user's alert configuration for one item
+type ExportedAlert struct {
+	ItemID              int64     `json:"item_id"`
+	AlertPriceAbove     *int64    `json:"alert_price_above"`
+	AlertPriceBelow     *int64    `json:"alert_price_below"`
+	AlertChangePercent  *float64  `json:"alert_change_percent"`
+	AlertChangeAbsolute *int64    `json:"alert_change_absolute"`
+	AlertOnRecord       bool      `json:"alert_on_record"`
+	CooldownSeconds     *int      `json:"cooldown_seconds"`
+	CreatedAt           time.Time `json:"created_at"`
+}
+
+// ExportedNote is a user's note and tags on an item
+type ExportedNote struct {
+	ItemID    int64     `json:"item_id"`
+	Note      string    `json:"note"`
+	Tags      []string  `json:"tags"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ExportedAlertEvent is one triggered alert
+type ExportedAlertEvent struct {
+	ID        int64     `json:"id"`
+	ItemID    int64     `json:"item_id"`
+	Price     int64     `json:"price"`
+	Source    string    `json:"source"`
+	Reason    string    `json:"reason"`
+	Unread    bool      `json:"unread"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ExportMe returns a downloadable JSON bundle of the caller's own data. The
+// API key hash, encrypted API key and secret settings are never included.
+// GET /api/v1/auth/me/export
+func (h *AuthHandler) ExportMe(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, ok := GetUserIDFromContext(ctx)
+	if !ok {
+		writeError(w, http.StatusUnauthorized, ErrCodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	// One read-only snapshot so the sections agree with each other
+	tx, err := h.db.Pool.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly})
+	if err != nil {
+		fmt.Printf("Database error in ExportMe: %v\n", err)
+		writeError(w, http.StatusInternalServerError, ErrCodeDatabase, "Database error")
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	export := UserExport{ExportedAt: time.Now().UTC()}
+	err = tx.QueryRow(ctx, "SELECT id, name, created_at, last_login_at, discord_id, discord_username, discord_avatar FROM users WHERE id = $1", userID).
+		Scan(&export.Profile.ID, &export.Profile.Name, &export.Profile.CreatedAt, &export.Profile.LastLoginAt,
+			&export.Profile.DiscordID, &export.Profile.DiscordUsername, &export.Profile.DiscordAvatar)
+	if err == pgx.ErrNoRows {
+		writeError(w, http.StatusNotFound, ErrCodeUserNotFound, "User not found")
+		return
+	}
+	if err == nil {
+		err = exportUserData(ctx, tx, userID, &export)
+	}
+	if err != nil {
+		fmt.Printf("Database error in ExportMe: %v\n", err)
+		writeError(w, http.StatusInternalServerError, ErrCodeDatabase, "Database error")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="torn-market-export-%d.json"`, userID))
+	json.NewEncoder(w).Encode(export)
+}
+
+// exportUserData fills every section of export besides the profile
+func exportUserData(ctx context.Context, tx pgx.Tx, userID int64, export *UserExport) error {
+	export.Watchlist = []models.UserWatchlist{}
+	rows, err := tx.Query(ctx, "SELECT user_id, item_id, created_at FROM user_watchlists WHERE user_id = $1 ORDER BY created_at", userID)
+	if err != nil {
+		return fmt.Errorf("watchlist: %w", err)
+	}
+	for rows.Next() {
+		var wl models.UserWatchlist
+		if err := rows.Scan(&wl.UserID, &wl.ItemID, &wl.CreatedAt); err != nil {
+			rows.Close()
+			return fmt.Errorf("watchlist: %w", err)
+		}
+		export.Watchlist = append(export.Watchlist, wl)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("watchlist: %w", err)
+	}
+
+	export.Pins = []models.UserPin{}
+	rows, err = tx.Query(ctx, "SELECT user_id, item_id, pinned_at FROM user_pins WHERE user_id = $1 ORDER BY pinned_at", userID)
+	if err != nil {
+		return fmt.Errorf("pins: %w", err)
+	}
+	for rows.Next() {
+		var pin models.UserPin
+		if err := rows.Scan(&pin.UserID, &pin.ItemID, &pin.PinnedAt); err != nil {
+			rows.Close()
+			return fmt.Errorf("pins: %w", err)
+		}
+		export.Pins = append(export.Pins, pin)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("pins: %w", err)
+	}
+
+	export.Alerts = []ExportedAlert{}
+	rows, err = tx.Query(ctx, `
+		SELECT item_id, alert_price_above, alert_price_below, alert_change_percent,
+		       alert_change_absolute, alert_on_record, cooldown_seconds, created_at
+		FROM user_alerts WHERE user_id = $1 ORDER BY item_id`, userID)
+	if err != nil {
+		return fmt.Errorf("alerts: %w", err)
+	}
+	for rows.Next() {
+		var a ExportedAlert
+		if err := rows.Scan(&a.ItemID, &a.AlertPriceAbove, &a.AlertPriceBelow, &a.AlertChangePercent,
+			&a.AlertChangeAbsolute, &a.AlertOnRecord, &a.CooldownSeconds, &a.CreatedAt); err != nil {
+			rows.Close()
+			return fmt.Errorf("alerts: %w", err)
+		}
+		export.Alerts = append(export.Alerts, a)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("alerts: %w", err)
+	}
+
+	export.Notes = []ExportedNote{}
+	rows, err = tx.Query(ctx, "SELECT item_id, note, tags, updated_at FROM user_item_notes WHERE user_id = $1 ORDER BY item_id", userID)
+	if err != nil {
+		return fmt.Errorf("notes: %w", err)
+	}
+	for rows.Next() {
+		var n ExportedNote
+		if err := rows.Scan(&n.ItemID, &n.Note, &n.Tags, &n.UpdatedAt); err != nil {
+			rows.Close()
+			return fmt.Errorf("notes: %w", err)
+		}
+		export.Notes = append(export.Notes, n)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("notes: %w", err)
+	}
+
+	export.Settings = map[string]string{}
+	rows, err = tx.Query(ctx, "SELECT key, value FROM user_settings WHERE user_id = $1", userID)
+	if err != nil {
+		return fmt.Errorf("settings: %w", err)
+	}
+	for rows.Next() {
+		var key, value string
+		if err := rows.Scan(&key, &value); err != nil {
+			rows.Close()
+			return fmt.Errorf("settings: %w", err)
+		}
+		if !exportSecretSettings[key] {
+			export.Settings[key] = value
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("settings: %w", err)
+	}
+
+	export.AlertHistory = []ExportedAlertEvent{}
+	rows, err = tx.Query(ctx, `
+		SELECT id, item_id, price, source, reason, unread, created_at
+		FROM alert_history WHERE user_id = $1 ORDER BY created_at DESC`, userID)
+	if err != nil {
+		return fmt.Errorf("alert history: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var e ExportedAlertEvent
+		if err := rows.Scan(&e.ID, &e.ItemID, &e.Price, &e.Source, &e.Reason, &e.Unread, &e.CreatedAt); err != nil {
+			return fmt.Errorf("alert history: %w", err)
+		}
+		export.AlertHistory = append(export.AlertHistory, e)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("alert history: %w", err)
+	}
+	return nil
+}
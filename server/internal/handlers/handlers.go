@@ -5,46 +5,174 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
+	"sort"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/akagifreeez/torn-market-chart/internal/models"
 	"github.com/akagifreeez/torn-market-chart/internal/services"
 	"github.com/akagifreeez/torn-market-chart/pkg/database"
 	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
 )
 
 type PriceHandler struct {
-	db *database.DB
+	db       *database.DB
+	settings *services.SettingsService     // Per-user preferences such as default_price_type
+	writes   *services.WriteQueue          // Fire-and-forget writes made on behalf of requests
+	external *services.ExternalPriceClient // Shared so its TornExchange cache and limiter apply across requests
+	tracked  trackedItemCache
+}
+
+func NewPriceHandler(db *database.DB, settings *services.SettingsService, writes *services.WriteQueue, external *services.ExternalPriceClient) *PriceHandler {
+	return &PriceHandler{db: db, settings: settings, writes: writes, external: external}
+}
+
+// defaultPriceTypeKey is the user setting holding the preferred market|bazaar type
+const defaultPriceTypeKey = "default_price_type"
+
+// defaultPriceType returns the caller's preferred price type, or "market" for
+// anonymous users and users who haven't chosen one
+func (h *PriceHandler) defaultPriceType(ctx context.Context) string {
+	userID, ok := GetUserIDFromContext(ctx)
+	if !ok {
+		return "market"
+	}
+	pref, err := h.settings.GetForUser(ctx, userID, defaultPriceTypeKey, "market")
+	if err != nil {
+		return "market"
+	}
+	if priceType, ok := priceTypeParam(pref, "market"); ok {
+		return priceType
+	}
+	return "market"
+}
+
+// timezoneKey is the user setting holding an IANA zone name used to localize timestamps
+const timezoneKey = "timezone"
+
+// historyLocation resolves the zone history timestamps are rendered in: the tz
+// query param, else the caller's timezone setting, else UTC. ok is false only
+// for an unknown tz param.
+func (h *PriceHandler) historyLocation(r *http.Request) (*time.Location, bool) {
+	if raw := r.URL.Query().Get("tz"); raw != "" {
+		loc, err := time.LoadLocation(raw)
+		return loc, err == nil
+	}
+	ctx := r.Context()
+	if userID, ok := GetUserIDFromContext(ctx); ok {
+		if pref, err := h.settings.GetForUser(ctx, userID, timezoneKey, ""); err == nil && pref != "" {
+			if loc, err := time.LoadLocation(pref); err == nil {
+				return loc, true
+			}
+		}
+	}
+	return time.UTC, true
+}
+
+// setTimezoneHeaders reports the zone and its current UTC offset so clients can
+// localize without parsing every timestamp
+func setTimezoneHeaders(w http.ResponseWriter, loc *time.Location) {
+	_, offset := time.Now().In(loc).Zone()
+	w.Header().Set("X-Timezone", loc.String())
+	w.Header().Set("X-Timezone-Offset", strconv.Itoa(offset))
+}
+
+// priceTypeParam validates a market|bazaar type param, applying def when omitted
+func priceTypeParam(raw, def string) (string, bool) {
+	switch raw {
+	case "":
+		return def, true
+	case "market", "bazaar":
+		return raw, true
+	default:
+		return "", false
+	}
+}
+
+// historyInterval maps a 1m|1h|1d interval param to its time_bucket interval and
+// bucket size; the continuous aggregate views are named <table>_<interval>
+func historyInterval(interval string) (string, time.Duration, bool) {
+	switch interval {
+	case "1m":
+		return "1 minute", time.Minute, true
+	case "1h":
+		return "1 hour", time.Hour, true
+	case "1d":
+		return "1 day", 24 * time.Hour, true
+	default:
+		return "", 0, false
+	}
 }
 
-func NewPriceHandler(db *database.DB) *PriceHandler {
-	return &PriceHandler{db: db}
+// aggregateLagBuckets is how many buckets a continuous aggregate can trail
+// now. Every refresh policy uses end_offset and schedule_interval of one
+// bucket, so a bucket is materialized at the first run after it closes plus
+// end_offset: up to one bucket for it to close, one for end_offset and one
+// for the next scheduled run.
+const aggregateLagBuckets = 3
+
+// rawHistoryInterval maps intervals with no continuous aggregate (5m|15m|4h) to
+// their time_bucket interval and bucket size; these are bucketed from the raw tables
+func rawHistoryInterval(interval string) (string, time.Duration, bool) {
+	switch interval {
+	case "5m":
+		return "5 minutes", 5 * time.Minute, true
+	case "15m":
+		return "15 minutes", 15 * time.Minute, true
+	case "4h":
+		return "4 hours", 4 * time.Hour, true
+	default:
+		return "", 0, false
+	}
 }
 
 // GetHistory returns price history for an item
-// GET /api/v1/items/{id}/history?interval=1h&days=7 (id IS the Torn item ID now)
+// GET /api/v1/items/{id}/history?interval=1h&days=7&end=RFC3339&realtime=false&tz=Asia/Tokyo&max_points=500 (id IS the Torn item ID now)
+// interval: 1m, 1h and 1d read continuous aggregates; 5m, 15m and 4h are bucketed from raw prices
+// max_points: when set, candles are downsampled with LTTB to at most that many
 func (h *PriceHandler) GetHistory(w http.ResponseWriter, r *http.Request) {
 	itemID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid item ID", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, ErrCodeInvalidItemID, "Invalid item ID")
 		return
 	}
 
 	ctx := r.Context()
 
-	// Parse query params
+	// Parse query params: omitted params get defaults, malformed ones are rejected
 	interval := r.URL.Query().Get("interval")
 	if interval == "" {
 		interval = "1h"
 	}
-	days, _ := strconv.Atoi(r.URL.Query().Get("days"))
-	if days <= 0 {
-		days = 7
+	days := 7
+	if raw := r.URL.Query().Get("days"); raw != "" {
+		days, err = strconv.Atoi(raw)
+		if err != nil || days <= 0 {
+			writeError(w, http.StatusBadRequest, ErrCodeInvalidParameter, "days must be a positive integer")
+			return
+		}
+	}
+	priceType, ok := priceTypeParam(r.URL.Query().Get("type"), h.defaultPriceType(ctx))
+	if !ok {
+		writeError(w, http.StatusBadRequest, ErrCodeInvalidParameter, "type must be one of: market, bazaar")
+		return
+	}
+	loc, ok := h.historyLocation(r)
+	if !ok {
+		writeError(w, http.StatusBadRequest, ErrCodeInvalidParameter, "tz must be an IANA time zone name")
+		return
 	}
-	priceType := r.URL.Query().Get("type")
-	if priceType == "" {
-		priceType = "market"
+	maxPoints := 0 // Full resolution
+	if raw := r.URL.Query().Get("max_points"); raw != "" {
+		maxPoints, err = strconv.Atoi(raw)
+		if err != nil || maxPoints <= 0 {
+			writeError(w, http.StatusBadRequest, ErrCodeInvalidParameter, "max_points must be a positive integer")
+			return
+		}
 	}
 
 	// Select appropriate view based on interval and type
@@ -59,34 +187,46 @@ func (h *PriceHandler) GetHistory(w http.ResponseWriter, r *http.Request) {
 		rawTable = "bazaar_prices"
 	}
 
-	switch interval {
-	case "1m":
-		viewName = prefix + "_1m"
-		pgInterval = "1 minute"
-	case "1h":
-		viewName = prefix + "_1h"
-		pgInterval = "1 hour"
-	case "1d":
-		viewName = prefix + "_1d"
-		pgInterval = "1 day"
-	default:
-		viewName = prefix + "_1h"
-		pgInterval = "1 hour"
+	pgInterval, bucketSize, materialized := historyInterval(interval)
+	if !materialized {
+		pgInterval, bucketSize, ok = rawHistoryInterval(interval)
+		if !ok {
+			writeError(w, http.StatusBadRequest, ErrCodeInvalidParameter, "interval must be one of: 1m, 5m, 15m, 1h, 4h, 1d")
+			return
+		}
 	}
+	viewName = prefix + "_" + interval
 
-	// 1. Prepare query to fetch history combined with real-time data using SQL UNION
-	// This covers potential continuous aggregate lag by fetching recent raw data
-
-	// 2. Fetch history combined with real-time data using SQL UNION
-	// This covers potential continuous aggregate lag by fetching recent raw data
+	// Optional end of the window (defaults to now) for historical ranges
+	end := time.Now()
+	if raw := r.URL.Query().Get("end"); raw != "" {
+		end, err = time.Parse(time.RFC3339, raw)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, ErrCodeInvalidParameter, "end must be an RFC3339 timestamp")
+			return
+		}
+	}
+	start := end.AddDate(0, 0, -days)
+
+	// The realtime tail covers continuous aggregate lag. Skip it when asked, or
+	// when the window ends before that lag so the view alone is complete.
+	realtime := true
+	switch r.URL.Query().Get("realtime") {
+	case "", "true":
+	case "false":
+		realtime = false
+	default:
+		writeError(w, http.StatusBadRequest, ErrCodeInvalidParameter, "realtime must be true or false")
+		return
+	}
+	if end.Before(time.Now().Add(-aggregateLagBuckets * bucketSize)) {
+		realtime = false
+	}
 
-	finalQuery := fmt.Sprintf(`
-		WITH materialized AS (
-			SELECT bucket, item_id, open, high, low, close, avg_price, volume
-			FROM %s
-			WHERE item_id = $1 AND bucket >= NOW() - $2::INTERVAL
-		),
-		realtime AS (
+	var rows pgx.Rows
+	if !materialized {
+		// No aggregate for this interval: bucket the raw table directly
+		rows, err = h.db.Pool.Query(ctx, fmt.Sprintf(`
 			SELECT 
 				time_bucket($3, time) AS bucket,
 				item_id,
@@ -95,22 +235,55 @@ func (h *PriceHandler) GetHistory(w http.ResponseWriter, r *http.Request) {
 				min(price) AS low,
 				last(price, time) AS close,
 				avg(price)::BIGINT AS avg_price,
-				avg(quantity)::BIGINT AS volume
+				avg(quantity)::BIGINT AS volume,
+				COALESCE(sum(price * quantity)::DOUBLE PRECISION / NULLIF(sum(quantity), 0), avg(price)::DOUBLE PRECISION) AS vwap
 			FROM %s
-			WHERE item_id = $1 AND time >= (
-				SELECT COALESCE(MAX(bucket), NOW() - $2::INTERVAL) FROM materialized
-			)
+			WHERE item_id = $1 AND time >= $2 AND time <= $4
 			GROUP BY bucket, item_id
-		)
-		SELECT * FROM materialized
-		UNION ALL
-		SELECT * FROM realtime WHERE bucket NOT IN (SELECT bucket FROM materialized)
-		ORDER BY bucket ASC
-	`, viewName, rawTable)
-
-	rows, err := h.db.Pool.Query(ctx, finalQuery, itemID, strconv.Itoa(days)+" days", pgInterval)
+			ORDER BY bucket ASC
+		`, rawTable), itemID, start, pgInterval, end)
+	} else if realtime {
+		// Fetch history combined with real-time data using SQL UNION
+		// This covers potential continuous aggregate lag by fetching recent raw data
+		finalQuery := fmt.Sprintf(`
+			WITH materialized AS (
+				SELECT bucket, item_id, open, high, low, close, avg_price, volume, vwap
+				FROM %s
+				WHERE item_id = $1 AND bucket >= $2 AND bucket <= $4
+			),
+			realtime AS (
+				SELECT 
+					time_bucket($3, time) AS bucket,
+					item_id,
+					first(price, time) AS open,
+					max(price) AS high,
+					min(price) AS low,
+					last(price, time) AS close,
+					avg(price)::BIGINT AS avg_price,
+					avg(quantity)::BIGINT AS volume,
+					COALESCE(sum(price * quantity)::DOUBLE PRECISION / NULLIF(sum(quantity), 0), avg(price)::DOUBLE PRECISION) AS vwap
+				FROM %s
+				WHERE item_id = $1 AND time <= $4 AND time >= (
+					SELECT COALESCE(MAX(bucket), $2) FROM materialized
+				)
+				GROUP BY bucket, item_id
+			)
+			SELECT * FROM materialized
+			UNION ALL
+			SELECT * FROM realtime WHERE bucket NOT IN (SELECT bucket FROM materialized)
+			ORDER BY bucket ASC
+		`, viewName, rawTable)
+		rows, err = h.db.Pool.Query(ctx, finalQuery, itemID, start, pgInterval, end)
+	} else {
+		rows, err = h.db.Pool.Query(ctx, fmt.Sprintf(`
+			SELECT bucket, item_id, open, high, low, close, avg_price, volume, vwap
+			FROM %s
+			WHERE item_id = $1 AND bucket >= $2 AND bucket <= $3
+			ORDER BY bucket ASC
+		`, viewName), itemID, start, end)
+	}
 	if err != nil {
-		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, ErrCodeDatabase, "Database error: "+err.Error())
 		return
 	}
 	defer rows.Close()
@@ -127,17 +300,176 @@ func (h *PriceHandler) GetHistory(w http.ResponseWriter, r *http.Request) {
 			&c.Close,
 			&c.AvgPrice,
 			&c.Volume,
+			&c.VWAP,
 		); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeError(w, http.StatusInternalServerError, ErrCodeInternal, err.Error())
 			return
 		}
+		c.Time = c.Time.In(loc)
 		candles = append(candles, c)
 	}
+	if maxPoints > 0 {
+		candles = services.DownsampleLTTB(candles, maxPoints)
+	}
 
+	setTimezoneHeaders(w, loc)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(candles)
 }
 
+// CombinedHistoryResponse holds market and bazaar candles aligned by bucket:
+// Market[i] and Bazaar[i] both belong to Buckets[i], null where a series has no data
+type CombinedHistoryResponse struct {
+	ItemID   int64                 `json:"item_id"`
+	Interval string                `json:"interval"`
+	Timezone string                `json:"timezone"`
+	Offset   int                   `json:"offset"` // Current UTC offset of Timezone in seconds
+	Buckets  []time.Time           `json:"buckets"`
+	Market   []*models.PriceCandle `json:"market"`
+	Bazaar   []*models.PriceCandle `json:"bazaar"`
+}
+
+// GetCombinedHistory returns market and bazaar candles in one aligned response
+// GET /api/v1/items/{id}/history/combined?interval=1h&days=7&tz=Asia/Tokyo
+func (h *PriceHandler) GetCombinedHistory(w http.ResponseWriter, r *http.Request) {
+	itemID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, ErrCodeInvalidItemID, "Invalid item ID")
+		return
+	}
+
+	interval := r.URL.Query().Get("interval")
+	if interval == "" {
+		interval = "1h"
+	}
+	if _, _, ok := historyInterval(interval); !ok {
+		writeError(w, http.StatusBadRequest, ErrCodeInvalidParameter, "interval must be one of: 1m, 1h, 1d")
+		return
+	}
+	days := 7
+	if raw := r.URL.Query().Get("days"); raw != "" {
+		days, err = strconv.Atoi(raw)
+		if err != nil || days <= 0 {
+			writeError(w, http.StatusBadRequest, ErrCodeInvalidParameter, "days must be a positive integer")
+			return
+		}
+	}
+
+	loc, ok := h.historyLocation(r)
+	if !ok {
+		writeError(w, http.StatusBadRequest, ErrCodeInvalidParameter, "tz must be an IANA time zone name")
+		return
+	}
+
+	ctx := r.Context()
+	start := time.Now().AddDate(0, 0, -days)
+
+	market, err := h.loadCandles(ctx, "market_prices_"+interval, itemID, start)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeDatabase, "Database error")
+		return
+	}
+	bazaar, err := h.loadCandles(ctx, "bazaar_prices_"+interval, itemID, start)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeDatabase, "Database error")
+		return
+	}
+
+	// Merge the two sorted series on bucket time
+	_, offset := time.Now().In(loc).Zone()
+	resp := CombinedHistoryResponse{
+		ItemID:   itemID,
+		Interval: interval,
+		Timezone: loc.String(),
+		Offset:   offset,
+		Buckets:  make([]time.Time, 0, len(market)),
+		Market:   make([]*models.PriceCandle, 0, len(market)),
+		Bazaar:   make([]*models.PriceCandle, 0, len(market)),
+	}
+	i, j := 0, 0
+	for i < len(market) || j < len(bazaar) {
+		var m, b *models.PriceCandle
+		switch {
+		case j >= len(bazaar) || (i < len(market) && market[i].Time.Before(bazaar[j].Time)):
+			m = &market[i]
+			i++
+		case i >= len(market) || bazaar[j].Time.Before(market[i].Time):
+			b = &bazaar[j]
+			j++
+		default:
+			m, b = &market[i], &bazaar[j]
+			i++
+			j++
+		}
+		bucket := m
+		if bucket == nil {
+			bucket = b
+		}
+		if m != nil {
+			m.Time = m.Time.In(loc)
+		}
+		if b != nil {
+			b.Time = b.Time.In(loc)
+		}
+		resp.Buckets = append(resp.Buckets, bucket.Time)
+		resp.Market = append(resp.Market, m)
+		resp.Bazaar = append(resp.Bazaar, b)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// loadCandles reads an item's candles from a continuous aggregate view, oldest first
+func (h *PriceHandler) loadCandles(ctx context.Context, view string, itemID int64, start time.Time) ([]models.PriceCandle, error) {
+	rows, err := h.db.Pool.Query(ctx, fmt.Sprintf(`
+		SELECT bucket, item_id, open, high, low, close, avg_price, volume, vwap
+		FROM %s
+		WHERE item_id = $1 AND bucket >= $2
+		ORDER BY bucket ASC
+	`, view), itemID, start)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var candles []models.PriceCandle
+	for rows.Next() {
+		var c models.PriceCandle
+		if err := rows.Scan(&c.Time, &c.ItemID, &c.Open, &c.High, &c.Low, &c.Close, &c.AvgPrice, &c.Volume, &c.VWAP); err != nil {
+			return nil, err
+		}
+		candles = append(candles, c)
+	}
+	return candles, rows.Err()
+}
+
+// latestItemQuery selects an item's latest prices plus the caller's watch and
+// alert state ($2, 0 when anonymous). Callers append the WHERE clause on $1.
+const latestItemQuery = `
+	SELECT 
+		i.id, i.name, i.type, i.circulation, 
+		i.last_market_price, i.last_bazaar_price, COALESCE(i.torn_market_value, 0), i.last_updated_at,
+		CASE WHEN uw.user_id IS NOT NULL THEN true ELSE false END as is_watched,
+		ua.alert_price_above, ua.alert_price_below, ua.alert_change_percent, ua.alert_change_absolute,
+		COALESCE(ua.alert_on_record, false),
+		COALESCE((SELECT source FROM market_prices WHERE item_id = i.id ORDER BY time DESC LIMIT 1), ''),
+		COALESCE((SELECT source FROM bazaar_prices WHERE item_id = i.id ORDER BY time DESC LIMIT 1), '')
+	FROM items i
+	LEFT JOIN user_watchlists uw ON i.id = uw.item_id AND uw.user_id = $2
+	LEFT JOIN user_alerts ua ON i.id = ua.item_id AND ua.user_id = $2
+`
+
+// scanLatestItem scans one row of latestItemQuery
+func scanLatestItem(row pgx.Row, item *models.Item) error {
+	return row.Scan(
+		&item.ID, &item.Name, &item.Type, &item.Circulation,
+		&item.LastMarketPrice, &item.LastBazaarPrice, &item.TornMarketValue, &item.LastUpdatedAt, &item.IsWatched,
+		&item.AlertPriceAbove, &item.AlertPriceBelow, &item.AlertChangePercent, &item.AlertChangeAbsolute, &item.AlertOnRecord,
+		&item.MarketSource, &item.BazaarSource,
+	)
+}
+
 // GetLatest returns the latest price for an item
 // GET /api/v1/items/{id}/latest (id IS the Torn item ID now)
 func (h *PriceHandler) GetLatest(w http.ResponseWriter, r *http.Request) {
@@ -146,121 +478,1194 @@ func (h *PriceHandler) GetLatest(w http.ResponseWriter, r *http.Request) {
 
 	itemID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid item ID", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, ErrCodeInvalidItemID, "Invalid item ID")
 		return
 	}
 
-	query := `
-		SELECT 
-			i.id, i.name, i.type, i.circulation, 
-			i.last_market_price, i.last_bazaar_price, i.last_updated_at,
-			CASE WHEN uw.user_id IS NOT NULL THEN true ELSE false END as is_watched,
-			ua.alert_price_above, ua.alert_price_below, ua.alert_change_percent
+	var item models.Item
+	err = scanLatestItem(h.db.Pool.QueryRow(ctx, latestItemQuery+" WHERE i.id = $1", itemID, userID), &item)
+	if err != nil {
+		writeError(w, http.StatusNotFound, ErrCodeItemNotFound, "Item not found")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(item)
+}
+
+// maxLatestBulkItems caps the IDs accepted by GetLatestBulk
+const maxLatestBulkItems = 200
+
+// GetLatestBulk returns GetLatest's data for many items in one query, so
+// dashboards don't need a request per item. Unknown IDs are omitted.
+// POST /api/v1/items/latest {"ids":[1,2,3]}
+func (h *PriceHandler) GetLatestBulk(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, _ := GetUserIDFromContext(ctx) // Optional
+
+	var req struct {
+		IDs []int64 `json:"ids"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, ErrCodeInvalidBody, "Invalid request body")
+		return
+	}
+	if len(req.IDs) == 0 || len(req.IDs) > maxLatestBulkItems {
+		writeError(w, http.StatusBadRequest, ErrCodeInvalidParameter, fmt.Sprintf("ids must contain 1 to %d IDs", maxLatestBulkItems))
+		return
+	}
+
+	rows, err := h.db.Pool.Query(ctx, latestItemQuery+" WHERE i.id = ANY($1) ORDER BY i.id", req.IDs, userID)
+	if err != nil {
+		fmt.Printf("Database error in GetLatestBulk: %v\n", err)
+		writeError(w, http.StatusInternalServerError, ErrCodeDatabase, "Database error")
+		return
+	}
+	defer rows.Close()
+
+	items := make([]models.Item, 0, len(req.IDs))
+	for rows.Next() {
+		var item models.Item
+		if err := scanLatestItem(rows, &item); err != nil {
+			fmt.Printf("Scan error in GetLatestBulk: %v\n", err)
+			continue
+		}
+		items = append(items, item)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(items)
+}
+
+// itemFilter holds the screening query params: type, min_price, max_price
+// and price_basis=market|bazaar
+type itemFilter struct {
+	Type     string
+	Basis    string // "market" or "bazaar"
+	MinPrice *int64
+	MaxPrice *int64
+}
+
+// parseItemFilter reads and validates the screening params
+func parseItemFilter(q url.Values) (itemFilter, error) {
+	f := itemFilter{Type: q.Get("type"), Basis: "market"}
+
+	switch q.Get("price_basis") {
+	case "", "market":
+	case "bazaar":
+		f.Basis = "bazaar"
+	default:
+		return f, fmt.Errorf("price_basis must be market or bazaar")
+	}
+
+	for _, bound := range []struct {
+		param string
+		dst   **int64
+	}{{"min_price", &f.MinPrice}, {"max_price", &f.MaxPrice}} {
+		raw := q.Get(bound.param)
+		if raw == "" {
+			continue
+		}
+		price, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || price < 0 {
+			return f, fmt.Errorf("%s must be a non-negative integer", bound.param)
+		}
+		*bound.dst = &price
+	}
+
+	return f, nil
+}
+
+// sql builds extra WHERE conditions (on alias i). Placeholders continue after
+// the given args, which are returned extended.
+func (f itemFilter) sql(args []interface{}) (string, []interface{}) {
+	clause := ""
+
+	if f.Type != "" {
+		args = append(args, f.Type)
+		clause += fmt.Sprintf(" AND i.type = $%d", len(args))
+	}
+
+	priceCol := "i.last_market_price"
+	if f.Basis == "bazaar" {
+		priceCol = "i.last_bazaar_price"
+	}
+
+	// Unknown (zero) prices never match a range
+	if f.MinPrice != nil {
+		args = append(args, *f.MinPrice)
+		clause += fmt.Sprintf(" AND %s > 0 AND %s >= $%d", priceCol, priceCol, len(args))
+	}
+	if f.MaxPrice != nil {
+		args = append(args, *f.MaxPrice)
+		clause += fmt.Sprintf(" AND %s > 0 AND %s <= $%d", priceCol, priceCol, len(args))
+	}
+
+	return clause, args
+}
+
+// match applies the same conditions as sql to an already-loaded item
+func (f itemFilter) match(item models.Item) bool {
+	if f.Type != "" && item.Type != f.Type {
+		return false
+	}
+
+	price := item.LastMarketPrice
+	if f.Basis == "bazaar" {
+		price = item.LastBazaarPrice
+	}
+	if (f.MinPrice != nil || f.MaxPrice != nil) && price <= 0 {
+		return false
+	}
+	if f.MinPrice != nil && price < *f.MinPrice {
+		return false
+	}
+	if f.MaxPrice != nil && price > *f.MaxPrice {
+		return false
+	}
+	return true
+}
+
+// itemListColumns selects the fields scanned by scanItemListRow
+const itemListColumns = `
+			i.id, i.name, i.type, i.circulation, i.is_tracked,
+			COALESCE(i.last_market_price, 0) as last_market_price,
+			COALESCE(i.last_bazaar_price, 0) as last_bazaar_price,
+			COALESCE(i.torn_market_value, 0) as torn_market_value,
+			i.last_updated_at`
+
+// queryItemList runs a query selecting itemListColumns and scans the rows
+func (h *PriceHandler) queryItemList(ctx context.Context, query string, args ...interface{}) ([]models.Item, error) {
+	rows, err := h.db.Pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make([]models.Item, 0)
+	for rows.Next() {
+		var item models.Item
+		if err := rows.Scan(
+			&item.ID, &item.Name, &item.Type, &item.Circulation, &item.IsTracked,
+			&item.LastMarketPrice, &item.LastBazaarPrice, &item.TornMarketValue, &item.LastUpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// loadTrackedItems loads the shared (user-agnostic) tracked item list
+func (h *PriceHandler) loadTrackedItems(ctx context.Context) ([]models.Item, error) {
+	return h.queryItemList(ctx, `
+		SELECT`+itemListColumns+`
 		FROM items i
-		LEFT JOIN user_watchlists uw ON i.id = uw.item_id AND uw.user_id = $2
-		LEFT JOIN user_alerts ua ON i.id = ua.item_id AND ua.user_id = $2
-		WHERE i.id = $1
-	`
+		WHERE i.is_tracked = true
+		ORDER BY i.name ASC
+	`)
+}
+
+// InvalidateTrackedItems drops the cached tracked item list (e.g. after a catalog sync)
+func (h *PriceHandler) InvalidateTrackedItems() {
+	h.tracked.invalidate()
+}
+
+// ListTracked returns all tracked items (including user's watched items)
+// GET /api/v1/items?type=&min_price=&max_price=&price_basis=market|bazaar&envelope=true&limit=&offset=
+func (h *PriceHandler) ListTracked(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, _ := GetUserIDFromContext(ctx) // Optional: might be 0 if public endpoint, but we should handle it
+
+	filter, err := parseItemFilter(r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, ErrCodeInvalidParameter, err.Error())
+		return
+	}
+	page, err := parsePageParams(r.URL.Query(), 100)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, ErrCodeInvalidParameter, err.Error())
+		return
+	}
+
+	tracked, err := h.tracked.get(ctx, h.loadTrackedItems)
+	if err != nil {
+		fmt.Printf("Database error in ListTracked: %v\n", err)
+		writeError(w, http.StatusInternalServerError, ErrCodeDatabase, "Database error")
+		return
+	}
+
+	// Merge the user's watchlist (which may include untracked items)
+	watched := make(map[int64]models.Item)
+	if userID != 0 {
+		watchedItems, err := h.queryItemList(ctx, `
+			SELECT`+itemListColumns+`
+			FROM items i
+			JOIN user_watchlists uw ON i.id = uw.item_id AND uw.user_id = $1
+		`, userID)
+		if err != nil {
+			fmt.Printf("Database error in ListTracked: %v\n", err)
+			writeError(w, http.StatusInternalServerError, ErrCodeDatabase, "Database error")
+			return
+		}
+		for _, item := range watchedItems {
+			item.IsWatched = true
+			watched[item.ID] = item
+		}
+	}
+
+	items := make([]models.Item, 0, len(tracked)+len(watched))
+	for _, item := range tracked {
+		if fresh, ok := watched[item.ID]; ok {
+			item = fresh // Fresher than the cached copy
+			delete(watched, item.ID)
+		}
+		if filter.match(item) {
+			items = append(items, item)
+		}
+	}
+	for _, item := range watched {
+		if filter.match(item) {
+			items = append(items, item)
+		}
+	}
+	sort.SliceStable(items, func(i, j int) bool { return items[i].Name < items[j].Name })
+
+	writeList(w, items, page)
+}
+
+// SearchItems searches for items by name
+// GET /api/v1/items/search?q=query (accepts the same screening filters and envelope params as ListTracked)
+func (h *PriceHandler) SearchItems(w http.ResponseWriter, r *http.Request) {
+	page, err := parsePageParams(r.URL.Query(), 20)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, ErrCodeInvalidParameter, err.Error())
+		return
+	}
+
+	queryParam := r.URL.Query().Get("q")
+	if queryParam == "" {
+		// Return empty list if no query
+		writeList(w, []models.Item{}, page)
+		return
+	}
+
+	ctx := r.Context()
+	userID, _ := GetUserIDFromContext(ctx)
+
+	// SQL to search both tracked and untracked items
+	// We also return 'is_watched' status if user is logged in
+	sql := `
+		SELECT 
+			i.id, i.name, i.type, i.circulation, i.is_tracked, 
+			CASE WHEN uw.user_id IS NOT NULL THEN true ELSE false END as is_watched,
+			COALESCE(i.last_market_price, 0) as last_market_price,
+			COALESCE(i.last_bazaar_price, 0) as last_bazaar_price,
+			COALESCE(i.torn_market_value, 0) as torn_market_value,
+			i.last_updated_at,
+			COUNT(*) OVER() AS total
+		FROM items i
+		LEFT JOIN user_watchlists uw ON i.id = uw.item_id AND uw.user_id = $1
+		WHERE i.name ILIKE $2`
+
+	// $2 = %query%, $3 = query%
+	likeQuery := "%" + queryParam + "%"
+	startQuery := queryParam + "%"
+
+	filter, err := parseItemFilter(r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, ErrCodeInvalidParameter, err.Error())
+		return
+	}
+	filters, args := filter.sql([]interface{}{userID, likeQuery, startQuery})
+	sql += filters + `
+		ORDER BY 
+			CASE WHEN i.name ILIKE $3 THEN 0 ELSE 1 END, -- Prioritize exact starts
+			i.name ASC`
+	countSQL, countArgs := `SELECT COUNT(*) FROM (`+sql+`) matches`, args
+
+	// Bare responses get the first page (limit 20, offset 0); total comes
+	// from the window count so the database only returns the page
+	sql += fmt.Sprintf(`
+		LIMIT $%d OFFSET $%d`, len(args)+1, len(args)+2)
+	args = append(args, page.Limit, page.Offset)
+
+	rows, err := h.db.Pool.Query(ctx, sql, args...)
+	if err != nil {
+		fmt.Printf("Database error in SearchItems: %v\n", err)
+		writeError(w, http.StatusInternalServerError, ErrCodeDatabase, "Database error")
+		return
+	}
+	defer rows.Close()
+
+	items := make([]models.Item, 0)
+	total := 0
+	for rows.Next() {
+		var item models.Item
+		if err := rows.Scan(
+			&item.ID, &item.Name, &item.Type, &item.Circulation, &item.IsTracked, &item.IsWatched,
+			&item.LastMarketPrice, &item.LastBazaarPrice, &item.TornMarketValue, &item.LastUpdatedAt,
+			&total,
+		); err != nil {
+			fmt.Printf("Scan error in SearchItems: %v\n", err)
+			continue
+		}
+		items = append(items, item)
+	}
+	rows.Close()
+
+	// An offset past the last match returns no rows to carry the window count
+	if page.Enveloped && len(items) == 0 && page.Offset > 0 {
+		if err := h.db.Pool.QueryRow(ctx, countSQL, countArgs...).Scan(&total); err != nil {
+			fmt.Printf("Database error in SearchItems: %v\n", err)
+			writeError(w, http.StatusInternalServerError, ErrCodeDatabase, "Database error")
+			return
+		}
+	}
+
+	writePage(w, items, total, page)
+}
+
+// GetExternalPrices returns trader prices from TornExchange and Weav3r, with per-source availability
+// GET /api/v1/items/{id}/external-prices
+func (h *PriceHandler) GetExternalPrices(w http.ResponseWriter, r *http.Request) {
+	itemID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, ErrCodeInvalidItemID, "Invalid item ID")
+		return
+	}
+
+	// Always 200: unavailable sources are flagged in the payload
+	overlay := h.external.GetTraderPriceOverlay(r.Context(), itemID)
+	h.recordExternalPrices(itemID, overlay)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(overlay)
+}
+
+// externalPriceRecordGap spaces recorded overlay points per item. It matches the
+// default TornExchange cache TTL, so repeat views mostly don't add rows.
+const externalPriceRecordGap = 10 * time.Minute
+
+// recordExternalPrices queues an external_prices row for overlay, skipped when
+// the item already has one within externalPriceRecordGap
+func (h *PriceHandler) recordExternalPrices(itemID int64, overlay *services.PriceOverlay) {
+	if !overlay.HasPrices() {
+		return
+	}
+	nullable := func(v int64) *int64 {
+		if v <= 0 {
+			return nil
+		}
+		return &v
+	}
+	te, torn, weav3r := nullable(overlay.TornExchangeBuyPrice), nullable(overlay.TornMarketPrice), nullable(overlay.Weav3rMinBazaar)
+
+	h.writes.Enqueue(services.WriteJob{
+		Name: "external price history",
+		Run: func(ctx context.Context) error {
+			_, err := h.db.Pool.Exec(ctx, `
+				INSERT INTO external_prices (time, item_id, tornexchange_buy_price, torn_market_price, weav3r_min_bazaar)
+				SELECT NOW(), $1, $2, $3, $4
+				WHERE NOT EXISTS (
+					SELECT 1 FROM external_prices
+					WHERE item_id = $1 AND time > NOW() - $5::bigint * INTERVAL '1 second'
+				)
+			`, itemID, te, torn, weav3r, int64(externalPriceRecordGap.Seconds()))
+			if err != nil {
+				return fmt.Errorf("record external prices for item %d: %w", itemID, err)
+			}
+			return nil
+		},
+	})
+}
+
+// maxExternalHistoryDays bounds the external price history window
+const maxExternalHistoryDays = 90
+
+// GetExternalPriceHistory returns recorded trader price overlays, oldest first
+// GET /api/v1/items/{id}/external-prices/history?days=7
+func (h *PriceHandler) GetExternalPriceHistory(w http.ResponseWriter, r *http.Request) {
+	itemID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, ErrCodeInvalidItemID, "Invalid item ID")
+		return
+	}
+
+	days := 7
+	if raw := r.URL.Query().Get("days"); raw != "" {
+		days, err = strconv.Atoi(raw)
+		if err != nil || days <= 0 || days > maxExternalHistoryDays {
+			writeError(w, http.StatusBadRequest, ErrCodeInvalidParameter, fmt.Sprintf("days must be between 1 and %d", maxExternalHistoryDays))
+			return
+		}
+	}
+
+	rows, err := h.db.Pool.Query(r.Context(), `
+		SELECT time, item_id, tornexchange_buy_price, torn_market_price, weav3r_min_bazaar
+		FROM external_prices
+		WHERE item_id = $1 AND time >= $2
+		ORDER BY time ASC
+	`, itemID, time.Now().AddDate(0, 0, -days))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeDatabase, "Database error")
+		return
+	}
+	defer rows.Close()
+
+	points := []models.ExternalPricePoint{}
+	for rows.Next() {
+		var p models.ExternalPricePoint
+		if err := rows.Scan(&p.Time, &p.ItemID, &p.TornExchangeBuyPrice, &p.TornMarketPrice, &p.Weav3rMinBazaar); err != nil {
+			fmt.Printf("Scan error in GetExternalPriceHistory: %v\n", err)
+			continue
+		}
+		points = append(points, p)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(points)
+}
+
+// Bulk external price limits: TornExchange is spaced at one request per few
+// seconds, so a bounded deadline makes slow sources drop out instead of stalling
+const (
+	maxBulkExternalItems   = 50
+	bulkExternalConcurrent = 8
+	bulkExternalTimeout    = 10 * time.Second
+)
+
+// GetExternalPricesBulk returns trader price overlays for many items, keyed by item ID.
+// Items or sources that don't answer within the deadline are omitted.
+// POST /api/v1/items/external-prices {"item_ids":[1,2,3]}
+func (h *PriceHandler) GetExternalPricesBulk(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ItemIDs []int64 `json:"item_ids"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, ErrCodeInvalidBody, "Invalid request body")
+		return
+	}
+	if len(req.ItemIDs) == 0 || len(req.ItemIDs) > maxBulkExternalItems {
+		writeError(w, http.StatusBadRequest, ErrCodeInvalidParameter, fmt.Sprintf("item_ids must contain 1 to %d IDs", maxBulkExternalItems))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), bulkExternalTimeout)
+	defer cancel()
+
+	var (
+		mu  sync.Mutex
+		wg  sync.WaitGroup
+		sem = make(chan struct{}, bulkExternalConcurrent)
+	)
+	results := make(map[string]*services.PriceOverlay, len(req.ItemIDs))
+
+	for _, itemID := range req.ItemIDs {
+		wg.Add(1)
+		go func(itemID int64) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			overlay := h.external.GetTraderPriceOverlay(ctx, itemID)
+			if !overlay.HasPrices() {
+				return
+			}
+			h.recordExternalPrices(itemID, overlay)
+			mu.Lock()
+			results[strconv.FormatInt(itemID, 10)] = overlay
+			mu.Unlock()
+		}(itemID)
+	}
+	wg.Wait()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(results)
+}
+
+// maxMetadataItems bounds a single metadata lookup
+const maxMetadataItems = 500
+
+// ItemMetadata is the price-free subset of an item used for labels
+type ItemMetadata struct {
+	ID          int64  `json:"id"`
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	Circulation int64  `json:"circulation"`
+	ImageURL    string `json:"image_url"`
+}
+
+// itemImageURL is Torn's static image path; items have no stored image column
+func itemImageURL(itemID int64) string {
+	return fmt.Sprintf("https://www.torn.com/images/items/%d/large.png", itemID)
+}
+
+// GetItemsMetadata returns names/types/images for many items without prices.
+// Unknown IDs are omitted.
+// POST /api/v1/items/metadata {"item_ids":[1,2,3]}
+func (h *PriceHandler) GetItemsMetadata(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ItemIDs []int64 `json:"item_ids"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, ErrCodeInvalidBody, "Invalid request body")
+		return
+	}
+	if len(req.ItemIDs) == 0 || len(req.ItemIDs) > maxMetadataItems {
+		writeError(w, http.StatusBadRequest, ErrCodeInvalidParameter, fmt.Sprintf("item_ids must contain 1 to %d IDs", maxMetadataItems))
+		return
+	}
+
+	rows, err := h.db.Pool.Query(r.Context(), `
+		SELECT id, name, COALESCE(type, ''), COALESCE(circulation, 0)
+		FROM items
+		WHERE id = ANY($1)
+		ORDER BY id
+	`, req.ItemIDs)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeDatabase, "Database error")
+		return
+	}
+	defer rows.Close()
+
+	items := make([]ItemMetadata, 0, len(req.ItemIDs))
+	for rows.Next() {
+		var m ItemMetadata
+		if err := rows.Scan(&m.ID, &m.Name, &m.Type, &m.Circulation); err != nil {
+			fmt.Printf("Scan error in GetItemsMetadata: %v\n", err)
+			continue
+		}
+		m.ImageURL = itemImageURL(m.ID)
+		items = append(items, m)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(items)
+}
+
+// GetTopListings returns top 5 bazaar listings from Weav3r
+// GET /api/v1/items/{id}/listings?type=bazaar
+func (h *PriceHandler) GetTopListings(w http.ResponseWriter, r *http.Request) {
+	itemID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, ErrCodeInvalidItemID, "Invalid item ID")
+		return
+	}
+
+	priceType, ok := priceTypeParam(r.URL.Query().Get("type"), "bazaar")
+	if !ok {
+		writeError(w, http.StatusBadRequest, ErrCodeInvalidParameter, "type must be one of: market, bazaar")
+		return
+	}
+
+	type ListingResponse struct {
+		PlayerID   int64  `json:"player_id"`
+		PlayerName string `json:"player_name"`
+		Price      int64  `json:"price"`
+		Quantity   int64  `json:"quantity"`
+		URL        string `json:"url"`
+	}
+
+	listings := make([]ListingResponse, 0)
+
+	if priceType == "bazaar" {
+		weav3rData, err := h.external.FetchWeav3rMarketplace(r.Context(), itemID)
+		if err != nil {
+			fmt.Printf("GetTopListings: Failed to fetch Weav3r data for item %d: %v\n", itemID, err)
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(listings)
+			return
+		}
+
+		// Update DB with latest bazaar price for non-watched items
+		if len(weav3rData.Listings) > 0 {
+			minPrice := weav3rData.Listings[0].Price
+			minQty := weav3rData.Listings[0].Quantity
+			sellerID := weav3rData.Listings[0].SellerID
+			now := time.Now()
+
+			// Run DB updates asynchronously to not block response significantly
+			h.writes.Enqueue(services.WriteJob{
+				Name: "top-listings bazaar price",
+				Run: func(ctx context.Context) error {
+					// Insert into bazaar_prices
+					_, err := h.db.Pool.Exec(ctx, `
+						INSERT INTO bazaar_prices (time, item_id, price, quantity, seller_id, source)
+						VALUES ($1, $2, $3, $4, $5, $6)
+					`, now, itemID, minPrice, minQty, sellerID, models.PriceSourceExternal)
+					if err != nil {
+						return fmt.Errorf("insert bazaar price for item %d: %w", itemID, err)
+					}
+
+					// Update item cache
+					_, err = h.db.Pool.Exec(ctx, `
+						UPDATE items SET last_bazaar_price = $1, last_bazaar_observed_at = $2, last_updated_at = $2 WHERE id = $3
+					`, minPrice, now, itemID)
+					if err != nil {
+						return fmt.Errorf("update item cache for item %d: %w", itemID, err)
+					}
+					return nil
+				},
+			})
+		}
+
+		// Get top 5 listings sorted by price
+		for i, listing := range weav3rData.Listings {
+			if i >= 5 {
+				break
+			}
+			listings = append(listings, ListingResponse{
+				PlayerID:   listing.SellerID,
+				PlayerName: listing.PlayerName,
+				Price:      listing.Price,
+				Quantity:   listing.Quantity,
+				URL:        "https://www.torn.com/bazaar.php?userId=" + strconv.FormatInt(listing.SellerID, 10) + "#/",
+			})
+		}
+	} else {
+		// Market type - return link to market
+		listings = append(listings, ListingResponse{
+			PlayerID:   0,
+			PlayerName: "Torn Market",
+			Price:      0,
+			Quantity:   0,
+			URL:        "https://www.torn.com/page.php?sid=ItemMarket#/market/view=search&itemID=" + strconv.FormatInt(itemID, 10),
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(listings)
+}
+
+// maxArbitrageItems caps GET /items/arbitrage
+const maxArbitrageItems = 100
+
+// defaultArbitrageMaxAge is how recently both sides must have been observed by default
+const defaultArbitrageMaxAge = 10 * time.Minute
+
+// ArbitrageItem is a tracked item whose market and bazaar prices diverge
+type ArbitrageItem struct {
+	ID              int64     `json:"id"`
+	Name            string    `json:"name"`
+	MarketPrice     int64     `json:"market_price"`
+	BazaarPrice     int64     `json:"bazaar_price"`
+	MarketUpdatedAt time.Time `json:"market_updated_at"`
+	BazaarUpdatedAt time.Time `json:"bazaar_updated_at"`
+	Spread          int64     `json:"spread"`         // market_price - bazaar_price
+	SpreadPercent   float64   `json:"spread_percent"` // |spread| relative to the cheaper side
+	BuyFrom         string    `json:"buy_from"`       // market or bazaar, whichever is cheaper
+}
+
+// GetArbitrage ranks tracked items by the percent gap between their market and
+// bazaar prices. Items missing either price, or whose market or bazaar price
+// was last observed more than max_age ago (a Go duration, default 10m), are
+// skipped so a stale side can't fake a spread. Observation times are kept on
+// items, so a stable price the throttle didn't store still counts as fresh.
+// GET /api/v1/items/arbitrage?limit=20&min_spread_percent=5&max_age=10m
+func (h *PriceHandler) GetArbitrage(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	var err error
+
+	limit := 20
+	if raw := q.Get("limit"); raw != "" {
+		limit, err = strconv.Atoi(raw)
+		if err != nil || limit <= 0 || limit > maxArbitrageItems {
+			writeError(w, http.StatusBadRequest, ErrCodeInvalidParameter, fmt.Sprintf("limit must be between 1 and %d", maxArbitrageItems))
+			return
+		}
+	}
+	minSpread := 5.0
+	if raw := q.Get("min_spread_percent"); raw != "" {
+		minSpread, err = strconv.ParseFloat(raw, 64)
+		if err != nil || minSpread < 0 {
+			writeError(w, http.StatusBadRequest, ErrCodeInvalidParameter, "min_spread_percent must be a non-negative number")
+			return
+		}
+	}
+	maxAge := defaultArbitrageMaxAge
+	if raw := q.Get("max_age"); raw != "" {
+		maxAge, err = time.ParseDuration(raw)
+		if err != nil || maxAge <= 0 {
+			writeError(w, http.StatusBadRequest, ErrCodeInvalidParameter, "max_age must be a positive duration such as 10m or 2h")
+			return
+		}
+	}
+	freshSince := time.Now().Add(-maxAge)
+
+	rows, err := h.db.Pool.Query(r.Context(), `
+		WITH candidates AS (
+			SELECT
+				i.id, i.name, i.last_market_price, i.last_bazaar_price,
+				i.last_market_observed_at AS market_at,
+				i.last_bazaar_observed_at AS bazaar_at,
+				abs(i.last_market_price - i.last_bazaar_price)::float
+					/ LEAST(i.last_market_price, i.last_bazaar_price) * 100 AS spread_percent
+			FROM items i
+			WHERE i.is_tracked = true AND i.last_market_price > 0 AND i.last_bazaar_price > 0
+		)
+		SELECT id, name, last_market_price, last_bazaar_price, market_at, bazaar_at, spread_percent
+		FROM candidates
+		WHERE spread_percent >= $1
+		  AND market_at >= $2
+		  AND bazaar_at >= $2
+		ORDER BY spread_percent DESC
+		LIMIT $3
+	`, minSpread, freshSince, limit)
+	if err != nil {
+		fmt.Printf("Database error in GetArbitrage: %v\n", err)
+		writeError(w, http.StatusInternalServerError, ErrCodeDatabase, "Database error")
+		return
+	}
+	defer rows.Close()
+
+	items := []ArbitrageItem{}
+	for rows.Next() {
+		var a ArbitrageItem
+		if err := rows.Scan(&a.ID, &a.Name, &a.MarketPrice, &a.BazaarPrice, &a.MarketUpdatedAt, &a.BazaarUpdatedAt, &a.SpreadPercent); err != nil {
+			fmt.Printf("Scan error in GetArbitrage: %v\n", err)
+			continue
+		}
+		a.Spread = a.MarketPrice - a.BazaarPrice
+		a.BuyFrom = "bazaar"
+		if a.Spread < 0 {
+			a.BuyFrom = "market"
+		}
+		items = append(items, a)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(items)
+}
+
+// maxRecentSales caps GET /items/{id}/sales
+const maxRecentSales = 100
+
+// GetRecentSales returns the item's most recent inferred bazaar sales
+// GET /api/v1/items/{id}/sales?limit=20
+func (h *PriceHandler) GetRecentSales(w http.ResponseWriter, r *http.Request) {
+	itemID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, ErrCodeInvalidItemID, "Invalid item ID")
+		return
+	}
+
+	limit := int64(20)
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		limit, err = strconv.ParseInt(raw, 10, 64)
+		if err != nil || limit <= 0 || limit > maxRecentSales {
+			writeError(w, http.StatusBadRequest, ErrCodeInvalidParameter, fmt.Sprintf("limit must be between 1 and %d", maxRecentSales))
+			return
+		}
+	}
+
+	rows, err := h.db.Pool.Query(r.Context(), `
+		SELECT time, item_id, price, quantity, COALESCE(seller_id, 0)
+		FROM sold_listings
+		WHERE item_id = $1
+		ORDER BY time DESC
+		LIMIT $2
+	`, itemID, limit)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeDatabase, "Database error")
+		return
+	}
+	defer rows.Close()
+
+	sales := []models.SoldListing{}
+	for rows.Next() {
+		var s models.SoldListing
+		if err := rows.Scan(&s.Time, &s.ItemID, &s.Price, &s.Quantity, &s.SellerID); err != nil {
+			fmt.Printf("Scan error in GetRecentSales: %v\n", err)
+			continue
+		}
+		sales = append(sales, s)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sales)
+}
+
+// BuyCostResponse describes the cost of filling a quantity from the cheapest bazaar listings
+type BuyCostResponse struct {
+	ItemID            int64      `json:"item_id"`
+	QuantityRequested int64      `json:"quantity_requested"`
+	QuantityFilled    int64      `json:"quantity_filled"`
+	TotalCost         int64      `json:"total_cost"`
+	AveragePrice      float64    `json:"average_price"`
+	ListingsUsed      int        `json:"listings_used"`
+	SufficientSupply  bool       `json:"sufficient_supply"`
+	SnapshotAt        *time.Time `json:"snapshot_at,omitempty"`
+}
+
+// GetBuyCost returns the cost to buy N units walking bazaar listings cheapest-first
+// GET /api/v1/items/{id}/buy-cost?quantity=100
+func (h *PriceHandler) GetBuyCost(w http.ResponseWriter, r *http.Request) {
+	itemID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, ErrCodeInvalidItemID, "Invalid item ID")
+		return
+	}
+
+	quantity, err := strconv.ParseInt(r.URL.Query().Get("quantity"), 10, 64)
+	if err != nil || quantity <= 0 {
+		writeError(w, http.StatusBadRequest, ErrCodeInvalidParameter, "quantity must be a positive integer")
+		return
+	}
+
+	ctx := r.Context()
+
+	// Prefer the depth snapshot stored by the bazaar poller
+	var listings []services.Weav3rListing
+	var snapshotAt *time.Time
+	var raw []byte
+	var updatedAt time.Time
+	err = h.db.Pool.QueryRow(ctx, "SELECT listings, updated_at FROM bazaar_listing_snapshots WHERE item_id = $1", itemID).
+		Scan(&raw, &updatedAt)
+	if err == nil && json.Unmarshal(raw, &listings) == nil {
+		snapshotAt = &updatedAt
+	} else {
+		// No snapshot yet (item not polled), fetch live
+		weav3rData, err := h.external.FetchWeav3rMarketplace(ctx, itemID)
+		if err != nil {
+			writeError(w, http.StatusBadGateway, ErrCodeUpstream, "Failed to fetch bazaar listings")
+			return
+		}
+		listings = weav3rData.Listings
+	}
+
+	resp := computeBuyCost(listings, quantity)
+	resp.ItemID = itemID
+	resp.SnapshotAt = snapshotAt
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// GetPriceTiers returns the cheapest item market price per listing-size bracket,
+// as last recorded by the background crawler
+// GET /api/v1/items/{id}/price-tiers
+func (h *PriceHandler) GetPriceTiers(w http.ResponseWriter, r *http.Request) {
+	itemID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, ErrCodeInvalidItemID, "Invalid item ID")
+		return
+	}
+
+	resp := models.ItemPriceTiers{ItemID: itemID}
+	var raw []byte
+	err = h.db.Pool.QueryRow(r.Context(), "SELECT tiers, updated_at FROM price_tiers WHERE item_id = $1", itemID).
+		Scan(&raw, &resp.UpdatedAt)
+	if err == pgx.ErrNoRows {
+		writeError(w, http.StatusNotFound, ErrCodeItemNotFound, "No price tiers recorded for this item")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeDatabase, "Database error")
+		return
+	}
+	if err := json.Unmarshal(raw, &resp.Tiers); err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to decode price tiers")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// computeBuyCost fills quantity from the cheapest listings first
+func computeBuyCost(listings []services.Weav3rListing, quantity int64) BuyCostResponse {
+	sorted := make([]services.Weav3rListing, len(listings))
+	copy(sorted, listings)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Price < sorted[j].Price })
+
+	resp := BuyCostResponse{QuantityRequested: quantity}
+	for _, l := range sorted {
+		if resp.QuantityFilled >= quantity {
+			break
+		}
+		if l.Quantity <= 0 || l.Price <= 0 {
+			continue
+		}
+		take := l.Quantity
+		if remaining := quantity - resp.QuantityFilled; take > remaining {
+			take = remaining
+		}
+		resp.TotalCost += take * l.Price
+		resp.QuantityFilled += take
+		resp.ListingsUsed++
+	}
+
+	resp.SufficientSupply = resp.QuantityFilled >= quantity
+	if resp.QuantityFilled > 0 {
+		resp.AveragePrice = float64(resp.TotalCost) / float64(resp.QuantityFilled)
+	}
+	return resp
+}
+
+// ItemValueResponse compares observed prices against Torn's reference market value
+type ItemValueResponse struct {
+	ItemID          int64    `json:"item_id"`
+	Name            string   `json:"name"`
+	TornMarketValue int64    `json:"torn_market_value"`
+	LastMarketPrice int64    `json:"last_market_price"`
+	LastBazaarPrice int64    `json:"last_bazaar_price"`
+	ObservedPrice   int64    `json:"observed_price"` // Cheapest of market/bazaar
+	Delta           *int64   `json:"delta"`          // observed - reference
+	DeltaPercent    *float64 `json:"delta_percent"`
+}
+
+// GetItemValue returns the observed price vs Torn's market_value
+// GET /api/v1/items/{id}/value
+func (h *PriceHandler) GetItemValue(w http.ResponseWriter, r *http.Request) {
+	itemID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, ErrCodeInvalidItemID, "Invalid item ID")
+		return
+	}
+
+	var resp ItemValueResponse
+	err = h.db.Pool.QueryRow(r.Context(), `
+		SELECT id, name, COALESCE(torn_market_value, 0),
+			COALESCE(last_market_price, 0), COALESCE(last_bazaar_price, 0)
+		FROM items
+		WHERE id = $1
+	`, itemID).Scan(&resp.ItemID, &resp.Name, &resp.TornMarketValue, &resp.LastMarketPrice, &resp.LastBazaarPrice)
+	if err != nil {
+		writeError(w, http.StatusNotFound, ErrCodeItemNotFound, "Item not found")
+		return
+	}
+
+	resp.ObservedPrice = resp.LastMarketPrice
+	if resp.LastBazaarPrice > 0 && (resp.ObservedPrice == 0 || resp.LastBazaarPrice < resp.ObservedPrice) {
+		resp.ObservedPrice = resp.LastBazaarPrice
+	}
+
+	if resp.ObservedPrice > 0 && resp.TornMarketValue > 0 {
+		delta := resp.ObservedPrice - resp.TornMarketValue
+		pct := float64(delta) / float64(resp.TornMarketValue) * 100
+		resp.Delta = &delta
+		resp.DeltaPercent = &pct
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// maxStatsDays caps the window of GET /items/{id}/stats
+const maxStatsDays = 365
+
+// ItemStatsResponse summarises an item's market price over a window.
+// Fields are null when the window (or change horizon) has no data.
+type ItemStatsResponse struct {
+	ItemID        int64    `json:"item_id"`
+	Days          int      `json:"days"`
+	Samples       int64    `json:"samples"`
+	Min           *int64   `json:"min"`
+	Max           *int64   `json:"max"`
+	Mean          *float64 `json:"mean"`
+	Median        *float64 `json:"median"`
+	StdDev        *float64 `json:"stddev"`
+	CurrentPrice  *int64   `json:"current_price"`
+	CurrentVolume *int64   `json:"current_volume"` // Quantity on the latest market price row
+	Change24h     *float64 `json:"change_24h"`     // Percent change vs the price 24h ago
+	Change7d      *float64 `json:"change_7d"`
+	Change30d     *float64 `json:"change_30d"`
+}
+
+// percentChange returns the percent move from past to current, nil when either is unknown
+func percentChange(past, current *int64) *float64 {
+	if past == nil || current == nil || *past <= 0 {
+		return nil
+	}
+	pct := float64(*current-*past) / float64(*past) * 100
+	return &pct
+}
+
+// GetItemStats returns min/max/mean/median/stddev of the market price over the
+// last ?days= (default 30), plus 24h/7d/30d percent changes and current volume
+// GET /api/v1/items/{id}/stats?days=30
+func (h *PriceHandler) GetItemStats(w http.ResponseWriter, r *http.Request) {
+	itemID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, ErrCodeInvalidItemID, "Invalid item ID")
+		return
+	}
+	days := 30
+	if raw := r.URL.Query().Get("days"); raw != "" {
+		days, err = strconv.Atoi(raw)
+		if err != nil || days <= 0 || days > maxStatsDays {
+			writeError(w, http.StatusBadRequest, ErrCodeInvalidParameter, fmt.Sprintf("days must be between 1 and %d", maxStatsDays))
+			return
+		}
+	}
+
+	resp := ItemStatsResponse{ItemID: itemID, Days: days}
+	ctx := r.Context()
+
+	// The past prices are the last point at or before each horizon
+	var price24h, price7d, price30d *int64
+	err = h.db.Pool.QueryRow(ctx, `
+		WITH window_prices AS (
+			SELECT price FROM market_prices
+			WHERE item_id = $1 AND time >= NOW() - make_interval(days => $2) AND price > 0
+		),
+		latest AS (
+			SELECT price, quantity FROM market_prices
+			WHERE item_id = $1 ORDER BY time DESC LIMIT 1
+		)
+		SELECT
+			(SELECT COUNT(*) FROM window_prices),
+			(SELECT MIN(price) FROM window_prices),
+			(SELECT MAX(price) FROM window_prices),
+			(SELECT AVG(price)::float FROM window_prices),
+			(SELECT percentile_cont(0.5) WITHIN GROUP (ORDER BY price) FROM window_prices),
+			(SELECT stddev_samp(price)::float FROM window_prices),
+			(SELECT price FROM latest),
+			(SELECT quantity FROM latest),
+			(SELECT price FROM market_prices WHERE item_id = $1 AND time <= NOW() - INTERVAL '24 hours' ORDER BY time DESC LIMIT 1),
+			(SELECT price FROM market_prices WHERE item_id = $1 AND time <= NOW() - INTERVAL '7 days' ORDER BY time DESC LIMIT 1),
+			(SELECT price FROM market_prices WHERE item_id = $1 AND time <= NOW() - INTERVAL '30 days' ORDER BY time DESC LIMIT 1)
+	`, itemID, days).Scan(
+		&resp.Samples, &resp.Min, &resp.Max, &resp.Mean, &resp.Median, &resp.StdDev,
+		&resp.CurrentPrice, &resp.CurrentVolume,
+		&price24h, &price7d, &price30d,
+	)
+	if err != nil {
+		fmt.Printf("Database error in GetItemStats: %v\n", err)
+		writeError(w, http.StatusInternalServerError, ErrCodeDatabase, "Database error")
+		return
+	}
+
+	resp.Change24h = percentChange(price24h, resp.CurrentPrice)
+	resp.Change7d = percentChange(price7d, resp.CurrentPrice)
+	resp.Change30d = percentChange(price30d, resp.CurrentPrice)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// ToggleWatchlist adds or removes an item from the user's watchlist
+// POST /api/v1/items/{id}/watch
+func (h *PriceHandler) ToggleWatchlist(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, ok := GetUserIDFromContext(ctx)
+	if !ok {
+		writeError(w, http.StatusUnauthorized, ErrCodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	itemID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, ErrCodeInvalidItemID, "Invalid item ID")
+		return
+	}
+
+	// Check current status
+	var exists bool
+	err = h.db.Pool.QueryRow(ctx, "SELECT EXISTS(SELECT 1 FROM user_watchlists WHERE user_id = $1 AND item_id = $2)", userID, itemID).Scan(&exists)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeDatabase, "Database error")
+		return
+	}
+
+	if exists {
+		// Remove
+		_, err = h.db.Pool.Exec(ctx, "DELETE FROM user_watchlists WHERE user_id = $1 AND item_id = $2", userID, itemID)
+	} else {
+		// Add
+		_, err = h.db.Pool.Exec(ctx, "INSERT INTO user_watchlists (user_id, item_id) VALUES ($1, $2)", userID, itemID)
+	}
 
-	var item models.Item
-	err = h.db.Pool.QueryRow(ctx, query, itemID, userID).Scan(
-		&item.ID, &item.Name, &item.Type, &item.Circulation,
-		&item.LastMarketPrice, &item.LastBazaarPrice, &item.LastUpdatedAt, &item.IsWatched,
-		&item.AlertPriceAbove, &item.AlertPriceBelow, &item.AlertChangePercent,
-	)
 	if err != nil {
-		http.Error(w, "Item not found", http.StatusNotFound)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to update watchlist")
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(item)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"item_id":    itemID,
+		"is_watched": !exists,
+	})
 }
 
-// ListTracked returns all tracked items (including user's watched items)
-// GET /api/v1/items
-func (h *PriceHandler) ListTracked(w http.ResponseWriter, r *http.Request) {
+// change24hJoin computes the 24h market % change for items aliased i from the
+// hourly aggregate (first open vs last close in the window) as ch.change_24h
+const change24hJoin = `
+		LEFT JOIN LATERAL (
+			SELECT (last(close, bucket) - first(open, bucket))::float
+				/ NULLIF(first(open, bucket), 0) * 100 AS change_24h
+			FROM market_prices_1h
+			WHERE item_id = i.id AND bucket >= NOW() - INTERVAL '24 hours'
+		) ch ON true`
+
+// ListWatched returns all items in the user's watchlist, with the user's notes/tags
+// GET /api/v1/items/watched?sort=name|change&order=asc|desc&tag=&envelope=true&limit=&offset=
+func (h *PriceHandler) ListWatched(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
-	userID, _ := GetUserIDFromContext(ctx) // Optional: might be 0 if public endpoint, but we should handle it
-
-	query := `
-		SELECT 
-			i.id, i.name, i.type, i.circulation, i.is_tracked, 
-			CASE WHEN uw.user_id IS NOT NULL THEN true ELSE false END as is_watched,
-			COALESCE(i.last_market_price, 0) as last_market_price,
-			COALESCE(i.last_bazaar_price, 0) as last_bazaar_price,
-			i.last_updated_at
-		FROM items i
-		LEFT JOIN user_watchlists uw ON i.id = uw.item_id AND uw.user_id = $1
-		WHERE i.is_tracked = true OR uw.user_id IS NOT NULL
-		ORDER BY i.name ASC
-	`
+	userID, ok := GetUserIDFromContext(ctx)
+	if !ok {
+		writeError(w, http.StatusUnauthorized, ErrCodeUnauthorized, "Unauthorized")
+		return
+	}
 
-	rows, err := h.db.Pool.Query(ctx, query, userID)
+	page, err := parsePageParams(r.URL.Query(), 100)
 	if err != nil {
-		fmt.Printf("Database error in ListTracked: %v\n", err)
-		http.Error(w, "Database error", http.StatusInternalServerError)
+		writeError(w, http.StatusBadRequest, ErrCodeInvalidParameter, err.Error())
 		return
 	}
-	defer rows.Close()
 
-	items := make([]models.Item, 0)
-	for rows.Next() {
-		var item models.Item
-		if err := rows.Scan(
-			&item.ID, &item.Name, &item.Type, &item.Circulation, &item.IsTracked, &item.IsWatched,
-			&item.LastMarketPrice, &item.LastBazaarPrice, &item.LastUpdatedAt,
-		); err != nil {
-			fmt.Printf("Scan error in ListTracked: %v\n", err)
-			continue
-		}
-		items = append(items, item)
+	order := r.URL.Query().Get("order")
+	if order != "" && order != "asc" && order != "desc" {
+		writeError(w, http.StatusBadRequest, ErrCodeInvalidParameter, "order must be asc or desc")
+		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(items)
-}
-
-// SearchItems searches for items by name
-// GET /api/v1/items/search?q=query
-func (h *PriceHandler) SearchItems(w http.ResponseWriter, r *http.Request) {
-	queryParam := r.URL.Query().Get("q")
-	if queryParam == "" {
-		// Return empty list if no query
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode([]models.Item{})
+	var orderBy string
+	switch r.URL.Query().Get("sort") {
+	case "", "name":
+		orderBy = "i.name ASC"
+		if order == "desc" {
+			orderBy = "i.name DESC"
+		}
+	case "change":
+		// Biggest movers first by default; items without data go last
+		orderBy = "ch.change_24h DESC NULLS LAST, i.name ASC"
+		if order == "asc" {
+			orderBy = "ch.change_24h ASC NULLS LAST, i.name ASC"
+		}
+	default:
+		writeError(w, http.StatusBadRequest, ErrCodeInvalidParameter, "sort must be name or change")
 		return
 	}
 
-	ctx := r.Context()
-	userID, _ := GetUserIDFromContext(ctx)
-
-	// SQL to search both tracked and untracked items
-	// We also return 'is_watched' status if user is logged in
-	sql := `
+	query := `
 		SELECT 
-			i.id, i.name, i.type, i.circulation, i.is_tracked, 
-			CASE WHEN uw.user_id IS NOT NULL THEN true ELSE false END as is_watched,
+			i.id, i.name, i.type, i.circulation, i.is_tracked, true as is_watched,
 			COALESCE(i.last_market_price, 0) as last_market_price,
 			COALESCE(i.last_bazaar_price, 0) as last_bazaar_price,
-			i.last_updated_at
+			i.last_updated_at,
+			ua.alert_price_above, ua.alert_price_below, ua.alert_change_percent, ua.alert_change_absolute,
+			COALESCE(ua.alert_on_record, false),
+			ch.change_24h,
+			COALESCE(n.note, ''), COALESCE(n.tags, '{}')
 		FROM items i
-		LEFT JOIN user_watchlists uw ON i.id = uw.item_id AND uw.user_id = $1
-		WHERE i.name ILIKE $2
-		ORDER BY 
-			CASE WHEN i.name ILIKE $3 THEN 0 ELSE 1 END, -- Prioritize exact starts
-			i.name ASC
-		LIMIT 20
-	`
+		JOIN user_watchlists uw ON i.id = uw.item_id AND uw.user_id = $1
+		LEFT JOIN user_alerts ua ON i.id = ua.item_id AND ua.user_id = $1
+		LEFT JOIN user_item_notes n ON i.id = n.item_id AND n.user_id = $1` + change24hJoin
 
-	// $2 = %query%, $3 = query%
-	likeQuery := "%" + queryParam + "%"
-	startQuery := queryParam + "%"
+	args := []interface{}{userID}
+	if tag := normalizeTag(r.URL.Query().Get("tag")); tag != "" {
+		args = append(args, tag)
+		query += `
+		WHERE $2 = ANY(n.tags)`
+	}
+	query += `
+		ORDER BY ` + orderBy
 
-	rows, err := h.db.Pool.Query(ctx, sql, userID, likeQuery, startQuery)
+	rows, err := h.db.Pool.Query(ctx, query, args...)
 	if err != nil {
-		fmt.Printf("Database error in SearchItems: %v\n", err)
-		http.Error(w, "Database error", http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, ErrCodeDatabase, "Database error")
 		return
 	}
 	defer rows.Close()
@@ -269,209 +1674,182 @@ func (h *PriceHandler) SearchItems(w http.ResponseWriter, r *http.Request) {
 	for rows.Next() {
 		var item models.Item
 		if err := rows.Scan(
-			&item.ID, &item.Name, &item.Type, &item.Circulation, &item.IsTracked, &item.IsWatched,
-			&item.LastMarketPrice, &item.LastBazaarPrice, &item.LastUpdatedAt,
+			&item.ID, &item.Name, &item.Type, &item.Circulation,
+			&item.IsTracked, &item.IsWatched, &item.LastMarketPrice, &item.LastBazaarPrice, &item.LastUpdatedAt,
+			&item.AlertPriceAbove, &item.AlertPriceBelow, &item.AlertChangePercent, &item.AlertChangeAbsolute, &item.AlertOnRecord,
+			&item.Change24h, &item.Note, &item.Tags,
 		); err != nil {
-			fmt.Printf("Scan error in SearchItems: %v\n", err)
+			fmt.Printf("Scan error in ListWatched: %v\n", err)
 			continue
 		}
 		items = append(items, item)
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(items)
+	writeList(w, items, page)
 }
 
-// GetExternalPrices returns trader prices from TornExchange and Weav3r
-// GET /api/v1/items/{id}/external-prices
-func (h *PriceHandler) GetExternalPrices(w http.ResponseWriter, r *http.Request) {
-	itemID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
-	if err != nil {
-		http.Error(w, "Invalid item ID", http.StatusBadRequest)
-		return
-	}
+// maxNoteLength and maxTags keep annotations lightweight
+const (
+	maxNoteLength = 1000
+	maxTags       = 10
+)
 
-	client := services.NewExternalPriceClient()
-	prices, err := client.GetTraderPriceOverlay(r.Context(), itemID)
-	if err != nil {
-		http.Error(w, "Failed to fetch external prices", http.StatusInternalServerError)
+// normalizeTag lowercases and trims a tag so filtering is case-insensitive
+func normalizeTag(tag string) string {
+	return strings.ToLower(strings.TrimSpace(tag))
+}
+
+// UpdateItemNote sets the user's private note and tags for an item.
+// An empty note with no tags removes the annotation.
+// PUT /api/v1/items/{id}/note
+func (h *PriceHandler) UpdateItemNote(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, ok := GetUserIDFromContext(ctx)
+	if !ok {
+		writeError(w, http.StatusUnauthorized, ErrCodeUnauthorized, "Unauthorized")
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(prices)
-}
-
-// GetTopListings returns top 5 bazaar listings from Weav3r
-// GET /api/v1/items/{id}/listings?type=bazaar
-func (h *PriceHandler) GetTopListings(w http.ResponseWriter, r *http.Request) {
 	itemID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid item ID", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, ErrCodeInvalidItemID, "Invalid item ID")
 		return
 	}
 
-	priceType := r.URL.Query().Get("type")
-	if priceType == "" {
-		priceType = "bazaar"
+	var req struct {
+		Note string   `json:"note"`
+		Tags []string `json:"tags"`
 	}
-
-	type ListingResponse struct {
-		PlayerID   int64  `json:"player_id"`
-		PlayerName string `json:"player_name"`
-		Price      int64  `json:"price"`
-		Quantity   int64  `json:"quantity"`
-		URL        string `json:"url"`
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, ErrCodeInvalidBody, "Invalid request body")
+		return
 	}
 
-	listings := make([]ListingResponse, 0)
+	note := strings.TrimSpace(req.Note)
+	if len(note) > maxNoteLength {
+		writeError(w, http.StatusBadRequest, ErrCodeInvalidParameter, fmt.Sprintf("note must be at most %d characters", maxNoteLength))
+		return
+	}
 
-	if priceType == "bazaar" {
-		client := services.NewExternalPriceClient()
-		weav3rData, err := client.FetchWeav3rMarketplace(r.Context(), itemID)
-		if err != nil {
-			fmt.Printf("GetTopListings: Failed to fetch Weav3r data for item %d: %v\n", itemID, err)
-			w.Header().Set("Content-Type", "application/json")
-			json.NewEncoder(w).Encode(listings)
-			return
+	// Normalize and dedupe tags
+	tags := make([]string, 0, len(req.Tags))
+	seen := make(map[string]bool)
+	for _, t := range req.Tags {
+		t = normalizeTag(t)
+		if t == "" || seen[t] {
+			continue
 		}
+		seen[t] = true
+		tags = append(tags, t)
+	}
+	if len(tags) > maxTags {
+		writeError(w, http.StatusBadRequest, ErrCodeInvalidParameter, fmt.Sprintf("at most %d tags allowed", maxTags))
+		return
+	}
 
-		// Update DB with latest bazaar price for non-watched items
-		if len(weav3rData.Listings) > 0 {
-			minPrice := weav3rData.Listings[0].Price
-			minQty := weav3rData.Listings[0].Quantity
-			sellerID := weav3rData.Listings[0].SellerID
-			now := time.Now()
-
-			// Run DB updates asynchronously to not block response significantly
-			go func() {
-				defer func() {
-					if r := recover(); r != nil {
-						fmt.Printf("Recovered from panic in GetTopListings async update: %v\n", r)
-					}
-				}()
-
-				ctx := context.Background() // New context for async operation
-
-				// Insert into bazaar_prices
-				_, err := h.db.Pool.Exec(ctx, `
-					INSERT INTO bazaar_prices (time, item_id, price, quantity, seller_id)
-					VALUES ($1, $2, $3, $4, $5)
-				`, now, itemID, minPrice, minQty, sellerID)
-				if err != nil {
-					fmt.Printf("Failed to insert bazaar price for item %d: %v\n", itemID, err)
-				} else {
-					// fmt.Printf("Successfully updated bazaar price for item %d: %d\n", itemID, minPrice)
-				}
-
-				// Update item cache
-				_, err = h.db.Pool.Exec(ctx, `
-					UPDATE items SET last_bazaar_price = $1, last_updated_at = $2 WHERE id = $3
-				`, minPrice, now, itemID)
-				if err != nil {
-					fmt.Printf("Failed to update item cache for item %d: %v\n", itemID, err)
-				}
-			}()
-		}
+	// An unknown item would otherwise surface as a foreign key error
+	var exists bool
+	if err := h.db.Pool.QueryRow(ctx, "SELECT EXISTS(SELECT 1 FROM items WHERE id = $1)", itemID).Scan(&exists); err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeDatabase, "Database error")
+		return
+	}
+	if !exists {
+		writeError(w, http.StatusNotFound, ErrCodeItemNotFound, "Item not found")
+		return
+	}
 
-		// Get top 5 listings sorted by price
-		for i, listing := range weav3rData.Listings {
-			if i >= 5 {
-				break
-			}
-			listings = append(listings, ListingResponse{
-				PlayerID:   listing.SellerID,
-				PlayerName: listing.PlayerName,
-				Price:      listing.Price,
-				Quantity:   listing.Quantity,
-				URL:        "https://www.torn.com/bazaar.php?userId=" + strconv.FormatInt(listing.SellerID, 10) + "#/",
-			})
-		}
+	if note == "" && len(tags) == 0 {
+		_, err = h.db.Pool.Exec(ctx, "DELETE FROM user_item_notes WHERE user_id = $1 AND item_id = $2", userID, itemID)
 	} else {
-		// Market type - return link to market
-		listings = append(listings, ListingResponse{
-			PlayerID:   0,
-			PlayerName: "Torn Market",
-			Price:      0,
-			Quantity:   0,
-			URL:        "https://www.torn.com/page.php?sid=ItemMarket#/market/view=search&itemID=" + strconv.FormatInt(itemID, 10),
-		})
+		_, err = h.db.Pool.Exec(ctx, `
+			INSERT INTO user_item_notes (user_id, item_id, note, tags, updated_at)
+			VALUES ($1, $2, $3, $4, NOW())
+			ON CONFLICT (user_id, item_id) DO UPDATE
+			SET note = $3, tags = $4, updated_at = NOW()
+		`, userID, itemID, note, tags)
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to update note")
+		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(listings)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"item_id": itemID,
+		"note":    note,
+		"tags":    tags,
+	})
 }
 
-// ToggleWatchlist adds or removes an item from the user's watchlist
-// POST /api/v1/items/{id}/watch
-func (h *PriceHandler) ToggleWatchlist(w http.ResponseWriter, r *http.Request) {
+// TogglePin pins or unpins an item for the user
+// POST /api/v1/items/{id}/pin
+func (h *PriceHandler) TogglePin(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	userID, ok := GetUserIDFromContext(ctx)
 	if !ok {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		writeError(w, http.StatusUnauthorized, ErrCodeUnauthorized, "Unauthorized")
 		return
 	}
 
 	itemID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid item ID", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, ErrCodeInvalidItemID, "Invalid item ID")
 		return
 	}
 
 	// Check current status
 	var exists bool
-	err = h.db.Pool.QueryRow(ctx, "SELECT EXISTS(SELECT 1 FROM user_watchlists WHERE user_id = $1 AND item_id = $2)", userID, itemID).Scan(&exists)
+	err = h.db.Pool.QueryRow(ctx, "SELECT EXISTS(SELECT 1 FROM user_pins WHERE user_id = $1 AND item_id = $2)", userID, itemID).Scan(&exists)
 	if err != nil {
-		http.Error(w, "Database error", http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, ErrCodeDatabase, "Database error")
 		return
 	}
 
 	if exists {
-		// Remove
-		_, err = h.db.Pool.Exec(ctx, "DELETE FROM user_watchlists WHERE user_id = $1 AND item_id = $2", userID, itemID)
+		_, err = h.db.Pool.Exec(ctx, "DELETE FROM user_pins WHERE user_id = $1 AND item_id = $2", userID, itemID)
 	} else {
-		// Add
-		_, err = h.db.Pool.Exec(ctx, "INSERT INTO user_watchlists (user_id, item_id) VALUES ($1, $2)", userID, itemID)
+		_, err = h.db.Pool.Exec(ctx, "INSERT INTO user_pins (user_id, item_id) VALUES ($1, $2)", userID, itemID)
 	}
 
 	if err != nil {
-		http.Error(w, "Failed to update watchlist", http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to update pins")
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"item_id":    itemID,
-		"is_watched": !exists,
+		"item_id":   itemID,
+		"is_pinned": !exists,
 	})
 }
 
-// ListWatched returns all items in the user's watchlist
-// GET /api/v1/items/watched
-func (h *PriceHandler) ListWatched(w http.ResponseWriter, r *http.Request) {
+// ListPinned returns the user's pinned items, most recently pinned first
+// GET /api/v1/items/pinned
+func (h *PriceHandler) ListPinned(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	userID, ok := GetUserIDFromContext(ctx)
 	if !ok {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		writeError(w, http.StatusUnauthorized, ErrCodeUnauthorized, "Unauthorized")
 		return
 	}
 
 	query := `
 		SELECT 
-			i.id, i.name, i.type, i.circulation, i.is_tracked, true as is_watched,
+			i.id, i.name, i.type, i.circulation, i.is_tracked,
+			CASE WHEN uw.user_id IS NOT NULL THEN true ELSE false END as is_watched,
+			true as is_pinned,
 			COALESCE(i.last_market_price, 0) as last_market_price,
 			COALESCE(i.last_bazaar_price, 0) as last_bazaar_price,
-			i.last_updated_at,
-			ua.alert_price_above, ua.alert_price_below, ua.alert_change_percent
+			i.last_updated_at
 		FROM items i
-		JOIN user_watchlists uw ON i.id = uw.item_id AND uw.user_id = $1
-		LEFT JOIN user_alerts ua ON i.id = ua.item_id AND ua.user_id = $1
-		ORDER BY i.name ASC
+		JOIN user_pins up ON i.id = up.item_id AND up.user_id = $1
+		LEFT JOIN user_watchlists uw ON i.id = uw.item_id AND uw.user_id = $1
+		ORDER BY up.pinned_at DESC
 	`
 
 	rows, err := h.db.Pool.Query(ctx, query, userID)
 	if err != nil {
-		http.Error(w, "Database error", http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, ErrCodeDatabase, "Database error")
 		return
 	}
 	defer rows.Close()
@@ -481,10 +1859,10 @@ func (h *PriceHandler) ListWatched(w http.ResponseWriter, r *http.Request) {
 		var item models.Item
 		if err := rows.Scan(
 			&item.ID, &item.Name, &item.Type, &item.Circulation,
-			&item.IsTracked, &item.IsWatched, &item.LastMarketPrice, &item.LastBazaarPrice, &item.LastUpdatedAt,
-			&item.AlertPriceAbove, &item.AlertPriceBelow, &item.AlertChangePercent,
+			&item.IsTracked, &item.IsWatched, &item.IsPinned,
+			&item.LastMarketPrice, &item.LastBazaarPrice, &item.LastUpdatedAt,
 		); err != nil {
-			fmt.Printf("Scan error in ListWatched: %v\n", err)
+			fmt.Printf("Scan error in ListPinned: %v\n", err)
 			continue
 		}
 		items = append(items, item)
@@ -496,9 +1874,69 @@ func (h *PriceHandler) ListWatched(w http.ResponseWriter, r *http.Request) {
 
 // AlertSettingsRequest represents the request body for updating alert settings
 type AlertSettingsRequest struct {
-	AlertPriceAbove    *int64   `json:"alert_price_above"`
-	AlertPriceBelow    *int64   `json:"alert_price_below"`
-	AlertChangePercent *float64 `json:"alert_change_percent"`
+	AlertPriceAbove     *int64   `json:"alert_price_above"`
+	AlertPriceBelow     *int64   `json:"alert_price_below"`
+	AlertChangePercent  *float64 `json:"alert_change_percent"`
+	AlertChangeAbsolute *int64   `json:"alert_change_absolute"`
+	AlertOnRecord       *bool    `json:"alert_on_record"`  // Omitted keeps the current value
+	CooldownSeconds     *int     `json:"cooldown_seconds"` // Omitted keeps the current value; 0 disables it, CooldownUseGlobal resets it
+}
+
+// CooldownUseGlobal is the cooldown_seconds value that clears an alert's own
+// cooldown so it falls back to the global alert cooldown
+const CooldownUseGlobal = -1
+
+// validCooldown reports whether a requested cooldown_seconds is acceptable
+func validCooldown(seconds *int) bool {
+	return seconds == nil || *seconds >= 0 || *seconds == CooldownUseGlobal
+}
+
+// GetAlertSettings returns the user's alert configuration for an item, in the
+// same shape UpdateAlertSettings responds with, or {} when none is set
+// GET /api/v1/items/{id}/alerts
+func (h *PriceHandler) GetAlertSettings(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, ok := GetUserIDFromContext(ctx)
+	if !ok {
+		writeError(w, http.StatusUnauthorized, ErrCodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	itemID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, ErrCodeInvalidItemID, "Invalid item ID")
+		return
+	}
+
+	var above, below, absolute *int64
+	var percent *float64
+	var onRecord bool
+	var cooldown *int
+	err = h.db.Pool.QueryRow(ctx, `
+		SELECT alert_price_above, alert_price_below, alert_change_percent, alert_change_absolute, COALESCE(alert_on_record, false), cooldown_seconds
+		FROM user_alerts
+		WHERE user_id = $1 AND item_id = $2
+	`, userID, itemID).Scan(&above, &below, &percent, &absolute, &onRecord, &cooldown)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err == pgx.ErrNoRows {
+		w.Write([]byte("{}"))
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeDatabase, "Database error")
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"item_id":               itemID,
+		"alert_price_above":     above,
+		"alert_price_below":     below,
+		"alert_change_percent":  percent,
+		"alert_change_absolute": absolute,
+		"alert_on_record":       onRecord,
+		"cooldown_seconds":      cooldown,
+	})
 }
 
 // UpdateAlertSettings updates alert configuration for an item
@@ -507,78 +1945,153 @@ func (h *PriceHandler) UpdateAlertSettings(w http.ResponseWriter, r *http.Reques
 	ctx := r.Context()
 	userID, ok := GetUserIDFromContext(ctx)
 	if !ok {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		writeError(w, http.StatusUnauthorized, ErrCodeUnauthorized, "Unauthorized")
 		return
 	}
 
 	itemID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid item ID", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, ErrCodeInvalidItemID, "Invalid item ID")
 		return
 	}
 
 	var req AlertSettingsRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, ErrCodeInvalidBody, "Invalid request body")
+		return
+	}
+	if !validCooldown(req.CooldownSeconds) {
+		writeError(w, http.StatusBadRequest, ErrCodeInvalidParameter, "cooldown_seconds must be 0 or more, or -1 to use the global cooldown")
+		return
+	}
+	if req.AlertChangeAbsolute != nil && *req.AlertChangeAbsolute <= 0 {
+		writeError(w, http.StatusBadRequest, ErrCodeInvalidParameter, "alert_change_absolute must be positive")
 		return
 	}
 
-	_, err = h.db.Pool.Exec(ctx, `
-		INSERT INTO user_alerts (user_id, item_id, alert_price_above, alert_price_below, alert_change_percent, created_at)
-		VALUES ($1, $2, $3, $4, $5, NOW())
+	var alertOnRecord bool
+	var cooldown *int
+	err = h.db.Pool.QueryRow(ctx, `
+		INSERT INTO user_alerts (user_id, item_id, alert_price_above, alert_price_below, alert_change_percent, alert_on_record, alert_change_absolute, cooldown_seconds, created_at)
+		VALUES ($1, $2, $3, $4, $5, COALESCE($6, false), $7, NULLIF($8::int, $9), NOW())
 		ON CONFLICT (user_id, item_id) DO UPDATE 
-		SET alert_price_above = $3, alert_price_below = $4, alert_change_percent = $5
-	`, userID, itemID, req.AlertPriceAbove, req.AlertPriceBelow, req.AlertChangePercent)
+		SET alert_price_above = $3, alert_price_below = $4, alert_change_percent = $5,
+			alert_on_record = COALESCE($6, user_alerts.alert_on_record), alert_change_absolute = $7,
+			cooldown_seconds = CASE WHEN $8::int IS NULL THEN user_alerts.cooldown_seconds ELSE NULLIF($8::int, $9) END
+		RETURNING alert_on_record, cooldown_seconds
+	`, userID, itemID, req.AlertPriceAbove, req.AlertPriceBelow, req.AlertChangePercent, req.AlertOnRecord, req.AlertChangeAbsolute, req.CooldownSeconds, CooldownUseGlobal).Scan(&alertOnRecord, &cooldown)
 
 	if err != nil {
-		http.Error(w, "Failed to update alert settings", http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to update alert settings")
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"item_id":              itemID,
-		"alert_price_above":    req.AlertPriceAbove,
-		"alert_price_below":    req.AlertPriceBelow,
-		"alert_change_percent": req.AlertChangePercent,
+		"item_id":               itemID,
+		"alert_price_above":     req.AlertPriceAbove,
+		"alert_price_below":     req.AlertPriceBelow,
+		"alert_change_percent":  req.AlertChangePercent,
+		"alert_change_absolute": req.AlertChangeAbsolute,
+		"alert_on_record":       alertOnRecord,
+		"cooldown_seconds":      cooldown,
 	})
 }
 
-// GetItemHistory returns the price history for a specific item over the last 24 hours
-// GET /api/v1/items/{id}/history
-func (h *PriceHandler) GetItemHistory(w http.ResponseWriter, r *http.Request) {
-	itemIDStr := chi.URLParam(r, "id")
-	itemID, err := strconv.ParseInt(itemIDStr, 10, 64)
+// SetItemTracked forces an item to be tracked or untracked by the crawler and poller.
+// Admin only.
+// PUT /api/v1/admin/items/{id}/tracked
+func (h *PriceHandler) SetItemTracked(w http.ResponseWriter, r *http.Request) {
+	itemID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid item ID", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, ErrCodeInvalidItemID, "Invalid item ID")
 		return
 	}
 
-	query := `
-		SELECT 
-			item_id, price as market_price, 0 as bazaar_price, time as timestamp
-		FROM market_prices
-		WHERE item_id = $1 AND time >= NOW() - INTERVAL '24 hours'
-		ORDER BY time ASC
-	`
+	var req struct {
+		IsTracked *bool `json:"is_tracked"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.IsTracked == nil {
+		writeError(w, http.StatusBadRequest, ErrCodeMissingField, "Invalid request body: is_tracked is required")
+		return
+	}
 
-	rows, err := h.db.Pool.Query(r.Context(), query, itemID)
+	var item models.Item
+	err = h.db.Pool.QueryRow(r.Context(), `
+		UPDATE items SET is_tracked = $1
+		WHERE id = $2
+		RETURNING id, name, type, circulation, is_tracked,
+			COALESCE(last_market_price, 0), COALESCE(last_bazaar_price, 0), last_updated_at
+	`, *req.IsTracked, itemID).Scan(
+		&item.ID, &item.Name, &item.Type, &item.Circulation, &item.IsTracked,
+		&item.LastMarketPrice, &item.LastBazaarPrice, &item.LastUpdatedAt,
+	)
 	if err != nil {
-		http.Error(w, "Database error", http.StatusInternalServerError)
+		writeError(w, http.StatusNotFound, ErrCodeItemNotFound, "Item not found")
 		return
 	}
-	defer rows.Close()
 
-	var history []models.Item
-	for rows.Next() {
-		var hItem models.Item
-		if err := rows.Scan(&hItem.ID, &hItem.LastMarketPrice, &hItem.LastBazaarPrice, &hItem.LastUpdatedAt); err == nil {
-			history = append(history, hItem)
-		}
+	w.Header().Set("Content-Type", "application/json")
+	h.tracked.invalidate()
+
+	json.NewEncoder(w).Encode(item)
+}
+
+// RecomputeItemCache rebuilds every item's last_market_price/last_bazaar_price
+// from the latest stored price rows, healing drift after data fixes or imports.
+// A cache column only takes the stored row when that row is at least as new as
+// its last_*_observed_at; writers bump the cache without always storing a row.
+// Admin only: it scans the full price hypertables.
+// POST /api/v1/admin/items/recompute-cache
+func (h *PriceHandler) RecomputeItemCache(w http.ResponseWriter, r *http.Request) {
+	tag, err := h.db.Pool.Exec(r.Context(), `
+		WITH m AS (
+			SELECT DISTINCT ON (item_id) item_id, price, time
+			FROM market_prices
+			ORDER BY item_id, time DESC
+		), b AS (
+			SELECT DISTINCT ON (item_id) item_id, price, time
+			FROM bazaar_prices
+			ORDER BY item_id, time DESC
+		), newer AS (
+			SELECT i.id, i.last_market_price, i.last_market_observed_at, i.last_bazaar_price, i.last_bazaar_observed_at,
+				m.price AS m_price, m.time AS m_time, b.price AS b_price, b.time AS b_time,
+				m.time IS NOT NULL AND (i.last_market_observed_at IS NULL OR m.time >= i.last_market_observed_at) AS use_m,
+				b.time IS NOT NULL AND (i.last_bazaar_observed_at IS NULL OR b.time >= i.last_bazaar_observed_at) AS use_b
+			FROM items i
+			LEFT JOIN m ON m.item_id = i.id
+			LEFT JOIN b ON b.item_id = i.id
+			WHERE m.price IS NOT NULL OR b.price IS NOT NULL
+		), latest AS (
+			SELECT id,
+				CASE WHEN use_m THEN m_price ELSE last_market_price END AS market,
+				CASE WHEN use_m THEN m_time ELSE last_market_observed_at END AS market_at,
+				CASE WHEN use_b THEN b_price ELSE last_bazaar_price END AS bazaar,
+				CASE WHEN use_b THEN b_time ELSE last_bazaar_observed_at END AS bazaar_at
+			FROM newer
+		)
+		UPDATE items SET
+			last_market_price = latest.market, last_market_observed_at = latest.market_at,
+			last_bazaar_price = latest.bazaar, last_bazaar_observed_at = latest.bazaar_at
+		FROM latest
+		WHERE items.id = latest.id
+			AND (items.last_market_price IS DISTINCT FROM latest.market
+				OR items.last_market_observed_at IS DISTINCT FROM latest.market_at
+				OR items.last_bazaar_price IS DISTINCT FROM latest.bazaar
+				OR items.last_bazaar_observed_at IS DISTINCT FROM latest.bazaar_at)
+	`)
+	if err != nil {
+		fmt.Printf("Database error in RecomputeItemCache: %v\n", err)
+		writeError(w, http.StatusInternalServerError, ErrCodeDatabase, "Database error")
+		return
 	}
 
+	h.tracked.invalidate()
+
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(history)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"updated": tag.RowsAffected(),
+	})
 }
 
 // GetMarketSummary returns items with largest price movements in the last 24h
@@ -616,7 +2129,7 @@ func (h *PriceHandler) GetMarketSummary(w http.ResponseWriter, r *http.Request)
 	rows, err := h.db.Pool.Query(r.Context(), query)
 	if err != nil {
 		fmt.Printf("Database error in GetMarketSummary: %v\n", err)
-		http.Error(w, "Database error", http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, ErrCodeDatabase, "Database error")
 		return
 	}
 	defer rows.Close()
@@ -654,7 +2167,7 @@ func NewWebhookHandler(db *database.DB) *WebhookHandler {
 func (h *WebhookHandler) HandleUpdate(w http.ResponseWriter, r *http.Request) {
 	var payload models.WebhookPayload
 	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
-		http.Error(w, "Invalid JSON payload", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, ErrCodeInvalidBody, "Invalid JSON payload")
 		return
 	}
 
@@ -673,6 +2186,10 @@ func (h *WebhookHandler) HandleUpdate(w http.ResponseWriter, r *http.Request) {
 			continue // Item not tracked or DB error
 		}
 
+		if item.Quantity < 0 {
+			continue
+		}
+
 		ts := now
 		if item.Timestamp > 0 {
 			ts = time.Unix(item.Timestamp, 0)
@@ -681,13 +2198,13 @@ func (h *WebhookHandler) HandleUpdate(w http.ResponseWriter, r *http.Request) {
 		if item.Type == "market" {
 			// Insert into market_prices
 			_, err = h.db.Pool.Exec(ctx,
-				"INSERT INTO market_prices (time, item_id, price) VALUES ($1, $2, $3)",
-				ts, itemID, item.Price,
+				"INSERT INTO market_prices (time, item_id, price, quantity, source) VALUES ($1, $2, $3, $4, $5)",
+				ts, itemID, item.Price, item.Quantity, models.PriceSourceWebhook,
 			)
 			if err == nil {
 				// Update item cache
 				h.db.Pool.Exec(ctx,
-					"UPDATE items SET last_market_price = $1, last_updated_at = $2 WHERE id = $3",
+					"UPDATE items SET last_market_price = $1, last_market_observed_at = $2, last_updated_at = $2 WHERE id = $3",
 					item.Price, now, itemID,
 				)
 				processed++
@@ -695,13 +2212,13 @@ func (h *WebhookHandler) HandleUpdate(w http.ResponseWriter, r *http.Request) {
 		} else if item.Type == "bazaar" {
 			// Insert into bazaar_prices
 			_, err = h.db.Pool.Exec(ctx,
-				"INSERT INTO bazaar_prices (time, item_id, price, quantity, seller_id, listing_id) VALUES ($1, $2, $3, $4, $5, $6)",
-				ts, itemID, item.Price, 0, item.SellerID, item.ListingID,
+				"INSERT INTO bazaar_prices (time, item_id, price, quantity, seller_id, listing_id, source) VALUES ($1, $2, $3, $4, $5, $6, $7)",
+				ts, itemID, item.Price, item.Quantity, item.SellerID, item.ListingID, models.PriceSourceWebhook,
 			)
 			if err == nil {
 				// Update item cache
 				h.db.Pool.Exec(ctx,
-					"UPDATE items SET last_bazaar_price = $1, last_updated_at = $2 WHERE id = $3",
+					"UPDATE items SET last_bazaar_price = $1, last_bazaar_observed_at = $2, last_updated_at = $2 WHERE id = $3",
 					item.Price, now, itemID,
 				)
 				processed++
@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/akagifreeez/torn-market-chart/pkg/tornapi"
+)
+
+// inventoryExternalTimeout bounds all external lookups for one valuation
+const inventoryExternalTimeout = 10 * time.Second
+
+// Price sources for a valued inventory item
+const (
+	priceSourceMarket   = "market"   // Our latest item market price
+	priceSourceBazaar   = "bazaar"   // Our latest bazaar price, when no market price is stored
+	priceSourceExternal = "external" // Weav3r / TornExchange, for items we don't track
+	priceSourceTorn     = "torn"     // Torn's own market value from the inventory response
+)
+
+// ValuedInventoryItem is an inventory stack with its unit price and value
+type ValuedInventoryItem struct {
+	tornapi.TornInventoryItem
+	Tracked     bool   `json:"tracked"`
+	UnitPrice   int64  `json:"unit_price"`
+	Value       int64  `json:"value"`
+	PriceSource string `json:"price_source,omitempty"` // Empty when no price was found
+}
+
+// InventoryValuation is a priced inventory, most valuable stacks first
+type InventoryValuation struct {
+	Items           []ValuedInventoryItem `json:"items"`
+	TotalValue      int64                 `json:"total_value"`
+	PricedItems     int                   `json:"priced_items"`
+	UnpricedItems   int                   `json:"unpriced_items"`
+	ExternalLookups int                   `json:"external_lookups"`
+	ExternalSkipped int                   `json:"external_skipped,omitempty"` // Untracked items beyond the per-request cap
+}
+
+// catalogPrice is our stored pricing for one item
+type catalogPrice struct {
+	tracked bool
+	market  int64
+	bazaar  int64
+}
+
+// loadCatalogPrices fetches stored prices for every inventory item in one query
+func (h *KeyHandler) loadCatalogPrices(ctx context.Context, items []tornapi.TornInventoryItem) (map[int64]catalogPrice, error) {
+	ids := make([]int64, 0, len(items))
+	seen := make(map[int64]bool, len(items))
+	for _, item := range items {
+		if !seen[item.ID] {
+			seen[item.ID] = true
+			ids = append(ids, item.ID)
+		}
+	}
+
+	prices := make(map[int64]catalogPrice, len(ids))
+	if len(ids) == 0 {
+		return prices, nil
+	}
+
+	rows, err := h.db.Pool.Query(ctx, `
+		SELECT id, COALESCE(is_tracked, false), COALESCE(last_market_price, 0), COALESCE(last_bazaar_price, 0)
+		FROM items
+		WHERE id = ANY($1)
+	`, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int64
+		var p catalogPrice
+		if err := rows.Scan(&id, &p.tracked, &p.market, &p.bazaar); err != nil {
+			return nil, err
+		}
+		prices[id] = p
+	}
+	return prices, rows.Err()
+}
+
+// loadExternalPrices looks up up to h.externalMax untracked items concurrently,
+// returning the prices found and how many items the cap left out. Failed or
+// empty lookups are simply absent from the result.
+func (h *KeyHandler) loadExternalPrices(ctx context.Context, ids []int64) (map[int64]int64, int) {
+	skipped := 0
+	if len(ids) > h.externalMax {
+		skipped = len(ids) - h.externalMax
+		ids = ids[:h.externalMax]
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, inventoryExternalTimeout)
+	defer cancel()
+
+	var (
+		mu  sync.Mutex
+		wg  sync.WaitGroup
+		sem = make(chan struct{}, h.externalConcurrency)
+	)
+	prices := make(map[int64]int64, len(ids))
+
+	for _, itemID := range ids {
+		wg.Add(1)
+		go func(itemID int64) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			overlay := h.external.GetTraderPriceOverlay(ctx, itemID)
+			price := overlay.Weav3rMinBazaar
+			if price <= 0 {
+				price = overlay.TornMarketPrice
+			}
+			if price <= 0 {
+				return
+			}
+			mu.Lock()
+			prices[itemID] = price
+			mu.Unlock()
+		}(itemID)
+	}
+	wg.Wait()
+
+	return prices, skipped
+}
+
+// valueInventory prices each stack and totals the inventory. Tracked items use
+// our catalog prices; untracked ones prefer an external price when external is
+// set. Torn's reported market value is the last resort.
+func (h *KeyHandler) valueInventory(ctx context.Context, items []tornapi.TornInventoryItem, external bool) (*InventoryValuation, error) {
+	catalog, err := h.loadCatalogPrices(ctx, items)
+	if err != nil {
+		return nil, err
+	}
+
+	valuation := &InventoryValuation{Items: make([]ValuedInventoryItem, 0, len(items))}
+
+	var externalPrices map[int64]int64
+	if external && h.external != nil && h.externalMax > 0 {
+		var untracked []int64
+		seen := make(map[int64]bool)
+		for _, item := range items {
+			if !catalog[item.ID].tracked && !seen[item.ID] {
+				seen[item.ID] = true
+				untracked = append(untracked, item.ID)
+			}
+		}
+		if len(untracked) > 0 {
+			externalPrices, valuation.ExternalSkipped = h.loadExternalPrices(ctx, untracked)
+			valuation.ExternalLookups = len(untracked) - valuation.ExternalSkipped
+		}
+	}
+
+	for _, item := range items {
+		cat := catalog[item.ID]
+		valued := ValuedInventoryItem{TornInventoryItem: item, Tracked: cat.tracked}
+
+		if price, ok := externalPrices[item.ID]; ok {
+			valued.UnitPrice, valued.PriceSource = price, priceSourceExternal
+		} else if cat.market > 0 {
+			valued.UnitPrice, valued.PriceSource = cat.market, priceSourceMarket
+		} else if cat.bazaar > 0 {
+			valued.UnitPrice, valued.PriceSource = cat.bazaar, priceSourceBazaar
+		} else if item.MarketPrice > 0 {
+			valued.UnitPrice, valued.PriceSource = item.MarketPrice, priceSourceTorn
+		}
+
+		if valued.PriceSource == "" {
+			valuation.UnpricedItems++
+		} else {
+			valued.Value = valued.UnitPrice * item.Quantity
+			valuation.TotalValue += valued.Value
+			valuation.PricedItems++
+		}
+		valuation.Items = append(valuation.Items, valued)
+	}
+
+	sort.Slice(valuation.Items, func(i, j int) bool {
+		a, b := valuation.Items[i], valuation.Items[j]
+		if a.Value != b.Value {
+			return a.Value > b.Value
+		}
+		return a.ID < b.ID
+	})
+	return valuation, nil
+}
@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/akagifreeez/torn-market-chart/internal/models"
+)
+
+// trackedItemsTTL is how long the shared tracked-item list is served from memory
+const trackedItemsTTL = 30 * time.Second
+
+// trackedItemCache holds the user-agnostic list of tracked items. Per-user
+// flags are merged at request time so one entry serves everyone.
+type trackedItemCache struct {
+	mu       sync.Mutex
+	items    []models.Item
+	loadedAt time.Time
+}
+
+// get returns the cached list, reloading it via load when missing or expired.
+// Callers must not modify the returned slice.
+func (c *trackedItemCache) get(ctx context.Context, load func(ctx context.Context) ([]models.Item, error)) ([]models.Item, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.items != nil && time.Since(c.loadedAt) < trackedItemsTTL {
+		return c.items, nil
+	}
+
+	items, err := load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c.items = items
+	c.loadedAt = time.Now()
+	return items, nil
+}
+
+// invalidate drops the cached list so the next request reloads it
+func (c *trackedItemCache) invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.items = nil
+}
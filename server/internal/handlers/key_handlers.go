@@ -10,18 +10,29 @@ import (
 	"github.com/rs/zerolog/log"
 
 	"github.com/akagifreeez/torn-market-chart/internal/services"
+	"github.com/akagifreeez/torn-market-chart/pkg/database"
 	"github.com/akagifreeez/torn-market-chart/pkg/tornapi"
 )
 
 type KeyHandler struct {
 	keyManager *services.KeyManager
 	client     *tornapi.Client
+	db         *database.DB
+	external   *services.ExternalPriceClient
+
+	// Inventory valuation limits for external lookups on untracked items
+	externalConcurrency int
+	externalMax         int
 }
 
-func NewKeyHandler(km *services.KeyManager, client *tornapi.Client) *KeyHandler {
+func NewKeyHandler(km *services.KeyManager, client *tornapi.Client, db *database.DB, external *services.ExternalPriceClient, externalConcurrency, externalMax int) *KeyHandler {
 	return &KeyHandler{
-		keyManager: km,
-		client:     client,
+		keyManager:          km,
+		client:              client,
+		db:                  db,
+		external:            external,
+		externalConcurrency: max(externalConcurrency, 1),
+		externalMax:         max(externalMax, 0),
 	}
 }
 
@@ -32,18 +43,18 @@ func (h *KeyHandler) RegisterKey(w http.ResponseWriter, r *http.Request) {
 		Label string `json:"label"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
-		http.Error(w, "Invalid input", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, ErrCodeInvalidBody, "Invalid input")
 		return
 	}
 
 	if input.Key == "" {
-		http.Error(w, "Key is required", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, ErrCodeMissingField, "Key is required")
 		return
 	}
 
 	if err := h.keyManager.AddKey(r.Context(), input.Key, input.Label); err != nil {
 		log.Error().Err(err).Msg("Failed to register key")
-		http.Error(w, "Failed to register key", http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to register key")
 		return
 	}
 
@@ -55,7 +66,7 @@ func (h *KeyHandler) ListKeys(w http.ResponseWriter, r *http.Request) {
 	keys, err := h.keyManager.GetKeys(r.Context())
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to list keys")
-		http.Error(w, "Failed to list keys", http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to list keys")
 		return
 	}
 
@@ -67,20 +78,40 @@ func (h *KeyHandler) ListKeys(w http.ResponseWriter, r *http.Request) {
 func (h *KeyHandler) DeleteKey(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 	if id == "" {
-		http.Error(w, "ID is required", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, ErrCodeMissingField, "ID is required")
 		return
 	}
 
 	if err := h.keyManager.DeleteKey(r.Context(), id); err != nil {
 		log.Error().Err(err).Str("id", id).Msg("Failed to delete key")
-		http.Error(w, "Failed to delete key", http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to delete key")
 		return
 	}
 
 	w.WriteHeader(http.StatusOK)
 }
 
-// GetInventory fetches inventory using a stored key
+// GetKeyUsage returns per-user key health (usage, errors, last use), most
+// errors first. Counts lag by up to the KeyManager flush interval. Admin only.
+// GET /api/v1/admin/keys/usage
+func (h *KeyHandler) GetKeyUsage(w http.ResponseWriter, r *http.Request) {
+	stats, err := h.keyManager.UsageStats(r.Context())
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to load key usage stats")
+		writeError(w, http.StatusInternalServerError, ErrCodeDatabase, "Failed to load key usage stats")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
+
+// GetInventory fetches inventory using a stored key and values it against our
+// catalog in one batched query. With external=true, untracked items are also
+// priced from TornExchange/Weav3r (capped and concurrency-limited).
+// The response is the array of valued items; summary=true returns the
+// InventoryValuation object with totals instead.
+// GET /api/v1/user/inventory?key_id=...&external=true&summary=true
 func (h *KeyHandler) GetInventory(w http.ResponseWriter, r *http.Request) {
 	// Ideally we should get key ID from the request to know WHICH user's inventory to fetch
 	// For now, let's assume we pass the raw key in a header or query param?
@@ -88,7 +119,27 @@ func (h *KeyHandler) GetInventory(w http.ResponseWriter, r *http.Request) {
 
 	keyID := r.URL.Query().Get("key_id")
 	if keyID == "" {
-		http.Error(w, "key_id query parameter is required", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, ErrCodeMissingField, "key_id query parameter is required")
+		return
+	}
+
+	external := false
+	switch r.URL.Query().Get("external") {
+	case "", "false":
+	case "true":
+		external = true
+	default:
+		writeError(w, http.StatusBadRequest, ErrCodeInvalidParameter, "external must be true or false")
+		return
+	}
+
+	summary := false
+	switch r.URL.Query().Get("summary") {
+	case "", "false":
+	case "true":
+		summary = true
+	default:
+		writeError(w, http.StatusBadRequest, ErrCodeInvalidParameter, "summary must be true or false")
 		return
 	}
 
@@ -103,7 +154,7 @@ func (h *KeyHandler) GetInventory(w http.ResponseWriter, r *http.Request) {
 	dbKey, err := h.keyManager.GetKeyByID(r.Context(), keyID)
 	if err != nil {
 		log.Error().Err(err).Str("id", keyID).Msg("Failed to get key by ID")
-		http.Error(w, "Failed to get key", http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to get key")
 		return
 	}
 
@@ -111,14 +162,26 @@ func (h *KeyHandler) GetInventory(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		// Check for specific API error message indicating the feature is disabled
 		if strings.Contains(err.Error(), "The inventory selection is no longer available") {
-			http.Error(w, "Torn API Inventory feature is currently disabled by game developers", http.StatusServiceUnavailable)
+			writeError(w, http.StatusServiceUnavailable, ErrCodeFeatureUnavailable, "Torn API Inventory feature is currently disabled by game developers")
 			return
 		}
 		log.Error().Err(err).Msg("Failed to fetch inventory")
-		http.Error(w, fmt.Sprintf("Failed to fetch inventory: %v", err), http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, ErrCodeUpstream, fmt.Sprintf("Failed to fetch inventory: %v", err))
+		return
+	}
+
+	valuation, err := h.valueInventory(r.Context(), items, external)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to value inventory")
+		writeError(w, http.StatusInternalServerError, ErrCodeDatabase, "Failed to value inventory")
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(items)
+	if summary {
+		json.NewEncoder(w).Encode(valuation)
+		return
+	}
+	// Existing clients expect an array; valued items extend the old item fields
+	json.NewEncoder(w).Encode(valuation.Items)
 }
@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/akagifreeez/torn-market-chart/internal/services"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	maxLiveSubscriptions = 200 // Items one connection may watch
+	liveWriteTimeout     = 10 * time.Second
+	livePongTimeout      = 60 * time.Second
+	livePingInterval     = 50 * time.Second
+)
+
+// liveUpgrader accepts any origin: like the REST API (CORS "*"), clients
+// authenticate with a bearer token rather than cookies
+var liveUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	CheckOrigin:     func(r *http.Request) bool { return true },
+}
+
+// LiveHandler pushes live price updates to WebSocket clients
+type LiveHandler struct {
+	hub *services.PriceHub
+}
+
+func NewLiveHandler(hub *services.PriceHub) *LiveHandler {
+	return &LiveHandler{hub: hub}
+}
+
+// liveRequest is a client message: {"action":"subscribe","item_ids":[1,2]}
+type liveRequest struct {
+	Action  string  `json:"action"` // "subscribe" or "unsubscribe"
+	ItemIDs []int64 `json:"item_ids"`
+}
+
+// liveMessage is a server message. Price updates have type "price"; replies to
+// requests have type "subscribed" (with the watched count) or "error".
+type liveMessage struct {
+	Type  string              `json:"type"`
+	Price *services.LivePrice `json:"price,omitempty"`
+	Count *int                `json:"count,omitempty"`
+	Error string              `json:"error,omitempty"`
+}
+
+// QueryTokenMiddleware lets clients that cannot set headers (browser
+// WebSockets) pass their JWT as ?token= on the given paths. The token is moved
+// into the Authorization header and stripped from the URL, so installing this
+// ahead of the request logger keeps tokens out of access logs.
+func QueryTokenMiddleware(paths ...string) func(http.Handler) http.Handler {
+	allowed := make(map[string]bool, len(paths))
+	for _, p := range paths {
+		allowed[p] = true
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			query := r.URL.Query()
+			if !query.Has("token") {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if allowed[r.URL.Path] && r.Header.Get("Authorization") == "" {
+				if token := query.Get("token"); token != "" {
+					r.Header.Set("Authorization", "Bearer "+token)
+				}
+			}
+			query.Del("token")
+			r.URL.RawQuery = query.Encode()
+			r.RequestURI = r.URL.RequestURI()
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// ServeWS upgrades to a WebSocket and streams price updates for the items the
+// client subscribes to. Clients that fall too far behind are disconnected.
+// GET /api/v1/ws?token=JWT
+func (h *LiveHandler) ServeWS(w http.ResponseWriter, r *http.Request) {
+	if _, ok := GetUserIDFromContext(r.Context()); !ok {
+		writeError(w, http.StatusUnauthorized, ErrCodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	conn, err := liveUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return // Upgrade has already replied with an error
+	}
+	defer conn.Close()
+
+	sub := h.hub.Subscribe()
+	defer sub.Close()
+
+	// Writes come from both the push loop and request replies
+	var writeMu sync.Mutex
+	write := func(msgType int, data []byte) error {
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		conn.SetWriteDeadline(time.Now().Add(liveWriteTimeout))
+		return conn.WriteMessage(msgType, data)
+	}
+	writeJSON := func(msg liveMessage) error {
+		data, err := json.Marshal(msg)
+		if err != nil {
+			return err
+		}
+		return write(websocket.TextMessage, data)
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		ping := time.NewTicker(livePingInterval)
+		defer ping.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case p, ok := <-sub.C:
+				if !ok {
+					// Dropped by the hub as a slow consumer
+					write(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "too slow"))
+					conn.Close()
+					return
+				}
+				if err := writeJSON(liveMessage{Type: "price", Price: &p}); err != nil {
+					conn.Close()
+					return
+				}
+			case <-ping.C:
+				if err := write(websocket.PingMessage, nil); err != nil {
+					conn.Close()
+					return
+				}
+			}
+		}
+	}()
+
+	conn.SetReadLimit(4096)
+	conn.SetReadDeadline(time.Now().Add(livePongTimeout))
+	conn.SetPongHandler(func(string) error {
+		conn.SetReadDeadline(time.Now().Add(livePongTimeout))
+		return nil
+	})
+
+	for {
+		var req liveRequest
+		if err := conn.ReadJSON(&req); err != nil {
+			return // Disconnected, timed out or sent a malformed frame
+		}
+		conn.SetReadDeadline(time.Now().Add(livePongTimeout))
+
+		var count int
+		switch req.Action {
+		case "subscribe":
+			if sub.Count()+len(req.ItemIDs) > maxLiveSubscriptions {
+				writeJSON(liveMessage{Type: "error", Error: fmt.Sprintf("at most %d items can be watched", maxLiveSubscriptions)})
+				continue
+			}
+			count = sub.Watch(req.ItemIDs...)
+		case "unsubscribe":
+			count = sub.Unwatch(req.ItemIDs...)
+		default:
+			writeJSON(liveMessage{Type: "error", Error: "action must be subscribe or unsubscribe"})
+			continue
+		}
+		writeJSON(liveMessage{Type: "subscribed", Count: &count})
+	}
+}
@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// GetMeta describes API-wide conventions so clients can discover them in-band
+// GET /api/v1/meta
+func GetMeta(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"version": "v1",
+		"pagination": map[string]interface{}{
+			"endpoints": []string{
+				"GET /api/v1/items",
+				"GET /api/v1/items/search",
+				"GET /api/v1/items/watched",
+			},
+			"params": map[string]string{
+				"envelope": "true to wrap the list in the envelope below; omitted or false returns a bare array",
+				"limit":    "page size, 1-500 (enveloped responses only)",
+				"offset":   "number of items to skip (enveloped responses only)",
+			},
+			"envelope": map[string]string{
+				"data":   "array of items in this page",
+				"total":  "number of items across all pages",
+				"limit":  "page size applied",
+				"offset": "offset applied",
+			},
+		},
+	})
+}
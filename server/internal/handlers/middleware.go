@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"context"
+	"crypto/subtle"
 	"fmt"
 	"net/http"
 	"os"
@@ -28,13 +29,13 @@ func AuthMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		authHeader := r.Header.Get("Authorization")
 		if authHeader == "" {
-			http.Error(w, "Unauthorized: No token provided", http.StatusUnauthorized)
+			writeError(w, http.StatusUnauthorized, ErrCodeMissingToken, "Unauthorized: No token provided")
 			return
 		}
 
 		bearerToken := strings.Split(authHeader, " ")
 		if len(bearerToken) != 2 || bearerToken[0] != "Bearer" {
-			http.Error(w, "Unauthorized: Invalid token format", http.StatusUnauthorized)
+			writeError(w, http.StatusUnauthorized, ErrCodeInvalidToken, "Unauthorized: Invalid token format")
 			return
 		}
 
@@ -56,13 +57,13 @@ func AuthMiddleware(next http.Handler) http.Handler {
 		})
 
 		if err != nil || !token.Valid {
-			http.Error(w, "Unauthorized: Invalid token", http.StatusUnauthorized)
+			writeError(w, http.StatusUnauthorized, ErrCodeInvalidToken, "Unauthorized: Invalid token")
 			return
 		}
 
 		// Check expiry
 		if claims.ExpiresAt.Time.Before(time.Now()) {
-			http.Error(w, "Unauthorized: Token expired", http.StatusUnauthorized)
+			writeError(w, http.StatusUnauthorized, ErrCodeTokenExpired, "Unauthorized: Token expired")
 			return
 		}
 
@@ -84,7 +85,7 @@ func OptionalAuthMiddleware(next http.Handler) http.Handler {
 
 		bearerToken := strings.Split(authHeader, " ")
 		if len(bearerToken) != 2 || bearerToken[0] != "Bearer" {
-			http.Error(w, "Unauthorized: Invalid token format", http.StatusUnauthorized)
+			writeError(w, http.StatusUnauthorized, ErrCodeInvalidToken, "Unauthorized: Invalid token format")
 			return
 		}
 
@@ -104,13 +105,13 @@ func OptionalAuthMiddleware(next http.Handler) http.Handler {
 		})
 
 		if err != nil || !token.Valid {
-			http.Error(w, "Unauthorized: Invalid token", http.StatusUnauthorized)
+			writeError(w, http.StatusUnauthorized, ErrCodeInvalidToken, "Unauthorized: Invalid token")
 			return
 		}
 
 		// Check expiry
 		if claims.ExpiresAt.Time.Before(time.Now()) {
-			http.Error(w, "Unauthorized: Token expired", http.StatusUnauthorized)
+			writeError(w, http.StatusUnauthorized, ErrCodeTokenExpired, "Unauthorized: Token expired")
 			return
 		}
 
@@ -120,6 +121,53 @@ func OptionalAuthMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+// BotSecretHeader carries the shared secret the Discord bot sends on /bot routes
+const BotSecretHeader = "X-Bot-Secret"
+
+// BotSecretMiddleware admits only requests carrying BOT_API_SECRET. The /bot
+// routes act on behalf of any Discord user, so they fail closed when the
+// secret is not configured.
+func BotSecretMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		secret := os.Getenv("BOT_API_SECRET")
+		if secret == "" {
+			fmt.Println("WARNING: BOT_API_SECRET not set, rejecting bot API request")
+			writeError(w, http.StatusUnauthorized, ErrCodeUnauthorized, "Unauthorized: Bot API is not configured")
+			return
+		}
+
+		provided := r.Header.Get(BotSecretHeader)
+		if subtle.ConstantTimeCompare([]byte(provided), []byte(secret)) != 1 {
+			writeError(w, http.StatusUnauthorized, ErrCodeUnauthorized, "Unauthorized")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// AdminMiddleware admits only authenticated users listed in adminIDs. It must
+// run after AuthMiddleware; with no admins configured every request is refused.
+func AdminMiddleware(adminIDs []int64) func(http.Handler) http.Handler {
+	admins := make(map[int64]bool, len(adminIDs))
+	for _, id := range adminIDs {
+		admins[id] = true
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userID, ok := GetUserIDFromContext(r.Context())
+			if !ok {
+				writeError(w, http.StatusUnauthorized, ErrCodeUnauthorized, "Unauthorized")
+				return
+			}
+			if !admins[userID] {
+				writeError(w, http.StatusForbidden, ErrCodeForbidden, "Admin access required")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
 // GetUserIDFromContext helper to retrieve user ID
 func GetUserIDFromContext(ctx context.Context) (int64, bool) {
 	userID, ok := ctx.Value(UserContextKey).(int64)
@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// maxPageLimit caps the limit param of enveloped list responses
+const maxPageLimit = 500
+
+// Page is the envelope for paginated list responses:
+// {"data":[...],"total":N,"limit":L,"offset":O}
+type Page struct {
+	Data   interface{} `json:"data"`
+	Total  int         `json:"total"`
+	Limit  int         `json:"limit"`
+	Offset int         `json:"offset"`
+}
+
+// pageParams holds the parsed envelope/limit/offset query params
+type pageParams struct {
+	Enveloped bool
+	Limit     int
+	Offset    int
+}
+
+// parsePageParams reads ?envelope=true&limit=&offset=. Lists stay bare arrays
+// unless envelope=true, so existing clients are unaffected; limit and offset
+// only apply to enveloped responses.
+func parsePageParams(q url.Values, defaultLimit int) (pageParams, error) {
+	p := pageParams{Limit: defaultLimit}
+	switch q.Get("envelope") {
+	case "", "false":
+		return p, nil
+	case "true":
+		p.Enveloped = true
+	default:
+		return p, fmt.Errorf("envelope must be true or false")
+	}
+
+	if raw := q.Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 || n > maxPageLimit {
+			return p, fmt.Errorf("limit must be between 1 and %d", maxPageLimit)
+		}
+		p.Limit = n
+	}
+	if raw := q.Get("offset"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 {
+			return p, fmt.Errorf("offset must be a non-negative integer")
+		}
+		p.Offset = n
+	}
+	return p, nil
+}
+
+// writeList encodes items as a bare array, or as a Page slice of them when the
+// caller asked for the envelope
+func writeList[T any](w http.ResponseWriter, items []T, p pageParams) {
+	w.Header().Set("Content-Type", "application/json")
+	if !p.Enveloped {
+		json.NewEncoder(w).Encode(items)
+		return
+	}
+
+	start := min(p.Offset, len(items))
+	end := min(start+p.Limit, len(items))
+	json.NewEncoder(w).Encode(Page{
+		Data:   items[start:end],
+		Total:  len(items),
+		Limit:  p.Limit,
+		Offset: p.Offset,
+	})
+}
+
+// writePage is writeList for items the query already limited to p's window;
+// total is the full match count
+func writePage[T any](w http.ResponseWriter, items []T, total int, p pageParams) {
+	w.Header().Set("Content-Type", "application/json")
+	if !p.Enveloped {
+		json.NewEncoder(w).Encode(items)
+		return
+	}
+
+	json.NewEncoder(w).Encode(Page{
+		Data:   items,
+		Total:  total,
+		Limit:  p.Limit,
+		Offset: p.Offset,
+	})
+}
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/akagifreeez/torn-market-chart/pkg/tornapi"
+)
+
+// RateLimitHandler exposes the process's rate limiters for inspection and reset
+type RateLimitHandler struct {
+	limiters map[string]tornapi.Limiter // By name, e.g. "torn_api"
+}
+
+func NewRateLimitHandler(limiters map[string]tornapi.Limiter) *RateLimitHandler {
+	return &RateLimitHandler{limiters: limiters}
+}
+
+// RateLimitState is one limiter's entry in the rate-limit report
+type RateLimitState struct {
+	Name string `json:"name"`
+	tornapi.LimiterState
+	Error string `json:"error,omitempty"`
+}
+
+// GetRateLimits reports the current window count and configured limit of each limiter.
+// Limiters sharing a Redis base key report the same count.
+// GET /api/v1/admin/rate-limits
+func (h *RateLimitHandler) GetRateLimits(w http.ResponseWriter, r *http.Request) {
+	names := make([]string, 0, len(h.limiters))
+	for name := range h.limiters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	states := make([]RateLimitState, 0, len(names))
+	for _, name := range names {
+		entry := RateLimitState{Name: name}
+		if l, ok := h.limiters[name].(tornapi.InspectableLimiter); ok {
+			state, err := l.State(r.Context())
+			if err != nil {
+				entry.Error = err.Error()
+			}
+			entry.LimiterState = state
+		} else {
+			entry.Error = "limiter does not support inspection"
+		}
+		states = append(states, entry)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(states)
+}
+
+// ResetRateLimit clears a limiter's current window, e.g. after raising its limit
+// POST /api/v1/admin/rate-limits/{name}/reset
+func (h *RateLimitHandler) ResetRateLimit(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	limiter, ok := h.limiters[name]
+	if !ok {
+		writeError(w, http.StatusNotFound, ErrCodeInvalidParameter, "Unknown rate limiter")
+		return
+	}
+	l, ok := limiter.(tornapi.InspectableLimiter)
+	if !ok {
+		writeError(w, http.StatusBadRequest, ErrCodeFeatureUnavailable, "Rate limiter does not support reset")
+		return
+	}
+	if err := l.Reset(r.Context()); err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to reset rate limiter: "+err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "reset", "name": name})
+}
@@ -0,0 +1,250 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"golang.org/x/time/rate"
+
+	"github.com/akagifreeez/torn-market-chart/internal/models"
+	"github.com/akagifreeez/torn-market-chart/internal/services"
+	"github.com/akagifreeez/torn-market-chart/pkg/database"
+	"github.com/akagifreeez/torn-market-chart/pkg/tornapi"
+)
+
+// Per-user refresh allowance: one every refreshInterval, bursting to refreshBurst
+const (
+	refreshInterval = 30 * time.Second
+	refreshBurst    = 3
+	refreshTimeout  = 20 * time.Second
+
+	// refreshSweepInterval is how often full (idle) per-user limiters are
+	// dropped; a limiter refills completely within this time
+	refreshSweepInterval = refreshInterval * refreshBurst
+)
+
+// RefreshResponse carries the freshly fetched prices; a source that failed
+// reports its error instead of a price
+type RefreshResponse struct {
+	ItemID      int64     `json:"item_id"`
+	MarketPrice int64     `json:"market_price,omitempty"`
+	BazaarPrice int64     `json:"bazaar_price,omitempty"`
+	MarketError string    `json:"market_error,omitempty"`
+	BazaarError string    `json:"bazaar_error,omitempty"`
+	RefreshedAt time.Time `json:"refreshed_at"`
+}
+
+// RefreshHandler serves on-demand single-item price refreshes
+type RefreshHandler struct {
+	db            *database.DB
+	client        *tornapi.Client
+	keyManager    *services.KeyManager
+	external      *services.ExternalPriceClient
+	bazaarLimiter tornapi.Limiter
+	throttle      *services.PriceThrottle
+	onRefresh     func() // e.g. tracked-list cache invalidation
+
+	mu        sync.Mutex
+	limiters  map[int64]*rate.Limiter // Per-user
+	lastSweep time.Time               // When idle limiters were last dropped
+}
+
+func NewRefreshHandler(db *database.DB, client *tornapi.Client, km *services.KeyManager, external *services.ExternalPriceClient,
+	bazaarLimiter tornapi.Limiter, throttle *services.PriceThrottle, onRefresh func()) *RefreshHandler {
+	if bazaarLimiter == nil {
+		bazaarLimiter = tornapi.NoopLimiter{}
+	}
+	return &RefreshHandler{
+		db:            db,
+		client:        client,
+		keyManager:    km,
+		external:      external,
+		bazaarLimiter: bazaarLimiter,
+		throttle:      throttle,
+		onRefresh:     onRefresh,
+		limiters:      make(map[int64]*rate.Limiter),
+	}
+}
+
+// allow consumes one refresh from the user's allowance. Limiters that have
+// refilled completely are dropped, since a new one behaves the same.
+func (h *RefreshHandler) allow(userID int64) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(h.lastSweep) >= refreshSweepInterval {
+		for id, l := range h.limiters {
+			if l.TokensAt(now) >= refreshBurst {
+				delete(h.limiters, id)
+			}
+		}
+		h.lastSweep = now
+	}
+
+	l, ok := h.limiters[userID]
+	if !ok {
+		l = rate.NewLimiter(rate.Every(refreshInterval), refreshBurst)
+		h.limiters[userID] = l
+	}
+	return l.Allow()
+}
+
+// RefreshItem fetches the item's market (Torn API) and bazaar (Weav3r) prices
+// now, stores them, and returns the fresh values
+// POST /api/v1/items/{id}/refresh
+func (h *RefreshHandler) RefreshItem(w http.ResponseWriter, r *http.Request) {
+	userID, ok := GetUserIDFromContext(r.Context())
+	if !ok {
+		writeError(w, http.StatusUnauthorized, ErrCodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	itemID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, ErrCodeInvalidItemID, "Invalid item ID")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), refreshTimeout)
+	defer cancel()
+
+	// Validate the item before spending any of the user's allowance
+	var exists bool
+	if err := h.db.Pool.QueryRow(ctx, "SELECT EXISTS(SELECT 1 FROM items WHERE id = $1)", itemID).Scan(&exists); err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeDatabase, "Database error")
+		return
+	}
+	if !exists {
+		writeError(w, http.StatusNotFound, ErrCodeItemNotFound, "Item not found")
+		return
+	}
+
+	if !h.allow(userID) {
+		w.Header().Set("Retry-After", strconv.Itoa(int(refreshInterval.Seconds())))
+		writeError(w, http.StatusTooManyRequests, ErrCodeRateLimited, "Too many refreshes, try again shortly")
+		return
+	}
+
+	resp := RefreshResponse{ItemID: itemID, RefreshedAt: time.Now()}
+	var marketQty, bazaarQty, sellerID int64
+
+	// Fetch both sources concurrently; each goes through its own rate limiter
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		var mErr error
+		resp.MarketPrice, marketQty, mErr = h.fetchMarket(ctx, itemID)
+		if mErr != nil {
+			resp.MarketError = mErr.Error()
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		var bErr error
+		resp.BazaarPrice, bazaarQty, sellerID, bErr = h.fetchBazaar(ctx, itemID)
+		if bErr != nil {
+			resp.BazaarError = bErr.Error()
+		}
+	}()
+	wg.Wait()
+
+	if resp.MarketPrice == 0 && resp.BazaarPrice == 0 {
+		writeError(w, http.StatusBadGateway, ErrCodeUpstream, "Failed to refresh prices")
+		return
+	}
+
+	if err := h.store(ctx, itemID, resp, marketQty, bazaarQty, sellerID); err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeDatabase, "Database error")
+		return
+	}
+	if h.onRefresh != nil {
+		h.onRefresh()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// fetchMarket returns the cheapest item market listing using a pooled key
+func (h *RefreshHandler) fetchMarket(ctx context.Context, itemID int64) (price, qty int64, err error) {
+	key := h.keyManager.GetNextKey()
+	var data *tornapi.TornMarketResponse
+	if key != "" {
+		data, err = h.client.FetchMarketPriceWithKey(ctx, itemID, key)
+		h.keyManager.RecordUsage(key, err)
+	} else {
+		data, err = h.client.FetchMarketPrice(ctx, itemID)
+	}
+	if err != nil {
+		return 0, 0, fmt.Errorf("market fetch failed")
+	}
+	if data.ItemMarket == nil || len(data.ItemMarket.Listings) == 0 {
+		return 0, 0, fmt.Errorf("no market listings")
+	}
+	l := data.ItemMarket.Listings[0]
+	return l.Price, l.Quantity, nil
+}
+
+// fetchBazaar returns the cheapest Weav3r bazaar listing
+func (h *RefreshHandler) fetchBazaar(ctx context.Context, itemID int64) (price, qty, sellerID int64, err error) {
+	if err := h.bazaarLimiter.WaitForTicket(ctx, 1); err != nil {
+		return 0, 0, 0, fmt.Errorf("bazaar rate limited")
+	}
+	data, err := h.external.FetchWeav3rMarketplace(ctx, itemID)
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("bazaar fetch failed")
+	}
+	if len(data.Listings) == 0 {
+		return 0, 0, 0, fmt.Errorf("no bazaar listings")
+	}
+	cheapest := data.Listings[0]
+	for _, l := range data.Listings {
+		if l.Price < cheapest.Price {
+			cheapest = l
+		}
+	}
+	return cheapest.Price, cheapest.Quantity, cheapest.SellerID, nil
+}
+
+// store writes the fetched prices to the hypertables and the item cache columns
+func (h *RefreshHandler) store(ctx context.Context, itemID int64, resp RefreshResponse, marketQty, bazaarQty, sellerID int64) error {
+	now := resp.RefreshedAt
+
+	if resp.MarketPrice > 0 && h.throttle.ShouldStore("market", itemID, resp.MarketPrice, now) {
+		if _, err := h.db.Pool.Exec(ctx, `
+			INSERT INTO market_prices (time, item_id, price, quantity, source)
+			VALUES ($1, $2, $3, $4, $5)
+		`, now, itemID, resp.MarketPrice, marketQty, models.PriceSourceRefresh); err != nil {
+			return err
+		}
+		h.throttle.Stored("market", itemID, resp.MarketPrice, now)
+	}
+	if resp.BazaarPrice > 0 && h.throttle.ShouldStore("bazaar", itemID, resp.BazaarPrice, now) {
+		if _, err := h.db.Pool.Exec(ctx, `
+			INSERT INTO bazaar_prices (time, item_id, price, quantity, seller_id, source)
+			VALUES ($1, $2, $3, $4, $5, $6)
+		`, now, itemID, resp.BazaarPrice, bazaarQty, sellerID, models.PriceSourceRefresh); err != nil {
+			return err
+		}
+		h.throttle.Stored("bazaar", itemID, resp.BazaarPrice, now)
+	}
+
+	_, err := h.db.Pool.Exec(ctx, `
+		UPDATE items SET
+			last_market_price = COALESCE(NULLIF($1, 0), last_market_price),
+			last_bazaar_price = COALESCE(NULLIF($2, 0), last_bazaar_price),
+			last_market_observed_at = CASE WHEN $1 > 0 THEN $3 ELSE last_market_observed_at END,
+			last_bazaar_observed_at = CASE WHEN $2 > 0 THEN $3 ELSE last_bazaar_observed_at END,
+			last_updated_at = $3
+		WHERE id = $4
+	`, resp.MarketPrice, resp.BazaarPrice, now, itemID)
+	return err
+}
@@ -3,6 +3,8 @@ package handlers
 import (
 	"encoding/json"
 	"net/http"
+	"net/mail"
+	"time"
 
 	"github.com/akagifreeez/torn-market-chart/internal/services"
 )
@@ -15,16 +17,26 @@ func NewSettingsHandler(service *services.SettingsService) *SettingsHandler {
 	return &SettingsHandler{service: service}
 }
 
+// GetSettings lists the system settings with secrets masked. Admin only.
 func (h *SettingsHandler) GetSettings(w http.ResponseWriter, r *http.Request) {
 	settings, err := h.service.GetAll(r.Context())
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, err.Error())
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(settings)
 }
 
+// credentialSettings are system settings the workers reload as live
+// credentials. They stay masked whatever the request says.
+var credentialSettings = map[string]bool{
+	"TORN_API_KEY":  true,
+	"TORN_WS_TOKEN": true,
+}
+
+// UpdateSetting creates or replaces a system setting. Admin only: the
+// workers reload TORN_API_KEY and TORN_WS_TOKEN from here.
 func (h *SettingsHandler) UpdateSetting(w http.ResponseWriter, r *http.Request) {
 	var req struct {
 		Key         string `json:"key"`
@@ -33,19 +45,21 @@ func (h *SettingsHandler) UpdateSetting(w http.ResponseWriter, r *http.Request)
 		IsSecret    bool   `json:"is_secret"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, ErrCodeInvalidBody, "Invalid request body")
 		return
 	}
 
 	if req.Key == "" {
-		http.Error(w, "Key is required", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, ErrCodeMissingField, "Key is required")
 		return
 	}
 
-	// Basic validation or filtering could be added here
+	if credentialSettings[req.Key] {
+		req.IsSecret = true
+	}
 
 	if err := h.service.Set(r.Context(), req.Key, req.Value, req.Description, req.IsSecret); err != nil {
-		http.Error(w, "Failed to update setting", http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to update setting")
 		return
 	}
 
@@ -58,15 +72,24 @@ func (h *SettingsHandler) GetUserSettings(w http.ResponseWriter, r *http.Request
 	ctx := r.Context()
 	userID, ok := GetUserIDFromContext(ctx)
 	if !ok {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		writeError(w, http.StatusUnauthorized, ErrCodeUnauthorized, "Unauthorized")
 		return
 	}
 
-	keys := []string{"discord_webhook_url", "global_webhook_enabled", "discord_dm_enabled"}
+	// Keys and their defaults when the user hasn't set them
+	keys := map[string]string{
+		"discord_webhook_url":    "",
+		"global_webhook_enabled": "",
+		"discord_dm_enabled":     "",
+		"email_alerts_enabled":   "false",
+		"alert_email":            "",
+		defaultPriceTypeKey:      "market",
+		timezoneKey:              "UTC",
+	}
 	settings := make(map[string]string)
 
-	for _, key := range keys {
-		val, err := h.service.GetForUser(ctx, userID, key, "")
+	for key, def := range keys {
+		val, err := h.service.GetForUser(ctx, userID, key, def)
 		if err != nil {
 			continue
 		}
@@ -82,7 +105,7 @@ func (h *SettingsHandler) UpdateUserSetting(w http.ResponseWriter, r *http.Reque
 	ctx := r.Context()
 	userID, ok := GetUserIDFromContext(ctx)
 	if !ok {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		writeError(w, http.StatusUnauthorized, ErrCodeUnauthorized, "Unauthorized")
 		return
 	}
 
@@ -91,7 +114,7 @@ func (h *SettingsHandler) UpdateUserSetting(w http.ResponseWriter, r *http.Reque
 		Value string `json:"value"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, ErrCodeInvalidBody, "Invalid request body")
 		return
 	}
 
@@ -99,15 +122,43 @@ func (h *SettingsHandler) UpdateUserSetting(w http.ResponseWriter, r *http.Reque
 		"discord_webhook_url":    true,
 		"global_webhook_enabled": true,
 		"discord_dm_enabled":     true,
+		"email_alerts_enabled":   true,
+		"alert_email":            true,
+		defaultPriceTypeKey:      true,
+		timezoneKey:              true,
 	}
 
 	if !allowedKeys[req.Key] {
-		http.Error(w, "Invalid setting key", http.StatusBadRequest)
+		writeError(w, http.StatusBadRequest, ErrCodeInvalidParameter, "Invalid setting key")
+		return
+	}
+	if req.Key == defaultPriceTypeKey {
+		if _, ok := priceTypeParam(req.Value, ""); !ok || req.Value == "" {
+			writeError(w, http.StatusBadRequest, ErrCodeInvalidParameter, "default_price_type must be one of: market, bazaar")
+			return
+		}
+	}
+	if req.Key == "email_alerts_enabled" && req.Value != "true" && req.Value != "false" {
+		writeError(w, http.StatusBadRequest, ErrCodeInvalidParameter, "email_alerts_enabled must be true or false")
 		return
 	}
+	if req.Key == "alert_email" && req.Value != "" {
+		addr, err := mail.ParseAddress(req.Value)
+		if err != nil || addr.Name != "" {
+			writeError(w, http.StatusBadRequest, ErrCodeInvalidParameter, "alert_email must be a plain email address")
+			return
+		}
+		req.Value = addr.Address
+	}
+	if req.Key == timezoneKey {
+		if _, err := time.LoadLocation(req.Value); err != nil || req.Value == "" {
+			writeError(w, http.StatusBadRequest, ErrCodeInvalidParameter, "timezone must be an IANA time zone name (e.g. Asia/Tokyo)")
+			return
+		}
+	}
 
 	if err := h.service.SetForUser(ctx, userID, req.Key, req.Value); err != nil {
-		http.Error(w, "Failed to update setting", http.StatusInternalServerError)
+		writeError(w, http.StatusInternalServerError, ErrCodeInternal, "Failed to update setting")
 		return
 	}
 
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/akagifreeez/torn-market-chart/internal/services"
+	"github.com/akagifreeez/torn-market-chart/internal/workers"
+	"github.com/akagifreeez/torn-market-chart/pkg/database"
+)
+
+// statsTTL is how long the aggregate counts are served from memory
+const statsTTL = time.Minute
+
+// StatsResponse is the public service overview
+type StatsResponse struct {
+	TotalItems     int64      `json:"total_items"`
+	TrackedItems   int64      `json:"tracked_items"`
+	TotalUsers     int64      `json:"total_users"`
+	WatchedItems   int64      `json:"watched_items"` // Distinct items on any watchlist
+	TotalAlerts    int64      `json:"total_alerts"`
+	PricePoints24h int64      `json:"price_points_24h"` // Market + bazaar rows ingested
+	LastSyncAt     *time.Time `json:"last_sync_at"`
+	WSConnected    bool       `json:"ws_connected"`
+}
+
+// StatsHandler serves cheap, cached service-wide counts
+type StatsHandler struct {
+	db         *database.DB
+	globalSync *workers.GlobalSync
+	ws         *services.TornWebSocketService
+
+	mu       sync.Mutex
+	counts   *StatsResponse
+	loadedAt time.Time
+}
+
+func NewStatsHandler(db *database.DB, globalSync *workers.GlobalSync, ws *services.TornWebSocketService) *StatsHandler {
+	return &StatsHandler{db: db, globalSync: globalSync, ws: ws}
+}
+
+// GetStats returns service-wide counts plus live sync and WebSocket state,
+// e.g. for the landing page and the bot's /status command
+// GET /api/v1/stats
+func (h *StatsHandler) GetStats(w http.ResponseWriter, r *http.Request) {
+	counts, err := h.cachedCounts(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, ErrCodeDatabase, "Database error")
+		return
+	}
+
+	// Counts are cached; connection state is always live
+	resp := *counts
+	if last := h.globalSync.LastSync(); !last.IsZero() {
+		resp.LastSyncAt = &last
+	}
+	resp.WSConnected = h.ws.Connected()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// cachedCounts returns the DB counts, reloading them once statsTTL has passed
+func (h *StatsHandler) cachedCounts(ctx context.Context) (*StatsResponse, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.counts != nil && time.Since(h.loadedAt) < statsTTL {
+		return h.counts, nil
+	}
+
+	counts := &StatsResponse{}
+	err := h.db.Pool.QueryRow(ctx, `
+		SELECT
+			(SELECT COUNT(*) FROM items),
+			(SELECT COUNT(*) FROM items WHERE is_tracked),
+			(SELECT COUNT(*) FROM users),
+			(SELECT COUNT(DISTINCT item_id) FROM user_watchlists),
+			(SELECT COUNT(*) FROM user_alerts),
+			(SELECT COUNT(*) FROM market_prices WHERE time > NOW() - INTERVAL '24 hours') +
+			(SELECT COUNT(*) FROM bazaar_prices WHERE time > NOW() - INTERVAL '24 hours')
+	`).Scan(&counts.TotalItems, &counts.TrackedItems, &counts.TotalUsers, &counts.WatchedItems,
+		&counts.TotalAlerts, &counts.PricePoints24h)
+	if err != nil {
+		return nil, err
+	}
+
+	h.counts = counts
+	h.loadedAt = time.Now()
+	return counts, nil
+}
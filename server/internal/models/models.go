@@ -7,28 +7,48 @@ import (
 // Item represents a Torn item with its current price cache
 // Note: id IS the Torn item ID (previously torn_id)
 type Item struct {
-	ID                 int64     `json:"id" db:"id"` // This IS the Torn item ID
-	Name               string    `json:"name" db:"name"`
-	Description        string    `json:"description,omitempty" db:"description"`
-	Type               string    `json:"type,omitempty" db:"type"`
-	Circulation        int64     `json:"circulation" db:"circulation"`
-	IsTracked          bool      `json:"is_tracked" db:"is_tracked"`
-	IsWatched          bool      `json:"is_watched" db:"is_watched"`
-	LastMarketPrice    int64     `json:"last_market_price" db:"last_market_price"`
-	LastBazaarPrice    int64     `json:"last_bazaar_price" db:"last_bazaar_price"`
-	LastUpdatedAt      time.Time `json:"last_updated_at" db:"last_updated_at"`
-	CreatedAt          time.Time `json:"created_at" db:"created_at"`
-	AlertPriceAbove    *int64    `json:"alert_price_above,omitempty" db:"alert_price_above"`
-	AlertPriceBelow    *int64    `json:"alert_price_below,omitempty" db:"alert_price_below"`
-	AlertChangePercent *float64  `json:"alert_change_percent,omitempty" db:"alert_change_percent"`
+	ID                  int64     `json:"id" db:"id"` // This IS the Torn item ID
+	Name                string    `json:"name" db:"name"`
+	Description         string    `json:"description,omitempty" db:"description"`
+	Type                string    `json:"type,omitempty" db:"type"`
+	Circulation         int64     `json:"circulation" db:"circulation"`
+	IsTracked           bool      `json:"is_tracked" db:"is_tracked"`
+	IsWatched           bool      `json:"is_watched" db:"is_watched"`
+	IsPinned            bool      `json:"is_pinned" db:"is_pinned"`
+	LastMarketPrice     int64     `json:"last_market_price" db:"last_market_price"`
+	LastBazaarPrice     int64     `json:"last_bazaar_price" db:"last_bazaar_price"`
+	TornMarketValue     int64     `json:"torn_market_value" db:"torn_market_value"`   // Reference value from the catalog sync
+	MarketSource        string    `json:"market_source,omitempty" db:"market_source"` // Writer of the latest market price point
+	BazaarSource        string    `json:"bazaar_source,omitempty" db:"bazaar_source"` // Writer of the latest bazaar price point
+	LastUpdatedAt       time.Time `json:"last_updated_at" db:"last_updated_at"`
+	CreatedAt           time.Time `json:"created_at" db:"created_at"`
+	AlertPriceAbove     *int64    `json:"alert_price_above,omitempty" db:"alert_price_above"`
+	AlertPriceBelow     *int64    `json:"alert_price_below,omitempty" db:"alert_price_below"`
+	AlertChangePercent  *float64  `json:"alert_change_percent,omitempty" db:"alert_change_percent"`
+	AlertChangeAbsolute *int64    `json:"alert_change_absolute,omitempty" db:"alert_change_absolute"`
+	AlertOnRecord       bool      `json:"alert_on_record" db:"alert_on_record"`
+	Change24h           *float64  `json:"change_24h,omitempty" db:"change_24h"` // Market % change over 24h, when computed
+	Note                string    `json:"note,omitempty" db:"note"`             // User's private note
+	Tags                []string  `json:"tags,omitempty" db:"tags"`
 }
 
+// Price sources recorded with each price point
+const (
+	PriceSourceWS       = "ws"       // Torn WebSocket feed
+	PriceSourcePoller   = "poller"   // Bazaar poller (Weav3r)
+	PriceSourceCrawler  = "crawler"  // Torn API v2 market fetch
+	PriceSourceWebhook  = "webhook"  // POST /api/webhook/update
+	PriceSourceExternal = "external" // On-demand Weav3r lookups from API requests
+	PriceSourceRefresh  = "refresh"  // User-requested refresh (POST /items/{id}/refresh)
+)
+
 // MarketPrice represents a single price point in the item market (Hypertable)
 type MarketPrice struct {
 	Time     time.Time `json:"time" db:"time"`
 	ItemID   int64     `json:"item_id" db:"item_id"`
 	Price    int64     `json:"price" db:"price"`
 	Quantity int64     `json:"quantity,omitempty" db:"quantity"`
+	Source   string    `json:"source,omitempty" db:"source"`
 }
 
 // BazaarPrice represents a single price point in bazaars (Hypertable)
@@ -39,6 +59,43 @@ type BazaarPrice struct {
 	Quantity  int64     `json:"quantity,omitempty" db:"quantity"`
 	SellerID  int64     `json:"seller_id,omitempty" db:"seller_id"`
 	ListingID int64     `json:"listing_id,omitempty" db:"listing_id"`
+	Source    string    `json:"source,omitempty" db:"source"`
+}
+
+// ExternalPricePoint is a recorded trader price overlay; a source that was
+// unavailable at the time is null
+type ExternalPricePoint struct {
+	Time                 time.Time `json:"time" db:"time"`
+	ItemID               int64     `json:"item_id" db:"item_id"`
+	TornExchangeBuyPrice *int64    `json:"tornexchange_buy_price" db:"tornexchange_buy_price"`
+	TornMarketPrice      *int64    `json:"torn_market_price" db:"torn_market_price"`
+	Weav3rMinBazaar      *int64    `json:"weav3r_min_bazaar" db:"weav3r_min_bazaar"`
+}
+
+// PriceTier is the cheapest item market price among listings whose stack size
+// falls in [MinQuantity, MaxQuantity]; MaxQuantity 0 means no upper bound
+type PriceTier struct {
+	MinQuantity int64 `json:"min_quantity"`
+	MaxQuantity int64 `json:"max_quantity,omitempty"`
+	Price       int64 `json:"price"`
+	Listed      int64 `json:"listed"` // Total units listed in this bracket
+}
+
+// ItemPriceTiers is the latest set of price tiers recorded for an item
+type ItemPriceTiers struct {
+	ItemID    int64       `json:"item_id" db:"item_id"`
+	Tiers     []PriceTier `json:"tiers" db:"tiers"`
+	UpdatedAt time.Time   `json:"updated_at" db:"updated_at"`
+}
+
+// SoldListing is a bazaar listing inferred as sold because it disappeared
+// (or its quantity dropped) between two poller snapshots
+type SoldListing struct {
+	Time     time.Time `json:"time" db:"time"`
+	ItemID   int64     `json:"item_id" db:"item_id"`
+	Price    int64     `json:"price" db:"price"`
+	Quantity int64     `json:"quantity" db:"quantity"`
+	SellerID int64     `json:"seller_id,omitempty" db:"seller_id"`
 }
 
 // AlertState tracks the last alert state for deduplication
@@ -72,6 +129,7 @@ type PriceCandle struct {
 	Close    int64     `json:"close" db:"close"`
 	AvgPrice float64   `json:"avg_price" db:"avg_price"`
 	Volume   int64     `json:"volume,omitempty" db:"volume"`
+	VWAP     float64   `json:"vwap" db:"vwap"` // sum(price*quantity)/sum(quantity); avg price when no quantity
 }
 
 // User represents a registered user (via Torn API Key)
@@ -93,15 +151,24 @@ type UserWatchlist struct {
 	CreatedAt time.Time `json:"created_at" db:"created_at"`
 }
 
+// UserPin represents an item pinned for quick access (independent of the watchlist)
+type UserPin struct {
+	UserID   int64     `json:"user_id" db:"user_id"`
+	ItemID   int64     `json:"item_id" db:"item_id"`
+	PinnedAt time.Time `json:"pinned_at" db:"pinned_at"`
+}
+
 // UserAlert represents a user capability to set price alerts
 type UserAlert struct {
-	ID                 int64     `json:"id" db:"id"`
-	UserID             int64     `json:"user_id" db:"user_id"`
-	ItemID             int64     `json:"item_id" db:"item_id"`
-	AlertPriceAbove    *int64    `json:"alert_price_above" db:"alert_price_above"`
-	AlertPriceBelow    *int64    `json:"alert_price_below" db:"alert_price_below"`
-	AlertChangePercent *float64  `json:"alert_change_percent" db:"alert_change_percent"`
-	CreatedAt          time.Time `json:"created_at" db:"created_at"`
+	ID                  int64     `json:"id" db:"id"`
+	UserID              int64     `json:"user_id" db:"user_id"`
+	ItemID              int64     `json:"item_id" db:"item_id"`
+	AlertPriceAbove     *int64    `json:"alert_price_above" db:"alert_price_above"`
+	AlertPriceBelow     *int64    `json:"alert_price_below" db:"alert_price_below"`
+	AlertChangePercent  *float64  `json:"alert_change_percent" db:"alert_change_percent"`
+	AlertChangeAbsolute *int64    `json:"alert_change_absolute" db:"alert_change_absolute"`
+	AlertOnRecord       bool      `json:"alert_on_record" db:"alert_on_record"`
+	CreatedAt           time.Time `json:"created_at" db:"created_at"`
 }
 
 // WebhookPayload represents incoming data from external sources
@@ -113,7 +180,8 @@ type WebhookPayload struct {
 type WebhookItem struct {
 	TornID    int64  `json:"torn_id"`
 	Price     int64  `json:"price"`
-	Type      string `json:"type"` // "market" or "bazaar"
+	Type      string `json:"type"`               // "market" or "bazaar"
+	Quantity  int64  `json:"quantity,omitempty"` // Units listed at Price; 0 when the sender doesn't know
 	SellerID  int64  `json:"seller_id,omitempty"`
 	ListingID int64  `json:"listing_id,omitempty"`
 	Timestamp int64  `json:"timestamp,omitempty"`
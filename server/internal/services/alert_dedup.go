@@ -0,0 +1,83 @@
+package services
+
+import (
+	"sync"
+	"time"
+)
+
+// alertDedupKey scopes recently alerted hashes to one user and item
+type alertDedupKey struct {
+	userID int64
+	itemID int64
+}
+
+// alertDedup remembers which listing hashes alerted recently, so a price that
+// flaps between values (A, B, A, ...) doesn't re-alert on every swing. The
+// last-hash check in alert_states only catches immediate repeats.
+type alertDedup struct {
+	window time.Duration
+
+	mu        sync.Mutex
+	seen      map[alertDedupKey]map[string]time.Time
+	lastSweep time.Time // When every key was last checked for expired hashes
+}
+
+func newAlertDedup(window time.Duration) *alertDedup {
+	return &alertDedup{
+		window: window,
+		seen:   make(map[alertDedupKey]map[string]time.Time),
+	}
+}
+
+// Seen reports whether hash alerted for user/item within the window
+func (d *alertDedup) Seen(userID, itemID int64, hash string, now time.Time) bool {
+	if d.window <= 0 {
+		return false
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	at, ok := d.seen[alertDedupKey{userID, itemID}][hash]
+	return ok && now.Sub(at) < d.window
+}
+
+// Record marks hash as alerted at now and drops entries older than the window.
+// At most once per window it also sweeps every other user/item, so pairs that
+// stop alerting don't stay in memory forever.
+func (d *alertDedup) Record(userID, itemID int64, hash string, now time.Time) {
+	if d.window <= 0 {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if now.Sub(d.lastSweep) >= d.window {
+		for key, hashes := range d.seen {
+			if d.prune(hashes, now) == 0 {
+				delete(d.seen, key)
+			}
+		}
+		d.lastSweep = now
+	}
+
+	key := alertDedupKey{userID, itemID}
+	hashes := d.seen[key]
+	if hashes == nil {
+		hashes = make(map[string]time.Time)
+		d.seen[key] = hashes
+	}
+	d.prune(hashes, now)
+	hashes[hash] = now
+}
+
+// prune drops hashes older than the window and returns how many remain
+func (d *alertDedup) prune(hashes map[string]time.Time, now time.Time) int {
+	for h, at := range hashes {
+		if now.Sub(at) >= d.window {
+			delete(hashes, h)
+		}
+	}
+	return len(hashes)
+}
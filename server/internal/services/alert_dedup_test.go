@@ -0,0 +1,107 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAlertDedupOscillation(t *testing.T) {
+	const window = 30 * time.Minute
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		third time.Duration // Offset of the second A after the first
+		want  bool
+	}{
+		{"A B A within window", 10 * time.Minute, true},
+		{"A B A just inside window", window - time.Second, true},
+		{"A B A at window", window, false},
+		{"A B A outside window", window + 5*time.Minute, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := newAlertDedup(window)
+
+			// A alerts, then the price swings to B, which alerts too
+			if d.Seen(1, 100, "A", start) {
+				t.Fatal("first A reported as seen")
+			}
+			d.Record(1, 100, "A", start)
+			if d.Seen(1, 100, "B", start.Add(time.Minute)) {
+				t.Fatal("B reported as seen before it alerted")
+			}
+			d.Record(1, 100, "B", start.Add(time.Minute))
+
+			// Swinging back to A is suppressed only inside the window
+			if got := d.Seen(1, 100, "A", start.Add(tt.third)); got != tt.want {
+				t.Errorf("Seen(A) after %v = %v, want %v", tt.third, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAlertDedupScopedToUserAndItem(t *testing.T) {
+	d := newAlertDedup(time.Hour)
+	now := time.Now()
+	d.Record(1, 100, "A", now)
+
+	if d.Seen(2, 100, "A", now) {
+		t.Error("hash leaked to another user")
+	}
+	if d.Seen(1, 200, "A", now) {
+		t.Error("hash leaked to another item")
+	}
+}
+
+func TestAlertDedupDisabled(t *testing.T) {
+	d := newAlertDedup(0)
+	now := time.Now()
+	d.Record(1, 100, "A", now)
+
+	if d.Seen(1, 100, "A", now) {
+		t.Error("zero window should never report a hash as seen")
+	}
+	if len(d.seen) != 0 {
+		t.Errorf("zero window stored %d keys", len(d.seen))
+	}
+}
+
+func TestAlertDedupEvictsIdlePairs(t *testing.T) {
+	const window = 30 * time.Minute
+	d := newAlertDedup(window)
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	for item := int64(1); item <= 10; item++ {
+		d.Record(1, item, "A", start)
+	}
+	if len(d.seen) != 10 {
+		t.Fatalf("tracked %d pairs, want 10", len(d.seen))
+	}
+
+	// Any record after the window sweeps pairs whose hashes all expired
+	d.Record(2, 999, "B", start.Add(window+time.Minute))
+	if len(d.seen) != 1 {
+		t.Errorf("tracked %d pairs after sweep, want 1", len(d.seen))
+	}
+	if _, ok := d.seen[alertDedupKey{2, 999}]; !ok {
+		t.Error("fresh pair was evicted")
+	}
+}
+
+func TestAlertDedupDropsExpiredHashes(t *testing.T) {
+	const window = 30 * time.Minute
+	d := newAlertDedup(window)
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	d.Record(1, 100, "A", start)
+	d.Record(1, 100, "B", start.Add(window+time.Minute))
+
+	hashes := d.seen[alertDedupKey{1, 100}]
+	if _, ok := hashes["A"]; ok {
+		t.Error("expired hash A was kept")
+	}
+	if _, ok := hashes["B"]; !ok {
+		t.Error("current hash B missing")
+	}
+}
@@ -7,8 +7,10 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"html"
 	"math"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/bwmarrin/discordgo"
@@ -16,15 +18,26 @@ import (
 	"github.com/rs/zerolog/log"
 )
 
+// alertSendTimeout bounds a single alert delivery (webhook + DM)
+const alertSendTimeout = 30 * time.Second
+
 // AlertService handles alert deduplication and triggering
 type AlertService struct {
 	db       *pgxpool.Pool
 	settings *SettingsService
 	discord  *discordgo.Session
+	email    *EmailSender // Optional; email alerts are skipped when SMTP is unconfigured
+	records  *priceRecordTracker
+	dedup    *alertDedup
+	cooldown time.Duration  // Minimum gap between alerts for one user/item (0 = off)
+	inflight sync.WaitGroup // Outstanding alert deliveries
 }
 
 // NewAlertService creates a new AlertService with dynamic settings
-func NewAlertService(db *pgxpool.Pool, settings *SettingsService, cooldown time.Duration, priceThreshold float64, botToken string) *AlertService {
+// recordLookback bounds the window for record low/high alerts (0 = all history).
+// dedupWindow suppresses re-alerting on a listing hash seen recently (0 = off).
+// cooldown is the minimum time between two alerts for the same user/item (0 = off).
+func NewAlertService(db *pgxpool.Pool, settings *SettingsService, cooldown, dedupWindow time.Duration, priceThreshold float64, recordLookback time.Duration, botToken string, email *EmailSender) *AlertService {
 	var session *discordgo.Session
 	if botToken != "" {
 		s, err := discordgo.New("Bot " + botToken)
@@ -39,6 +52,10 @@ func NewAlertService(db *pgxpool.Pool, settings *SettingsService, cooldown time.
 		db:       db,
 		settings: settings,
 		discord:  session,
+		email:    email,
+		records:  newPriceRecordTracker(db, recordLookback),
+		dedup:    newAlertDedup(dedupWindow),
+		cooldown: cooldown,
 	}
 }
 
@@ -55,15 +72,19 @@ type PriceUpdate struct {
 
 // AlertState represents the last alert state for deduplication
 type AlertState struct {
-	LastPrice int64
-	LastHash  string
+	LastPrice       int64
+	LastHash        string
+	LastTriggeredAt *time.Time // When an alert last actually fired
 }
 
 // ItemAlertConfig holds the alert configuration for an item
 type ItemAlertConfig struct {
-	AlertPriceAbove    *int64
-	AlertPriceBelow    *int64
-	AlertChangePercent *float64
+	AlertPriceAbove     *int64
+	AlertPriceBelow     *int64
+	AlertChangePercent  *float64
+	AlertChangeAbsolute *int64
+	AlertOnRecord       bool
+	CooldownSeconds     *int // Overrides the service cooldown when set
 }
 
 // CheckAndTrigger checks if an alert should be triggered for any subscribing users
@@ -73,7 +94,7 @@ func (a *AlertService) CheckAndTrigger(ctx context.Context, update PriceUpdate,
 
 	// Fetch all users with alert configurations for this item
 	rows, err := a.db.Query(ctx, `
-		SELECT ua.user_id, ua.alert_price_above, ua.alert_price_below, ua.alert_change_percent, u.discord_id
+		SELECT ua.user_id, ua.alert_price_above, ua.alert_price_below, ua.alert_change_percent, ua.alert_change_absolute, ua.alert_on_record, ua.cooldown_seconds, u.discord_id
 		FROM user_alerts ua
 		LEFT JOIN users u ON u.id = ua.user_id
 		WHERE ua.item_id = $1
@@ -87,37 +108,54 @@ func (a *AlertService) CheckAndTrigger(ctx context.Context, update PriceUpdate,
 	anyTriggered := false
 
 	type UserAlert struct {
-		UserID             int64
-		AlertPriceAbove    *int64
-		AlertPriceBelow    *int64
-		AlertChangePercent *float64
-		DiscordID          *string
+		UserID              int64
+		AlertPriceAbove     *int64
+		AlertPriceBelow     *int64
+		AlertChangePercent  *float64
+		AlertChangeAbsolute *int64
+		AlertOnRecord       bool
+		CooldownSeconds     *int
+		DiscordID           *string
 	}
 	var alerts []UserAlert
+	wantRecords := false
 
 	for rows.Next() {
 		var ua UserAlert
-		if err := rows.Scan(&ua.UserID, &ua.AlertPriceAbove, &ua.AlertPriceBelow, &ua.AlertChangePercent, &ua.DiscordID); err != nil {
+		if err := rows.Scan(&ua.UserID, &ua.AlertPriceAbove, &ua.AlertPriceBelow, &ua.AlertChangePercent, &ua.AlertChangeAbsolute, &ua.AlertOnRecord, &ua.CooldownSeconds, &ua.DiscordID); err != nil {
 			continue
 		}
 		alerts = append(alerts, ua)
+		wantRecords = wantRecords || ua.AlertOnRecord
+	}
+
+	// Record lows/highs are only tracked for items someone asked for them on
+	var isRecordLow, isRecordHigh bool
+	if wantRecords {
+		isRecordLow, isRecordHigh, err = a.records.Observe(ctx, update.ItemID, update.Type, update.Price)
+		if err != nil {
+			log.Warn().Err(err).Int64("item_id", update.ItemID).Msg("Failed to check price record")
+		}
 	}
 
 	for _, config := range alerts {
 		// Get last alert state for this user/item
 		var state AlertState
 		err = a.db.QueryRow(ctx, `
-			SELECT last_price, last_hash
+			SELECT last_price, last_hash, last_triggered_at
 			FROM alert_states
 			WHERE item_id = $1 AND user_id = $2
-		`, update.ItemID, config.UserID).Scan(&state.LastPrice, &state.LastHash)
+		`, update.ItemID, config.UserID).Scan(&state.LastPrice, &state.LastHash, &state.LastTriggeredAt)
 
 		isNewState := err != nil
 
-		// Check duplicate hash
+		// Check duplicate hash, both the last one and any alerted within the dedup window
 		if !isNewState && currentHash == state.LastHash {
 			continue
 		}
+		if a.dedup.Seen(config.UserID, update.ItemID, currentHash, time.Now()) {
+			continue
+		}
 
 		shouldAlert := false
 		alertReason := ""
@@ -129,18 +167,52 @@ func (a *AlertService) CheckAndTrigger(ctx context.Context, update PriceUpdate,
 		} else if config.AlertPriceBelow != nil && update.Price <= *config.AlertPriceBelow {
 			shouldAlert = true
 			alertReason = fmt.Sprintf("Price $%d is below threshold $%d", update.Price, *config.AlertPriceBelow)
-		} else if config.AlertChangePercent != nil && !isNewState && state.LastPrice > 0 {
-			priceDiffPct := math.Abs(float64(update.Price-state.LastPrice)) / float64(state.LastPrice) * 100
-			if priceDiffPct >= *config.AlertChangePercent {
+		} else if (config.AlertChangePercent != nil || config.AlertChangeAbsolute != nil) && !isNewState && state.LastPrice > 0 {
+			// Fires if either the percent or the absolute threshold is met
+			priceDiff := update.Price - state.LastPrice
+			absDiff := priceDiff
+			if absDiff < 0 {
+				absDiff = -absDiff
+			}
+			priceDiffPct := math.Abs(float64(priceDiff)) / float64(state.LastPrice) * 100
+			changeDir := "increased"
+			if priceDiff < 0 {
+				changeDir = "decreased"
+			}
+			if config.AlertChangePercent != nil && priceDiffPct >= *config.AlertChangePercent {
 				shouldAlert = true
-				changeDir := "increased"
-				if update.Price < state.LastPrice {
-					changeDir = "decreased"
-				}
 				alertReason = fmt.Sprintf("Price %s by %.1f%% (threshold: %.1f%%)", changeDir, priceDiffPct, *config.AlertChangePercent)
+			} else if config.AlertChangeAbsolute != nil && absDiff >= *config.AlertChangeAbsolute {
+				shouldAlert = true
+				alertReason = fmt.Sprintf("Price %s by $%d (threshold: $%d)", changeDir, absDiff, *config.AlertChangeAbsolute)
+			}
+		}
+		if !shouldAlert && config.AlertOnRecord {
+			if isRecordLow {
+				shouldAlert = true
+				alertReason = fmt.Sprintf("Price $%d is a record low", update.Price)
+			} else if isRecordHigh {
+				shouldAlert = true
+				alertReason = fmt.Sprintf("Price $%d is a record high", update.Price)
 			}
 		}
 
+		// Hold back alerts that would fire within the cooldown of the last one;
+		// the alert's own cooldown, when set, replaces the service default
+		cooldown := a.cooldown
+		if config.CooldownSeconds != nil {
+			cooldown = time.Duration(*config.CooldownSeconds) * time.Second
+		}
+		if shouldAlert && cooldown > 0 && state.LastTriggeredAt != nil && time.Since(*state.LastTriggeredAt) < cooldown {
+			log.Debug().
+				Int64("item_id", update.ItemID).
+				Int64("user_id", config.UserID).
+				Time("last_triggered_at", *state.LastTriggeredAt).
+				Dur("cooldown", cooldown).
+				Msg("Alert suppressed by cooldown")
+			shouldAlert = false
+		}
+
 		// Update state regardless of trigger (to track history/dedup)
 		// But if we don't alert, maybe we shouldn't update hash?
 		// Logic: If it matched criteria but we didn't alert because... wait.
@@ -155,11 +227,17 @@ func (a *AlertService) CheckAndTrigger(ctx context.Context, update PriceUpdate,
 				Str("reason", alertReason).
 				Msg("Alert triggered for user")
 
-			a.updateAlertState(ctx, update, currentHash, config.UserID, isNewState)
+			a.updateAlertState(ctx, update, currentHash, config.UserID, isNewState, true)
+			a.dedup.Record(config.UserID, update.ItemID, currentHash, time.Now())
+			a.recordHistory(ctx, update, alertReason, config.UserID)
 
-			// Send notification
+			// Send notification (tracked so Shutdown can drain it)
+			a.inflight.Add(1)
 			go func(ua UserAlert, reason string) {
-				if err := a.SendAlert(context.Background(), update, reason, ua.UserID, ua.DiscordID); err != nil {
+				defer a.inflight.Done()
+				sendCtx, cancel := context.WithTimeout(context.Background(), alertSendTimeout)
+				defer cancel()
+				if err := a.SendAlert(sendCtx, update, reason, ua.UserID, ua.DiscordID); err != nil {
 					log.Error().Err(err).Int64("user_id", ua.UserID).Msg("Failed to send alert notification")
 				}
 			}(config, alertReason)
@@ -170,26 +248,61 @@ func (a *AlertService) CheckAndTrigger(ctx context.Context, update PriceUpdate,
 			// BUT legacy logic was:
 			// if !shouldAlert { a.updateAlertState(...) return false }
 			// So yes, we should update state.
-			a.updateAlertState(ctx, update, currentHash, config.UserID, isNewState)
+			a.updateAlertState(ctx, update, currentHash, config.UserID, isNewState, false)
 		}
 	}
 
 	return anyTriggered, nil
 }
 
-func (a *AlertService) updateAlertState(ctx context.Context, update PriceUpdate, hash string, userID int64, isNew bool) {
+// Shutdown waits for in-flight alert deliveries to finish, or until ctx is done
+func (a *AlertService) Shutdown(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		a.inflight.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// recordHistory logs a triggered alert to the user's (unread) alert history
+func (a *AlertService) recordHistory(ctx context.Context, update PriceUpdate, reason string, userID int64) {
+	_, err := a.db.Exec(ctx, `
+		INSERT INTO alert_history (user_id, item_id, price, source, reason)
+		VALUES ($1, $2, $3, $4, $5)
+	`, userID, update.ItemID, update.Price, update.Type, reason)
+	if err != nil {
+		log.Error().Err(err).Int64("item_id", update.ItemID).Msg("Failed to record alert history")
+	}
+}
+
+// updateAlertState records the latest seen price/hash; last_triggered_at only
+// moves when an alert actually fired, so the cooldown measures real alerts
+func (a *AlertService) updateAlertState(ctx context.Context, update PriceUpdate, hash string, userID int64, isNew, triggered bool) {
+	var triggeredAt *time.Time
+	if triggered {
+		now := time.Now()
+		triggeredAt = &now
+	}
+
 	var err error
 	if isNew {
 		_, err = a.db.Exec(ctx, `
 			INSERT INTO alert_states (item_id, user_id, last_price, last_hash, last_triggered_at)
 			VALUES ($1, $2, $3, $4, $5)
-		`, update.ItemID, userID, update.Price, hash, time.Now())
+		`, update.ItemID, userID, update.Price, hash, triggeredAt)
 	} else {
 		_, err = a.db.Exec(ctx, `
 			UPDATE alert_states
-			SET last_price = $1, last_hash = $2, last_triggered_at = $3
+			SET last_price = $1, last_hash = $2, last_triggered_at = COALESCE($3, last_triggered_at)
 			WHERE item_id = $4 AND user_id = $5
-		`, update.Price, hash, time.Now(), update.ItemID, userID)
+		`, update.Price, hash, triggeredAt, update.ItemID, userID)
 	}
 	if err != nil {
 		log.Error().Err(err).Int64("item_id", update.ItemID).Msg("Failed to update alert state")
@@ -286,7 +399,21 @@ func (a *AlertService) SendAlert(ctx context.Context, update PriceUpdate, reason
 		}
 	}
 
-	// 5. Send Discord DM if Discord ID is present, bot is configured, and enabled
+	// 5. Send email if SMTP is configured and the user opted in. Failures are
+	// logged only so they never block the Discord channels.
+	if a.email.Enabled() {
+		emailEnabled, _ := a.settings.GetForUser(ctx, userID, "email_alerts_enabled", "false")
+		to, _ := a.settings.GetForUser(ctx, userID, "alert_email", "")
+		if emailEnabled == "true" && to != "" {
+			subject := fmt.Sprintf("🚨 Price Alert: %s", update.ItemName)
+			text, htmlBody := renderAlertEmail(update, reason, alertURL)
+			if err := a.email.Send(ctx, to, subject, text, htmlBody); err != nil {
+				log.Error().Err(err).Int64("user_id", userID).Msg("Failed to send alert email")
+			}
+		}
+	}
+
+	// 6. Send Discord DM if Discord ID is present, bot is configured, and enabled
 	dmEnabled, _ := a.settings.GetForUser(ctx, userID, "discord_dm_enabled", "true")
 	if dmEnabled != "false" && discordID != nil && *discordID != "" && a.discord != nil {
 		// Create the discordgo Embed struct
@@ -333,3 +460,29 @@ func (a *AlertService) SendAlert(ctx context.Context, update PriceUpdate, reason
 
 	return nil
 }
+
+// renderAlertEmail builds the plaintext and HTML bodies of an alert email,
+// mirroring the Discord embed fields
+func renderAlertEmail(update PriceUpdate, reason, alertURL string) (string, string) {
+	type field struct{ name, value string }
+	fields := []field{
+		{"Price", fmt.Sprintf("$%d", update.Price)},
+		{"Quantity", fmt.Sprintf("%d", update.Quantity)},
+		{"Source", update.Type},
+		{"Trigger", reason},
+	}
+	if update.SellerID > 0 {
+		fields = append(fields, field{"Seller ID", fmt.Sprintf("%d", update.SellerID)})
+	}
+
+	var text, body bytes.Buffer
+	fmt.Fprintf(&text, "Price Alert: %s\n\n", update.ItemName)
+	fmt.Fprintf(&body, "<h2>&#x1F6A8; Price Alert: <a href=\"%s\">%s</a></h2>\n<table>\n", html.EscapeString(alertURL), html.EscapeString(update.ItemName))
+	for _, f := range fields {
+		fmt.Fprintf(&text, "%s: %s\n", f.name, f.value)
+		fmt.Fprintf(&body, "<tr><th align=\"left\">%s</th><td>%s</td></tr>\n", f.name, html.EscapeString(f.value))
+	}
+	fmt.Fprintf(&text, "\n%s\n\n-- Torn Market Chart Bot\n", alertURL)
+	body.WriteString("</table>\n<p><small>Torn Market Chart Bot</small></p>\n")
+	return text.String(), body.String()
+}
@@ -0,0 +1,128 @@
+package services
+
+import (
+	"encoding/binary"
+	"hash"
+	"hash/fnv"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/akagifreeez/torn-market-chart/internal/models"
+)
+
+const (
+	// chartCacheTTL matches the 1m continuous aggregate refresh schedule
+	chartCacheTTL = time.Minute
+	// maxChartCacheEntries bounds memory; expired entries are evicted first
+	maxChartCacheEntries = 256
+)
+
+// chartCache holds rendered chart bytes for a short TTL. Keys hash the chart
+// kind, title, time zone and every plotted value, so new data for an item
+// produces a new key and stale renders are never served.
+type chartCache struct {
+	mu      sync.Mutex
+	entries map[uint64]chartCacheEntry
+}
+
+type chartCacheEntry struct {
+	png     []byte
+	expires time.Time
+}
+
+func newChartCache() *chartCache {
+	return &chartCache{entries: make(map[uint64]chartCacheEntry)}
+}
+
+// get returns cached bytes for key if still fresh
+func (c *chartCache) get(key uint64) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok || time.Now().After(e.expires) {
+		return nil, false
+	}
+	return e.png, true
+}
+
+// put stores png under key, evicting expired entries (or an arbitrary one)
+// when the cache is full
+func (c *chartCache) put(key uint64, png []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if len(c.entries) >= maxChartCacheEntries {
+		for k, e := range c.entries {
+			if now.After(e.expires) {
+				delete(c.entries, k)
+			}
+		}
+		for k := range c.entries {
+			if len(c.entries) < maxChartCacheEntries {
+				break
+			}
+			delete(c.entries, k)
+		}
+	}
+	c.entries[key] = chartCacheEntry{png: png, expires: now.Add(chartCacheTTL)}
+}
+
+// chartKey builds a cache key from string parts and plotted numbers
+type chartKey struct {
+	h   hash.Hash64
+	buf [8]byte
+}
+
+func newChartKey(parts ...string) *chartKey {
+	k := &chartKey{h: fnv.New64a()}
+	for _, p := range parts {
+		k.str(p)
+	}
+	return k
+}
+
+func (k *chartKey) str(s string) {
+	k.h.Write([]byte(s))
+	k.h.Write([]byte{0})
+}
+
+func (k *chartKey) int(v int64) {
+	binary.LittleEndian.PutUint64(k.buf[:], uint64(v))
+	k.h.Write(k.buf[:])
+}
+
+func (k *chartKey) float(v float64) {
+	k.int(int64(math.Float64bits(v)))
+}
+
+// seriesChartKey keys a line chart by its title, zone and points
+func seriesChartKey(itemName string, series []PriceSeries, loc *time.Location) uint64 {
+	k := newChartKey("series", itemName, loc.String())
+	for _, ps := range series {
+		k.str(ps.Name)
+		k.str(ps.Color)
+		k.int(int64(len(ps.Times)))
+		for i, t := range ps.Times {
+			k.int(t.UnixNano())
+			if i < len(ps.Prices) {
+				k.float(ps.Prices[i])
+			}
+		}
+	}
+	return k.h.Sum64()
+}
+
+// candlestickChartKey keys a candlestick chart by its title, zone and candles
+func candlestickChartKey(itemName string, candles []models.PriceCandle, loc *time.Location) uint64 {
+	k := newChartKey("candles", itemName, loc.String())
+	for _, c := range candles {
+		k.int(c.Time.UnixNano())
+		k.int(c.Open)
+		k.int(c.High)
+		k.int(c.Low)
+		k.int(c.Close)
+	}
+	return k.h.Sum64()
+}
@@ -2,6 +2,7 @@ package services
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"time"
 
@@ -10,29 +11,132 @@ import (
 	"github.com/wcharczuk/go-chart/v2/drawing"
 )
 
-// ChartService provides methods to generate chart images
-type ChartService struct{}
+// ErrChartBusy is returned when no render slot frees up within the queue timeout
+var ErrChartBusy = errors.New("chart renderer is busy")
 
-func NewChartService() *ChartService {
-	return &ChartService{}
+// ChartService provides methods to generate chart images. Rendered charts are
+// cached briefly so repeat requests for unchanged data skip rendering, and at
+// most maxRenders charts are drawn at once; the rest queue for up to
+// queueTimeout before failing with ErrChartBusy.
+type ChartService struct {
+	cache        *chartCache
+	renders      chan struct{} // Semaphore of render slots
+	queueTimeout time.Duration
 }
 
-// GeneratePriceChartPNG takes a history of item records and creates a line chart PNG
-func (s *ChartService) GeneratePriceChartPNG(itemName string, history []models.Item) ([]byte, error) {
-	if len(history) < 2 {
+// NewChartService creates a ChartService. maxRenders < 1 is treated as 1.
+func NewChartService(maxRenders int, queueTimeout time.Duration) *ChartService {
+	return &ChartService{
+		cache:        newChartCache(),
+		renders:      make(chan struct{}, max(maxRenders, 1)),
+		queueTimeout: queueTimeout,
+	}
+}
+
+// PriceSeries is one line on a price chart
+type PriceSeries struct {
+	Name   string
+	Color  string // Hex stroke colour without the leading #
+	Times  []time.Time
+	Prices []float64
+}
+
+// maxSeriesPoints caps the points drawn per line; longer series are
+// downsampled with LTTB
+const maxSeriesPoints = 500
+
+// GenerateSeriesChartPNG draws one line per series on a shared time axis.
+// Series with fewer than two points are skipped.
+func (s *ChartService) GenerateSeriesChartPNG(itemName string, series []PriceSeries, loc *time.Location) ([]byte, error) {
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	var lines []chart.Series
+	for _, ps := range series {
+		if len(ps.Times) < 2 {
+			continue
+		}
+		times, prices := DownsampleSeries(ps.Times, ps.Prices, maxSeriesPoints)
+		lines = append(lines, chart.TimeSeries{
+			Name:    ps.Name,
+			XValues: times,
+			YValues: prices,
+			Style: chart.Style{
+				StrokeColor: drawing.ColorFromHex(ps.Color),
+				StrokeWidth: 3.0,
+			},
+		})
+	}
+	if len(lines) == 0 {
 		return nil, fmt.Errorf("not enough data points to generate a chart")
 	}
 
-	var xValues []time.Time
-	var yValuesMarket []float64
+	key := seriesChartKey(itemName, series, loc)
+	if png, ok := s.cache.get(key); ok {
+		return png, nil
+	}
+
+	graph := darkChart(itemName+" - 24h Price History", "15:04", loc)
+	graph.Series = lines
+	if len(lines) > 1 {
+		graph.Elements = []chart.Renderable{chart.Legend(&graph, chart.Style{
+			FillColor: drawing.ColorFromHex("2c2f33"),
+			FontColor: drawing.ColorWhite,
+		})}
+	}
+
+	return s.render(key, graph)
+}
+
+// maxCandles caps how many candles are drawn so bodies stay wide enough to read
+const maxCandles = 168
+
+// GenerateCandlestickPNG draws OHLC candles (green up, red down, with high/low wicks),
+// downsampled with LTTB to at most maxCandles. Times are labelled in loc (UTC when nil).
+func (s *ChartService) GenerateCandlestickPNG(itemName string, candles []models.PriceCandle, loc *time.Location) ([]byte, error) {
+	if len(candles) < 2 {
+		return nil, fmt.Errorf("not enough candles to generate a chart")
+	}
+	if loc == nil {
+		loc = time.UTC
+	}
+	candles = DownsampleLTTB(candles, maxCandles)
 
-	for _, h := range history {
-		xValues = append(xValues, h.LastUpdatedAt)
-		yValuesMarket = append(yValuesMarket, float64(h.LastMarketPrice))
+	key := candlestickChartKey(itemName, candles, loc)
+	if png, ok := s.cache.get(key); ok {
+		return png, nil
 	}
 
-	graph := chart.Chart{
-		Title: itemName + " - 24h Price History",
+	graph := darkChart(itemName+" - Price Candles", "01/02 15:04", loc)
+	graph.Series = []chart.Series{candlestickSeries{candles: candles}}
+	return s.render(key, graph)
+}
+
+// render draws graph once a render slot is free and caches the PNG under key
+func (s *ChartService) render(key uint64, graph chart.Chart) ([]byte, error) {
+	timer := time.NewTimer(s.queueTimeout)
+	defer timer.Stop()
+	select {
+	case s.renders <- struct{}{}:
+	case <-timer.C:
+		return nil, ErrChartBusy
+	}
+	defer func() { <-s.renders }()
+
+	png, err := renderPNG(graph)
+	if err != nil {
+		return nil, err
+	}
+	s.cache.put(key, png)
+	return png, nil
+}
+
+// darkChart returns a chart skeleton in Discord's dark theme with a time x-axis
+// formatted with layout in loc and an abbreviated dollar y-axis
+func darkChart(title, layout string, loc *time.Location) chart.Chart {
+	return chart.Chart{
+		Title: title,
 		TitleStyle: chart.Style{
 			FontColor: drawing.ColorWhite,
 			FontSize:  16,
@@ -44,7 +148,7 @@ func (s *ChartService) GeneratePriceChartPNG(itemName string, history []models.I
 			FillColor: drawing.ColorFromHex("23272a"),
 		},
 		XAxis: chart.XAxis{
-			Name: "Time",
+			Name: "Time (" + loc.String() + ")",
 			NameStyle: chart.Style{
 				FontColor: drawing.ColorWhite,
 			},
@@ -52,7 +156,15 @@ func (s *ChartService) GeneratePriceChartPNG(itemName string, history []models.I
 				FontColor:   drawing.ColorWhite,
 				StrokeColor: drawing.ColorWhite,
 			},
-			ValueFormatter: chart.TimeValueFormatterWithFormat("15:04"),
+			ValueFormatter: func(v interface{}) string {
+				switch typed := v.(type) {
+				case time.Time:
+					return typed.In(loc).Format(layout)
+				case float64:
+					return chart.TimeFromFloat64(typed).In(loc).Format(layout)
+				}
+				return ""
+			},
 		},
 		YAxis: chart.YAxis{
 			Name: "Price ($)",
@@ -65,30 +177,44 @@ func (s *ChartService) GeneratePriceChartPNG(itemName string, history []models.I
 			},
 			ValueFormatter: func(v interface{}) string {
 				if typed, ok := v.(float64); ok {
-					if typed >= 1000000 {
-						return fmt.Sprintf("$%.1fM", typed/1000000)
-					}
-					if typed >= 1000 {
-						return fmt.Sprintf("$%.1fK", typed/1000)
-					}
-					return fmt.Sprintf("$%.0f", typed)
+					return AbbreviatePrice(typed)
 				}
 				return ""
 			},
 		},
-		Series: []chart.Series{
-			chart.TimeSeries{
-				Name:    "Market Price",
-				XValues: xValues,
-				YValues: yValuesMarket,
-				Style: chart.Style{
-					StrokeColor: drawing.ColorFromHex("5865F2"), // Blurple
-					StrokeWidth: 3.0,
-				},
-			},
-		},
 	}
+}
 
+// AbbreviatePrice formats a dollar amount with a K/M/B suffix and three
+// significant digits ($950, $45.6K, $1.23B)
+func AbbreviatePrice(v float64) string {
+	sign := ""
+	if v < 0 {
+		sign, v = "-", -v
+	}
+	suffix := ""
+	for _, unit := range []struct {
+		size   float64
+		suffix string
+	}{{1e9, "B"}, {1e6, "M"}, {1e3, "K"}} {
+		// Promote values that would round up to 1000 of the smaller unit
+		if v >= unit.size*0.9995 {
+			v /= unit.size
+			suffix = unit.suffix
+			break
+		}
+	}
+	switch {
+	case suffix == "" || v >= 100:
+		return fmt.Sprintf("%s$%.0f%s", sign, v, suffix)
+	case v >= 10:
+		return fmt.Sprintf("%s$%.1f%s", sign, v, suffix)
+	default:
+		return fmt.Sprintf("%s$%.2f%s", sign, v, suffix)
+	}
+}
+
+func renderPNG(graph chart.Chart) ([]byte, error) {
 	buffer := bytes.NewBuffer([]byte{})
 	err := graph.Render(chart.PNG, buffer)
 	if err != nil {
@@ -97,3 +223,73 @@ func (s *ChartService) GeneratePriceChartPNG(itemName string, history []models.I
 
 	return buffer.Bytes(), nil
 }
+
+var (
+	candleUp   = drawing.ColorFromHex("3ba55d")
+	candleDown = drawing.ColorFromHex("ed4245")
+)
+
+// candlestickSeries is a go-chart series drawing one OHLC candle per entry.
+// It reports high/low as bounded values so the y-axis covers the wicks.
+type candlestickSeries struct {
+	candles []models.PriceCandle
+}
+
+func (cs candlestickSeries) GetName() string           { return "Candles" }
+func (cs candlestickSeries) GetYAxis() chart.YAxisType { return chart.YAxisPrimary }
+func (cs candlestickSeries) GetStyle() chart.Style     { return chart.Style{} }
+func (cs candlestickSeries) Len() int                  { return len(cs.candles) }
+
+func (cs candlestickSeries) GetBoundedValues(index int) (x, y1, y2 float64) {
+	c := cs.candles[index]
+	return chart.TimeToFloat64(c.Time), float64(c.High), float64(c.Low)
+}
+
+func (cs candlestickSeries) Validate() error {
+	if len(cs.candles) == 0 {
+		return fmt.Errorf("candlestick series has no candles")
+	}
+	return nil
+}
+
+func (cs candlestickSeries) Render(r chart.Renderer, canvasBox chart.Box, xrange, yrange chart.Range, defaults chart.Style) {
+	// Bodies take ~60% of each slot, never narrower than a pixel
+	bodyWidth := max(canvasBox.Width()*6/(10*len(cs.candles)), 1)
+	half := bodyWidth / 2
+
+	for _, c := range cs.candles {
+		color := candleUp
+		if c.Close < c.Open {
+			color = candleDown
+		}
+		x := canvasBox.Left + xrange.Translate(chart.TimeToFloat64(c.Time))
+		yHigh := canvasBox.Bottom - yrange.Translate(float64(c.High))
+		yLow := canvasBox.Bottom - yrange.Translate(float64(c.Low))
+		yOpen := canvasBox.Bottom - yrange.Translate(float64(c.Open))
+		yClose := canvasBox.Bottom - yrange.Translate(float64(c.Close))
+
+		// Wick
+		r.SetStrokeColor(color)
+		r.SetStrokeWidth(1)
+		r.MoveTo(x, yHigh)
+		r.LineTo(x, yLow)
+		r.Stroke()
+
+		// Doji: open == close leaves no body, so draw a thin horizontal line
+		if c.Open == c.Close {
+			r.SetStrokeColor(color)
+			r.SetStrokeWidth(1)
+			r.MoveTo(x-half, yOpen)
+			r.LineTo(x+half, yOpen)
+			r.Stroke()
+			continue
+		}
+
+		chart.Draw.Box(r, chart.Box{
+			Top:    min(yOpen, yClose),
+			Left:   x - half,
+			Right:  x - half + bodyWidth,
+			Bottom: max(yOpen, yClose),
+		}, chart.Style{FillColor: color, StrokeColor: color, StrokeWidth: 1})
+	}
+}
@@ -0,0 +1,139 @@
+package services
+
+import (
+	"math"
+	"time"
+
+	"github.com/akagifreeez/torn-market-chart/internal/models"
+)
+
+// lttb runs Largest-Triangle-Three-Buckets over the points (x[i], y[i]) and
+// returns, for each of the threshold output points, the selected index and the
+// [start, end) range of input points it stands for. The first and last points
+// are always kept as their own buckets. threshold must be in [3, len(x)).
+func lttb(x, y []float64, threshold int) (selected []int, bounds [][2]int) {
+	n := len(x)
+	selected = make([]int, 0, threshold)
+	bounds = make([][2]int, 0, threshold)
+	selected = append(selected, 0)
+	bounds = append(bounds, [2]int{0, 1})
+
+	// Split the interior points into threshold-2 buckets
+	every := float64(n-2) / float64(threshold-2)
+	bucketStart := func(i int) int { return int(math.Floor(float64(i)*every)) + 1 }
+
+	prev := 0
+	for i := 0; i < threshold-2; i++ {
+		start, end := bucketStart(i), bucketStart(i+1)
+
+		// Average of the next bucket (or the last point) is the third vertex
+		nextStart, nextEnd := end, bucketStart(i+2)
+		if i == threshold-3 {
+			nextStart, nextEnd = n-1, n
+		}
+		var avgX, avgY float64
+		for j := nextStart; j < nextEnd; j++ {
+			avgX += x[j]
+			avgY += y[j]
+		}
+		count := float64(nextEnd - nextStart)
+		avgX /= count
+		avgY /= count
+
+		best, bestArea := start, -1.0
+		for j := start; j < end; j++ {
+			area := math.Abs((x[prev]-avgX)*(y[j]-y[prev]) - (x[prev]-x[j])*(avgY-y[prev]))
+			if area > bestArea {
+				best, bestArea = j, area
+			}
+		}
+		selected = append(selected, best)
+		bounds = append(bounds, [2]int{start, end})
+		prev = best
+	}
+
+	selected = append(selected, n-1)
+	bounds = append(bounds, [2]int{n - 1, n})
+	return selected, bounds
+}
+
+// DownsampleLTTB reduces candles to at most threshold points with LTTB on their
+// closes, so long ranges keep their shape. Each output candle takes the time and
+// close of the point LTTB picked, the open of its bucket's first candle and the
+// bucket's high/low extremes; averages are pooled over the bucket (VWAP by
+// volume). Candles are returned as-is when they already fit or threshold is not
+// positive.
+func DownsampleLTTB(candles []models.PriceCandle, threshold int) []models.PriceCandle {
+	n := len(candles)
+	if threshold <= 0 || n <= threshold {
+		return candles
+	}
+	// Too few points for triangles: fold everything after the first candle
+	switch threshold {
+	case 1:
+		return []models.PriceCandle{mergeCandles(candles, candles[n-1])}
+	case 2:
+		return []models.PriceCandle{candles[0], mergeCandles(candles[1:], candles[n-1])}
+	}
+
+	x := make([]float64, n)
+	y := make([]float64, n)
+	for i, c := range candles {
+		x[i] = float64(c.Time.Unix())
+		y[i] = float64(c.Close)
+	}
+	selected, bounds := lttb(x, y, threshold)
+
+	out := make([]models.PriceCandle, len(selected))
+	for i, idx := range selected {
+		out[i] = mergeCandles(candles[bounds[i][0]:bounds[i][1]], candles[idx])
+	}
+	return out
+}
+
+// mergeCandles folds a run of candles into one positioned at pick
+func mergeCandles(run []models.PriceCandle, pick models.PriceCandle) models.PriceCandle {
+	merged := pick
+	merged.Open = run[0].Open
+	var avgSum, vwapSum, vwapVolume, plainVWAP float64
+	var volumeSum int64
+	for _, c := range run {
+		merged.High = max(merged.High, c.High)
+		merged.Low = min(merged.Low, c.Low)
+		avgSum += c.AvgPrice
+		volumeSum += c.Volume
+		vwapSum += c.VWAP * float64(c.Volume)
+		vwapVolume += float64(c.Volume)
+		plainVWAP += c.VWAP
+	}
+	merged.AvgPrice = avgSum / float64(len(run))
+	merged.Volume = volumeSum / int64(len(run))
+	if vwapVolume > 0 {
+		merged.VWAP = vwapSum / vwapVolume
+	} else {
+		merged.VWAP = plainVWAP / float64(len(run))
+	}
+	return merged
+}
+
+// DownsampleSeries reduces a line to at most threshold points with LTTB
+func DownsampleSeries(times []time.Time, prices []float64, threshold int) ([]time.Time, []float64) {
+	n := min(len(times), len(prices))
+	if threshold < 3 || n <= threshold {
+		return times, prices
+	}
+
+	x := make([]float64, n)
+	for i, t := range times[:n] {
+		x[i] = float64(t.Unix())
+	}
+	selected, _ := lttb(x, prices[:n], threshold)
+
+	outTimes := make([]time.Time, len(selected))
+	outPrices := make([]float64, len(selected))
+	for i, idx := range selected {
+		outTimes[i] = times[idx]
+		outPrices[i] = prices[idx]
+	}
+	return outTimes, outPrices
+}
@@ -0,0 +1,153 @@
+package services
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/akagifreeez/torn-market-chart/internal/models"
+)
+
+var downsampleStart = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// testCandles builds n hourly candles following a sine wave, with a single
+// spike high and dip low in the middle so extremes can be checked
+func testCandles(n int) []models.PriceCandle {
+	candles := make([]models.PriceCandle, n)
+	for i := range candles {
+		base := int64(1000 + 200*math.Sin(float64(i)/5))
+		candles[i] = models.PriceCandle{
+			Time:     downsampleStart.Add(time.Duration(i) * time.Hour),
+			ItemID:   1,
+			Open:     base - 5,
+			High:     base + 10,
+			Low:      base - 10,
+			Close:    base,
+			AvgPrice: float64(base),
+			Volume:   10,
+			VWAP:     float64(base),
+		}
+	}
+	if n > 4 {
+		candles[n/2].High = 99999
+		candles[n/2+1].Low = 1
+	}
+	return candles
+}
+
+func candleExtremes(candles []models.PriceCandle) (high, low int64) {
+	high, low = candles[0].High, candles[0].Low
+	for _, c := range candles {
+		high = max(high, c.High)
+		low = min(low, c.Low)
+	}
+	return high, low
+}
+
+func TestDownsampleLTTB(t *testing.T) {
+	candles := testCandles(500)
+	wantHigh, wantLow := candleExtremes(candles)
+
+	for _, threshold := range []int{1, 2, 3, 10, 168, 499} {
+		out := DownsampleLTTB(candles, threshold)
+
+		if len(out) != threshold {
+			t.Errorf("threshold %d: got %d candles", threshold, len(out))
+			continue
+		}
+		if last := out[len(out)-1]; !last.Time.Equal(candles[len(candles)-1].Time) || last.Close != candles[len(candles)-1].Close {
+			t.Errorf("threshold %d: last candle %v/%d, want the final input candle", threshold, last.Time, last.Close)
+		}
+		if threshold > 1 && (!out[0].Time.Equal(candles[0].Time) || out[0] != candles[0]) {
+			t.Errorf("threshold %d: first candle changed", threshold)
+		}
+		if out[0].Open != candles[0].Open {
+			t.Errorf("threshold %d: open = %d, want the first input open %d", threshold, out[0].Open, candles[0].Open)
+		}
+		if high, low := candleExtremes(out); high != wantHigh || low != wantLow {
+			t.Errorf("threshold %d: high/low = %d/%d, want %d/%d", threshold, high, low, wantHigh, wantLow)
+		}
+		for i := 1; i < len(out); i++ {
+			if !out[i].Time.After(out[i-1].Time) {
+				t.Errorf("threshold %d: candle %d not after candle %d", threshold, i, i-1)
+				break
+			}
+		}
+	}
+}
+
+func TestDownsampleLTTBPassthrough(t *testing.T) {
+	candles := testCandles(50)
+	for _, threshold := range []int{0, -1, 50, 100} {
+		if out := DownsampleLTTB(candles, threshold); len(out) != len(candles) {
+			t.Errorf("threshold %d: got %d candles, want all %d", threshold, len(out), len(candles))
+		}
+	}
+}
+
+func TestDownsampleLTTBMergesVolume(t *testing.T) {
+	candles := testCandles(100)
+	out := DownsampleLTTB(candles, 10)
+
+	// Every input candle has volume 10, so merged buckets keep that mean
+	// volume and a volume-weighted VWAP within the input range
+	minVWAP, maxVWAP := candles[0].VWAP, candles[0].VWAP
+	for _, c := range candles {
+		minVWAP, maxVWAP = min(minVWAP, c.VWAP), max(maxVWAP, c.VWAP)
+	}
+	for i, c := range out {
+		if c.Volume != 10 {
+			t.Errorf("candle %d: volume = %d, want 10", i, c.Volume)
+		}
+		if c.VWAP < minVWAP || c.VWAP > maxVWAP {
+			t.Errorf("candle %d: vwap %.1f outside input range", i, c.VWAP)
+		}
+	}
+}
+
+func TestDownsampleSeries(t *testing.T) {
+	const n = 1000
+	times := make([]time.Time, n)
+	prices := make([]float64, n)
+	for i := range times {
+		times[i] = downsampleStart.Add(time.Duration(i) * time.Minute)
+		prices[i] = 500 + 100*math.Sin(float64(i)/20)
+	}
+	prices[n/3] = 5000 // Spike LTTB should keep
+
+	for _, threshold := range []int{3, 50, 500} {
+		outTimes, outPrices := DownsampleSeries(times, prices, threshold)
+
+		if len(outTimes) != threshold || len(outPrices) != threshold {
+			t.Errorf("threshold %d: got %d times, %d prices", threshold, len(outTimes), len(outPrices))
+			continue
+		}
+		if !outTimes[0].Equal(times[0]) || outPrices[0] != prices[0] {
+			t.Errorf("threshold %d: first point not preserved", threshold)
+		}
+		if !outTimes[threshold-1].Equal(times[n-1]) || outPrices[threshold-1] != prices[n-1] {
+			t.Errorf("threshold %d: last point not preserved", threshold)
+		}
+		if threshold >= 50 {
+			found := false
+			for _, p := range outPrices {
+				found = found || p == 5000
+			}
+			if !found {
+				t.Errorf("threshold %d: spike dropped", threshold)
+			}
+		}
+	}
+}
+
+func TestDownsampleSeriesPassthrough(t *testing.T) {
+	times := []time.Time{downsampleStart, downsampleStart.Add(time.Hour), downsampleStart.Add(2 * time.Hour), downsampleStart.Add(3 * time.Hour)}
+	prices := []float64{1, 2, 3, 4}
+
+	for _, threshold := range []int{0, 1, 2, 4, 10} {
+		outTimes, outPrices := DownsampleSeries(times, prices, threshold)
+		if len(outTimes) != len(times) || len(outPrices) != len(prices) {
+			t.Errorf("threshold %d: got %d points, want all %d", threshold, len(outTimes), len(times))
+		}
+	}
+}
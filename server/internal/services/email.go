@@ -0,0 +1,146 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// emailSendTimeout bounds connecting to and talking with the SMTP server
+const emailSendTimeout = 20 * time.Second
+
+// EmailSender delivers multipart (plaintext + HTML) mail over SMTP.
+// A sender without host or from address is disabled and Send is a no-op.
+type EmailSender struct {
+	host string
+	port int
+	user string
+	pass string
+	from string
+}
+
+// NewEmailSender creates an SMTP sender. user/pass may be empty for servers
+// that don't require authentication.
+func NewEmailSender(host string, port int, user, pass, from string) *EmailSender {
+	return &EmailSender{host: host, port: port, user: user, pass: pass, from: from}
+}
+
+// Enabled reports whether SMTP is configured
+func (e *EmailSender) Enabled() bool {
+	return e != nil && e.host != "" && e.from != ""
+}
+
+// Send mails one message to a single recipient. STARTTLS is used whenever the
+// server offers it, and is required before sending credentials.
+func (e *EmailSender) Send(ctx context.Context, to, subject, text, html string) error {
+	if !e.Enabled() {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, emailSendTimeout)
+	defer cancel()
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", net.JoinHostPort(e.host, strconv.Itoa(e.port)))
+	if err != nil {
+		return fmt.Errorf("smtp dial: %w", err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	c, err := smtp.NewClient(conn, e.host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("smtp handshake: %w", err)
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: e.host}); err != nil {
+			return fmt.Errorf("smtp starttls: %w", err)
+		}
+	}
+	if e.user != "" {
+		// PlainAuth refuses to send credentials over an unencrypted connection
+		if err := c.Auth(smtp.PlainAuth("", e.user, e.pass, e.host)); err != nil {
+			return fmt.Errorf("smtp auth: %w", err)
+		}
+	}
+
+	msg, err := buildMultipartMessage(e.from, to, subject, text, html)
+	if err != nil {
+		return err
+	}
+	if err := c.Mail(e.from); err != nil {
+		return fmt.Errorf("smtp mail from: %w", err)
+	}
+	if err := c.Rcpt(to); err != nil {
+		return fmt.Errorf("smtp rcpt to: %w", err)
+	}
+	wc, err := c.Data()
+	if err != nil {
+		return fmt.Errorf("smtp data: %w", err)
+	}
+	if _, err := wc.Write(msg); err != nil {
+		wc.Close()
+		return fmt.Errorf("smtp write: %w", err)
+	}
+	if err := wc.Close(); err != nil {
+		return fmt.Errorf("smtp write: %w", err)
+	}
+	return c.Quit()
+}
+
+// buildMultipartMessage renders a multipart/alternative message with headers
+func buildMultipartMessage(from, to, subject, text, html string) ([]byte, error) {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for _, part := range []struct{ contentType, content string }{
+		{"text/plain; charset=UTF-8", text},
+		{"text/html; charset=UTF-8", html},
+	} {
+		w, err := mw.CreatePart(textproto.MIMEHeader{"Content-Type": {part.contentType}})
+		if err != nil {
+			return nil, err
+		}
+		w.Write([]byte(part.content))
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+
+	var msg bytes.Buffer
+	headers := []string{
+		"From: " + from,
+		"To: " + to,
+		"Subject: " + mimeHeader(subject),
+		"Date: " + time.Now().Format(time.RFC1123Z),
+		"MIME-Version: 1.0",
+		"Content-Type: multipart/alternative; boundary=" + mw.Boundary(),
+	}
+	msg.WriteString(strings.Join(headers, "\r\n"))
+	msg.WriteString("\r\n\r\n")
+	msg.Write(body.Bytes())
+	return msg.Bytes(), nil
+}
+
+// mimeHeader strips line breaks and encodes non-ASCII header text (e.g. the alert emoji)
+func mimeHeader(s string) string {
+	s = strings.NewReplacer("\r", "", "\n", " ").Replace(s)
+	for _, r := range s {
+		if r > 127 {
+			return mime.QEncoding.Encode("UTF-8", s)
+		}
+	}
+	return s
+}
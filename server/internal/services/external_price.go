@@ -3,24 +3,35 @@ package services
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"sync"
 	"time"
 
+	"golang.org/x/sync/singleflight"
 	"golang.org/x/time/rate"
 
 	"github.com/rs/zerolog/log"
 )
 
+// ErrRateLimited is returned when a source is throttling us (locally or upstream)
+var ErrRateLimited = errors.New("rate limited")
+
 // ExternalPriceClient fetches prices from TornExchange and Weav3r
 type ExternalPriceClient struct {
 	httpClient *http.Client
 
+	flights      singleflight.Group // Shared upstream fetches per source and item
+	flightMu     sync.Mutex
+	flightStates map[string]*flightState
+
 	// TornExchange Rate Limiting & Caching
-	teLimiter *rate.Limiter
-	teCache   sync.Map // map[int64]*teCacheEntry
+	teLimiter  *rate.Limiter
+	teCache    sync.Map // map[int64]*teCacheEntry
+	teCacheTTL time.Duration
+	teRefresh  sync.Map // map[int64]struct{}: items with a background refresh running
 }
 
 type teCacheEntry struct {
@@ -28,15 +39,18 @@ type teCacheEntry struct {
 	ExpiresAt time.Time
 }
 
-// NewExternalPriceClient creates a new client for external price APIs
-func NewExternalPriceClient() *ExternalPriceClient {
+// NewExternalPriceClient creates a new client for external price APIs.
+// TornExchange responses are cached for cacheTTL and requests are spaced by
+// teInterval (burst of 1 to strictly enforce spacing). Each request times out
+// after timeout.
+func NewExternalPriceClient(cacheTTL, teInterval, timeout time.Duration) *ExternalPriceClient {
 	return &ExternalPriceClient{
 		httpClient: &http.Client{
-			Timeout: 15 * time.Second,
+			Timeout: timeout,
 		},
-		// Limit to 10 requests per minute (1 request every 6 seconds) to be safe
-		// Allow burst of 1 to strictly enforce spacing
-		teLimiter: rate.NewLimiter(rate.Every(6*time.Second), 1),
+		flightStates: make(map[string]*flightState),
+		teLimiter:    rate.NewLimiter(rate.Every(teInterval), 1),
+		teCacheTTL:   cacheTTL,
 	}
 }
 
@@ -53,8 +67,9 @@ type TornExchangeResponse struct {
 // TornExchangePrice represents price data (internal use)
 type TornExchangePrice struct {
 	ItemID    int64 `json:"item_id"`
-	TEPrice   int64 `json:"te_price"`   // TornExchange buy price
-	TornPrice int64 `json:"torn_price"` // Torn market reference
+	TEPrice   int64 `json:"te_price"`        // TornExchange buy price
+	TornPrice int64 `json:"torn_price"`      // Torn market reference
+	Stale     bool  `json:"stale,omitempty"` // Served from an expired cache entry
 }
 
 // Weav3rListing represents a listing from Weav3r API
@@ -71,24 +86,124 @@ type Weav3rMarketResponse struct {
 	Listings []Weav3rListing `json:"listings"`
 }
 
+// teRefreshTimeout bounds a background refresh, including the limiter wait
+const teRefreshTimeout = 30 * time.Second
+
+// sharedFetchTimeout bounds a deduplicated upstream fetch. The fetch outlives
+// any single caller's cancellation since other callers may be waiting on it.
+const sharedFetchTimeout = 30 * time.Second
+
+// flightState is the fetch context shared by every caller waiting on a key
+type flightState struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	waiters int
+}
+
+// shared runs fn once for all concurrent callers using the same key. Each
+// caller still stops waiting when its own context is done, and the fetch is
+// cancelled once no caller is left waiting, so an abandoned flight doesn't
+// hold a limiter slot that live callers need.
+func (c *ExternalPriceClient) shared(ctx context.Context, key string, fn func(ctx context.Context) (any, error)) (any, error) {
+	c.flightMu.Lock()
+	st, ok := c.flightStates[key]
+	if !ok {
+		fetchCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), sharedFetchTimeout)
+		st = &flightState{ctx: fetchCtx, cancel: cancel}
+		c.flightStates[key] = st
+	}
+	st.waiters++
+	c.flightMu.Unlock()
+
+	ch := c.flights.DoChan(key, func() (any, error) {
+		return fn(st.ctx)
+	})
+	select {
+	case res := <-ch:
+		c.leaveFlight(key, st, false)
+		return res.Val, res.Err
+	case <-ctx.Done():
+		c.leaveFlight(key, st, true)
+		return nil, ctx.Err()
+	}
+}
+
+// leaveFlight drops a caller from key's flight. The last caller out cancels
+// the fetch context; if it gave up early, the flight is also forgotten so
+// the next caller starts a fresh fetch instead of joining the cancelled one.
+func (c *ExternalPriceClient) leaveFlight(key string, st *flightState, abandoned bool) {
+	c.flightMu.Lock()
+	defer c.flightMu.Unlock()
+
+	st.waiters--
+	if st.waiters > 0 {
+		return
+	}
+	st.cancel()
+	if c.flightStates[key] == st {
+		delete(c.flightStates, key)
+	}
+	if abandoned {
+		c.flights.Forget(key)
+	}
+}
+
 // FetchTornExchangePrice gets the trader price from TornExchange
 // Endpoint: GET https://tornexchange.com/api/te_price?item_id={id}
-// Implements caching (10 min) and rate limiting (10 req/min)
+// Implements caching and rate limiting (see NewExternalPriceClient). Expired
+// entries are served immediately, flagged stale, while a single background
+// refresh per item fetches a fresh value.
 func (c *ExternalPriceClient) FetchTornExchangePrice(ctx context.Context, itemID int64) (*TornExchangePrice, error) {
-	// 1. Check Cache
 	if val, ok := c.teCache.Load(itemID); ok {
 		entry := val.(*teCacheEntry)
 		if time.Now().Before(entry.ExpiresAt) {
 			return entry.Price, nil
 		}
-		// Cache expired, proceed to fetch
-		c.teCache.Delete(itemID)
+		c.refreshTornExchangePrice(itemID)
+		stale := *entry.Price
+		stale.Stale = true
+		return &stale, nil
 	}
 
-	// 2. Check Rate Limiter
+	return c.fetchTornExchangePrice(ctx, itemID)
+}
+
+// refreshTornExchangePrice refetches an item in the background unless a
+// refresh for it is already running
+func (c *ExternalPriceClient) refreshTornExchangePrice(itemID int64) {
+	if _, running := c.teRefresh.LoadOrStore(itemID, struct{}{}); running {
+		return
+	}
+
+	go func() {
+		defer c.teRefresh.Delete(itemID)
+
+		ctx, cancel := context.WithTimeout(context.Background(), teRefreshTimeout)
+		defer cancel()
+
+		if _, err := c.fetchTornExchangePrice(ctx, itemID); err != nil {
+			log.Warn().Err(err).Int64("item_id", itemID).Msg("Background TornExchange refresh failed")
+		}
+	}()
+}
+
+// fetchTornExchangePrice calls TornExchange and caches the result.
+// Concurrent calls for the same item share one request.
+func (c *ExternalPriceClient) fetchTornExchangePrice(ctx context.Context, itemID int64) (*TornExchangePrice, error) {
+	val, err := c.shared(ctx, fmt.Sprintf("te:%d", itemID), func(ctx context.Context) (any, error) {
+		return c.doFetchTornExchangePrice(ctx, itemID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return val.(*TornExchangePrice), nil
+}
+
+func (c *ExternalPriceClient) doFetchTornExchangePrice(ctx context.Context, itemID int64) (*TornExchangePrice, error) {
+	// 1. Check Rate Limiter
 	// Wait until allowed. Context cancellation will abort this.
 	if err := c.teLimiter.Wait(ctx); err != nil {
-		return nil, fmt.Errorf("rate limiter wait: %w", err)
+		return nil, fmt.Errorf("%w: %v", ErrRateLimited, err)
 	}
 
 	// Correct endpoint per Swagger: /api/te_price?item_id={id}
@@ -106,6 +221,10 @@ func (c *ExternalPriceClient) FetchTornExchangePrice(ctx context.Context, itemID
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return nil, fmt.Errorf("%w by TornExchange", ErrRateLimited)
+	}
+
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
 		// Truncate body to avoid flooding logs with HTML
@@ -131,10 +250,10 @@ func (c *ExternalPriceClient) FetchTornExchangePrice(ctx context.Context, itemID
 		TornPrice: response.Data.TornPrice,
 	}
 
-	// 3. Update Cache (TTL 10 min)
+	// 2. Update Cache
 	c.teCache.Store(itemID, &teCacheEntry{
 		Price:     result,
-		ExpiresAt: time.Now().Add(10 * time.Minute),
+		ExpiresAt: time.Now().Add(c.teCacheTTL),
 	})
 
 	return result, nil
@@ -142,7 +261,18 @@ func (c *ExternalPriceClient) FetchTornExchangePrice(ctx context.Context, itemID
 
 // FetchWeav3rMarketplace gets bazaar listings from Weav3r
 // Endpoint: GET https://weav3r.dev/api/marketplace/{item_id}
+// Concurrent calls for the same item share one request; treat the result as read-only.
 func (c *ExternalPriceClient) FetchWeav3rMarketplace(ctx context.Context, itemID int64) (*Weav3rMarketResponse, error) {
+	val, err := c.shared(ctx, fmt.Sprintf("weav3r:%d", itemID), func(ctx context.Context) (any, error) {
+		return c.fetchWeav3rMarketplace(ctx, itemID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return val.(*Weav3rMarketResponse), nil
+}
+
+func (c *ExternalPriceClient) fetchWeav3rMarketplace(ctx context.Context, itemID int64) (*Weav3rMarketResponse, error) {
 	url := fmt.Sprintf("https://weav3r.dev/api/marketplace/%d", itemID)
 
 	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
@@ -164,7 +294,7 @@ func (c *ExternalPriceClient) FetchWeav3rMarketplace(ctx context.Context, itemID
 			Str("retry_after", resp.Header.Get("Retry-After")).
 			Str("limit_reset", resp.Header.Get("X-RateLimit-Reset")).
 			Msg("Rate limited by Weav3r API")
-		return nil, fmt.Errorf("rate limited by Weav3r")
+		return nil, fmt.Errorf("%w by Weav3r", ErrRateLimited)
 	}
 
 	if resp.StatusCode != http.StatusOK {
@@ -181,21 +311,61 @@ func (c *ExternalPriceClient) FetchWeav3rMarketplace(ctx context.Context, itemID
 	return &result, nil
 }
 
+// SourceStatus reports whether an external source contributed to an overlay
+type SourceStatus struct {
+	Available bool   `json:"available"`
+	Reason    string `json:"reason,omitempty"` // rate_limited, timeout or error
+	Stale     bool   `json:"stale,omitempty"`  // Served from cache while a refresh runs
+}
+
+// PriceOverlay is the trader price overlay for one item. Sources that failed
+// are reported as unavailable instead of failing the whole overlay.
+type PriceOverlay struct {
+	TornExchangeBuyPrice int64 `json:"tornexchange_buy_price,omitempty"`
+	TornMarketPrice      int64 `json:"torn_market_price,omitempty"`
+	Weav3rMinBazaar      int64 `json:"weav3r_min_bazaar,omitempty"`
+
+	TornExchange SourceStatus `json:"tornexchange"`
+	Weav3r       SourceStatus `json:"weav3r"`
+}
+
+// HasPrices reports whether any source returned a price
+func (o *PriceOverlay) HasPrices() bool {
+	return o.TornExchangeBuyPrice > 0 || o.Weav3rMinBazaar > 0
+}
+
+// sourceStatus classifies a fetch error for SourceStatus.Reason
+func sourceStatus(err error) SourceStatus {
+	switch {
+	case err == nil:
+		return SourceStatus{Available: true}
+	case errors.Is(err, ErrRateLimited):
+		return SourceStatus{Reason: "rate_limited"}
+	case errors.Is(err, context.DeadlineExceeded):
+		return SourceStatus{Reason: "timeout"}
+	default:
+		return SourceStatus{Reason: "error"}
+	}
+}
+
 // GetTraderPriceOverlay fetches external prices for chart overlay
-func (c *ExternalPriceClient) GetTraderPriceOverlay(ctx context.Context, itemID int64) (map[string]int64, error) {
-	result := make(map[string]int64)
+func (c *ExternalPriceClient) GetTraderPriceOverlay(ctx context.Context, itemID int64) *PriceOverlay {
+	result := &PriceOverlay{}
 
 	// Fetch TornExchange price (with rate limit awareness)
 	tePrice, err := c.FetchTornExchangePrice(ctx, itemID)
+	result.TornExchange = sourceStatus(err)
 	if err != nil {
 		log.Warn().Err(err).Int64("item_id", itemID).Msg("Failed to fetch TornExchange price")
 	} else if tePrice.TEPrice > 0 {
-		result["tornexchange_buy_price"] = tePrice.TEPrice
-		result["torn_market_price"] = tePrice.TornPrice
+		result.TornExchange.Stale = tePrice.Stale
+		result.TornExchangeBuyPrice = tePrice.TEPrice
+		result.TornMarketPrice = tePrice.TornPrice
 	}
 
 	// Fetch Weav3r marketplace (for cross-checking)
 	weav3rData, err := c.FetchWeav3rMarketplace(ctx, itemID)
+	result.Weav3r = sourceStatus(err)
 	if err != nil {
 		log.Warn().Err(err).Int64("item_id", itemID).Msg("Failed to fetch Weav3r marketplace")
 	} else if len(weav3rData.Listings) > 0 {
@@ -206,10 +376,10 @@ func (c *ExternalPriceClient) GetTraderPriceOverlay(ctx context.Context, itemID
 				minPrice = listing.Price
 			}
 		}
-		result["weav3r_min_bazaar"] = minPrice
+		result.Weav3rMinBazaar = minPrice
 	}
 
-	return result, nil
+	return result
 }
 
 // CheckArbOpportunity checks if there's an arbitrage opportunity
@@ -0,0 +1,163 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSharedCancelsAbandonedFlight(t *testing.T) {
+	c := NewExternalPriceClient(time.Minute, time.Second, time.Second)
+
+	started := make(chan struct{})
+	fetchDone := make(chan error, 1)
+	fn := func(ctx context.Context) (any, error) {
+		close(started)
+		<-ctx.Done()
+		fetchDone <- ctx.Err()
+		return nil, ctx.Err()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() {
+		_, err := c.shared(ctx, "k", fn)
+		errc <- err
+	}()
+	<-started
+	cancel()
+
+	if err := <-errc; err != context.Canceled {
+		t.Fatalf("caller err = %v, want context.Canceled", err)
+	}
+	select {
+	case err := <-fetchDone:
+		if err != context.Canceled {
+			t.Fatalf("fetch ctx err = %v, want context.Canceled", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("abandoned flight was not cancelled")
+	}
+}
+
+func TestSharedKeepsFlightWhileWaited(t *testing.T) {
+	c := NewExternalPriceClient(time.Minute, time.Second, time.Second)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	calls := 0
+	fn := func(ctx context.Context) (any, error) {
+		calls++
+		close(started)
+		select {
+		case <-release:
+			return 42, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	leaver, cancel := context.WithCancel(context.Background())
+	leaverErr := make(chan error, 1)
+	go func() {
+		_, err := c.shared(leaver, "k", fn)
+		leaverErr <- err
+	}()
+	<-started
+
+	type result struct {
+		val any
+		err error
+	}
+	stayer := make(chan result, 1)
+	go func() {
+		val, err := c.shared(context.Background(), "k", fn)
+		stayer <- result{val, err}
+	}()
+
+	// Wait for the second caller to join before the first one leaves
+	waitForWaiters(c, "k", 2)
+	cancel()
+	if err := <-leaverErr; err != context.Canceled {
+		t.Fatalf("leaving caller err = %v, want context.Canceled", err)
+	}
+
+	close(release)
+	res := <-stayer
+	if res.err != nil || res.val != 42 {
+		t.Fatalf("remaining caller got (%v, %v), want (42, nil)", res.val, res.err)
+	}
+	if calls != 1 {
+		t.Fatalf("fn ran %d times, want 1", calls)
+	}
+}
+
+func TestSharedDeduplicatesConcurrentCallers(t *testing.T) {
+	c := NewExternalPriceClient(time.Minute, time.Second, time.Second)
+
+	const n = 10
+	release := make(chan struct{})
+	var calls atomic.Int32
+	fn := func(ctx context.Context) (any, error) {
+		calls.Add(1)
+		select {
+		case <-release:
+			return 42, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	// One caller gives up while the rest keep waiting
+	leaver, cancel := context.WithCancel(context.Background())
+	errs := make([]error, n)
+	vals := make([]any, n)
+	var wg sync.WaitGroup
+	for i := range n {
+		ctx := context.Background()
+		if i == 0 {
+			ctx = leaver
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			vals[i], errs[i] = c.shared(ctx, "k", fn)
+		}()
+	}
+
+	waitForWaiters(c, "k", n)
+	cancel()
+	waitForWaiters(c, "k", n-1)
+	close(release)
+	wg.Wait()
+
+	if errs[0] != context.Canceled {
+		t.Fatalf("cancelled caller err = %v, want context.Canceled", errs[0])
+	}
+	for i := 1; i < n; i++ {
+		if errs[i] != nil || vals[i] != 42 {
+			t.Fatalf("caller %d got (%v, %v), want (42, nil)", i, vals[i], errs[i])
+		}
+	}
+	if got := calls.Load(); got != 1 {
+		t.Fatalf("fn ran %d times, want 1", got)
+	}
+}
+
+// waitForWaiters blocks until want callers are waiting on key's flight
+func waitForWaiters(c *ExternalPriceClient, key string, want int) {
+	for {
+		c.flightMu.Lock()
+		waiters := 0
+		if st := c.flightStates[key]; st != nil {
+			waiters = st.waiters
+		}
+		c.flightMu.Unlock()
+		if waiters == want {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
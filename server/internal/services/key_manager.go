@@ -3,6 +3,7 @@ package services
 import (
 	"context"
 	"fmt"
+	"strconv"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -13,6 +14,7 @@ import (
 	"github.com/akagifreeez/torn-market-chart/internal/models"
 	"github.com/akagifreeez/torn-market-chart/pkg/crypto"
 	"github.com/akagifreeez/torn-market-chart/pkg/database"
+	"github.com/akagifreeez/torn-market-chart/pkg/tornapi"
 )
 
 type KeyManager struct {
@@ -23,32 +25,82 @@ type KeyManager struct {
 	mu      sync.RWMutex
 	pool    []string
 	poolIdx uint64
-	keyMap  map[string]string // plaintext key -> user_id (string)
+	keyMap  map[string]string    // plaintext key -> user_id (string)
+	benched map[string]time.Time // plaintext key -> when Torn's rate limit on it lifts
+
+	// Usage counted since the last flush to key_usage, by user ID
+	usageMu sync.Mutex
+	usage   map[string]*keyUsageDelta
+	// Consecutive rejections by Torn, by plaintext key
+	rejections map[string]int
+}
+
+// keyBenchDuration is how long a key is skipped after Torn reports it over
+// its limit (code 5); Torn counts requests per key per minute
+const keyBenchDuration = time.Minute
+
+// keyDisableThreshold is how many consecutive times Torn must reject a key
+// before it is disabled; transient failures neither count nor reset
+const keyDisableThreshold = 3
+
+// keyUsageDelta is usage not yet written to key_usage
+type keyUsageDelta struct {
+	uses, errors int64
+	lastUsed     time.Time
+	lastError    time.Time
+}
+
+// keyUsageFlushInterval is how often buffered key usage is written, so the
+// crawler's per-request RecordUsage calls become one batched upsert
+const keyUsageFlushInterval = 30 * time.Second
+
+// KeyUsageStats is a user key's recorded health
+type KeyUsageStats struct {
+	UserID      int64      `json:"user_id"`
+	Name        string     `json:"name"`
+	Active      bool       `json:"active"` // Key is still stored and in the pool
+	UsageCount  int64      `json:"usage_count"`
+	ErrorCount  int64      `json:"error_count"`
+	ErrorRate   float64    `json:"error_rate"` // error_count / usage_count
+	LastUsedAt  *time.Time `json:"last_used_at"`
+	LastErrorAt *time.Time `json:"last_error_at"`
 }
 
 func NewKeyManager(db *database.DB, cfg *config.Config) *KeyManager {
 	km := &KeyManager{
-		db:     db,
-		cfg:    cfg,
-		keyMap: make(map[string]string),
+		db:         db,
+		cfg:        cfg,
+		keyMap:     make(map[string]string),
+		benched:    make(map[string]time.Time),
+		usage:      make(map[string]*keyUsageDelta),
+		rejections: make(map[string]int),
 	}
 	// Initial load
 	km.RefreshPool(context.Background())
 	return km
 }
 
-// StartAutoRefresh starts a background goroutine to refresh the key pool periodically
+// StartAutoRefresh starts a background goroutine to refresh the key pool
+// periodically and flush buffered key usage stats
 func (km *KeyManager) StartAutoRefresh(ctx context.Context) {
 	go func() {
 		ticker := time.NewTicker(5 * time.Minute)
 		defer ticker.Stop()
+		flushTicker := time.NewTicker(keyUsageFlushInterval)
+		defer flushTicker.Stop()
 
 		for {
 			select {
 			case <-ctx.Done():
+				// Write what's left; ctx is already cancelled
+				flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				km.flushUsage(flushCtx)
+				cancel()
 				return
 			case <-ticker.C:
 				km.RefreshPool(ctx)
+			case <-flushTicker.C:
+				km.flushUsage(ctx)
 			}
 		}
 	}()
@@ -82,6 +134,12 @@ func (km *KeyManager) RefreshPool(ctx context.Context) {
 
 	var newPool []string
 	newMap := make(map[string]string)
+	type upgrade struct {
+		id        int64
+		encrypted string
+		plaintext string
+	}
+	var upgrades []upgrade // keys still stored in a legacy ciphertext format
 
 	for rows.Next() {
 		var id int64
@@ -99,32 +157,84 @@ func (km *KeyManager) RefreshPool(ctx context.Context) {
 		if decrypted != "" {
 			newPool = append(newPool, decrypted)
 			newMap[decrypted] = fmt.Sprintf("%d", id) // Store user ID as string
+			if crypto.NeedsUpgrade(encrypted) {
+				upgrades = append(upgrades, upgrade{id: id, encrypted: encrypted, plaintext: decrypted})
+			}
+		}
+	}
+	rows.Close()
+
+	// Incrementally migrate legacy ciphertext to the current versioned format
+	// (guarded on the old value so a concurrent login isn't overwritten)
+	for _, u := range upgrades {
+		if ctx.Err() != nil {
+			break // The remaining keys are upgraded on the next refresh
+		}
+		reencrypted, err := crypto.Encrypt(km.cfg.EncryptionKey, u.plaintext)
+		if err != nil {
+			continue
+		}
+		_, err = km.db.Pool.Exec(ctx, "UPDATE users SET encrypted_api_key = $1 WHERE id = $2 AND encrypted_api_key = $3", reencrypted, u.id, u.encrypted)
+		if err != nil {
+			log.Warn().Err(err).Int64("user_id", u.id).Msg("Failed to upgrade key ciphertext format")
 		}
 	}
+	if len(upgrades) > 0 {
+		log.Info().Int("count", len(upgrades)).Msg("Upgraded legacy key ciphertext to current format")
+	}
 
 	km.mu.Lock()
 	km.pool = newPool
 	km.keyMap = newMap
+	now := time.Now()
+	for key, until := range km.benched {
+		if _, ok := newMap[key]; !ok || !now.Before(until) {
+			delete(km.benched, key)
+		}
+	}
 	km.mu.Unlock()
 
 	log.Info().Int("count", len(newPool)).Msg("API key pool refreshed")
 }
 
-// GetNextKey returns the next available key in round-robin fashion
+// GetNextKey returns the next available key in round-robin fashion, skipping
+// benched keys. It returns "" when the pool is empty or every key is benched.
 func (km *KeyManager) GetNextKey() string {
 	km.mu.RLock()
 	defer km.mu.RUnlock()
 
-	if len(km.pool) == 0 {
-		return ""
+	now := time.Now()
+	for range km.pool {
+		idx := atomic.AddUint64(&km.poolIdx, 1)
+		key := km.pool[idx%uint64(len(km.pool))]
+		if !now.Before(km.benched[key]) {
+			return key
+		}
 	}
+	return ""
+}
 
-	idx := atomic.AddUint64(&km.poolIdx, 1)
-	return km.pool[idx%uint64(len(km.pool))]
+// bench skips key in GetNextKey for keyBenchDuration
+func (km *KeyManager) bench(key string) {
+	km.mu.Lock()
+	defer km.mu.Unlock()
+	km.benched[key] = time.Now().Add(keyBenchDuration)
 }
 
-// RecordUsage updates usage stats for a key (async)
-func (km *KeyManager) RecordUsage(key string, success bool) {
+// PoolSize returns the number of keys currently in the pool
+func (km *KeyManager) PoolSize() int {
+	km.mu.RLock()
+	defer km.mu.RUnlock()
+	return len(km.pool)
+}
+
+// RecordUsage counts a request made with a pool key; err is the request's
+// result. Counts are buffered in memory and written to key_usage every
+// keyUsageFlushInterval. A key Torn rate-limits is benched for
+// keyBenchDuration, and one Torn rejects keyDisableThreshold times in a row
+// is disabled.
+func (km *KeyManager) RecordUsage(key string, err error) {
+	success := err == nil
 	km.mu.RLock()
 	idStr, ok := km.keyMap[key]
 	km.mu.RUnlock()
@@ -133,11 +243,145 @@ func (km *KeyManager) RecordUsage(key string, success bool) {
 		return
 	}
 
-	// Currently skipping DB usage stats for user keys to avoid complexity/perf impact on user table.
-	// Logging failure is enough for now.
+	now := time.Now()
+	km.usageMu.Lock()
+	d := km.usage[idStr]
+	if d == nil {
+		d = &keyUsageDelta{}
+		km.usage[idStr] = d
+	}
+	d.uses++
+	d.lastUsed = now
 	if !success {
-		log.Warn().Str("user_id", idStr).Msg("API Key usage failed")
+		d.errors++
+		d.lastError = now
+	}
+
+	// Only Torn refusing the key counts towards disabling it; outages and
+	// rate limits say nothing about the key
+	disable := false
+	switch {
+	case success:
+		delete(km.rejections, key)
+	case tornapi.IsKeyRejected(err):
+		km.rejections[key]++
+		if km.rejections[key] >= keyDisableThreshold {
+			delete(km.rejections, key)
+			disable = true
+		}
+	}
+	km.usageMu.Unlock()
+
+	if tornapi.IsRateLimited(err) {
+		km.bench(key)
+	}
+	if !success {
+		log.Warn().Err(err).Str("user_id", idStr).Msg("API Key usage failed")
+	}
+	if disable {
+		log.Warn().Str("user_id", idStr).Int("rejections", keyDisableThreshold).Msg("Torn keeps rejecting API key, disabling it")
+		km.DisableKey(key)
+	}
+}
+
+// flushUsage writes buffered usage in one upsert. On failure the counts are
+// merged back so they are retried on the next flush.
+func (km *KeyManager) flushUsage(ctx context.Context) {
+	km.usageMu.Lock()
+	pending := km.usage
+	km.usage = make(map[string]*keyUsageDelta)
+	km.usageMu.Unlock()
+
+	if len(pending) == 0 {
+		return
+	}
+
+	ids := make([]int64, 0, len(pending))
+	uses := make([]int64, 0, len(pending))
+	errs := make([]int64, 0, len(pending))
+	lastUsed := make([]*time.Time, 0, len(pending))
+	lastError := make([]*time.Time, 0, len(pending))
+	for idStr, d := range pending {
+		id, err := strconv.ParseInt(idStr, 10, 64)
+		if err != nil {
+			continue
+		}
+		ids = append(ids, id)
+		uses = append(uses, d.uses)
+		errs = append(errs, d.errors)
+		lastUsed = append(lastUsed, &d.lastUsed)
+		if d.lastError.IsZero() {
+			lastError = append(lastError, nil)
+		} else {
+			lastError = append(lastError, &d.lastError)
+		}
+	}
+
+	// GREATEST ignores NULLs, so a flush without errors keeps last_error_at
+	_, err := km.db.Pool.Exec(ctx, `
+		INSERT INTO key_usage (user_id, usage_count, error_count, last_used_at, last_error_at)
+		SELECT u.user_id, u.uses, u.errors, u.last_used, u.last_error
+		FROM unnest($1::bigint[], $2::bigint[], $3::bigint[], $4::timestamptz[], $5::timestamptz[])
+			AS u(user_id, uses, errors, last_used, last_error)
+		JOIN users ON users.id = u.user_id
+		ON CONFLICT (user_id) DO UPDATE SET
+			usage_count = key_usage.usage_count + EXCLUDED.usage_count,
+			error_count = key_usage.error_count + EXCLUDED.error_count,
+			last_used_at = GREATEST(key_usage.last_used_at, EXCLUDED.last_used_at),
+			last_error_at = GREATEST(key_usage.last_error_at, EXCLUDED.last_error_at)
+	`, ids, uses, errs, lastUsed, lastError)
+	if err == nil {
+		return
+	}
+	log.Warn().Err(err).Int("keys", len(pending)).Msg("Failed to flush key usage stats, will retry")
+
+	km.usageMu.Lock()
+	for id, d := range pending {
+		cur := km.usage[id]
+		if cur == nil {
+			km.usage[id] = d
+			continue
+		}
+		cur.uses += d.uses
+		cur.errors += d.errors
+		if d.lastUsed.After(cur.lastUsed) {
+			cur.lastUsed = d.lastUsed
+		}
+		if d.lastError.After(cur.lastError) {
+			cur.lastError = d.lastError
+		}
+	}
+	km.usageMu.Unlock()
+}
+
+// UsageStats returns recorded key health for every user with a key or usage
+// history, most errors first
+func (km *KeyManager) UsageStats(ctx context.Context) ([]KeyUsageStats, error) {
+	rows, err := km.db.Pool.Query(ctx, `
+		SELECT u.id, u.name, u.encrypted_api_key IS NOT NULL,
+			COALESCE(k.usage_count, 0), COALESCE(k.error_count, 0), k.last_used_at, k.last_error_at
+		FROM users u
+		LEFT JOIN key_usage k ON k.user_id = u.id
+		WHERE u.encrypted_api_key IS NOT NULL OR k.user_id IS NOT NULL
+		ORDER BY COALESCE(k.error_count, 0) DESC, u.id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stats := make([]KeyUsageStats, 0)
+	for rows.Next() {
+		var s KeyUsageStats
+		if err := rows.Scan(&s.UserID, &s.Name, &s.Active, &s.UsageCount, &s.ErrorCount, &s.LastUsedAt, &s.LastErrorAt); err != nil {
+			return nil, err
+		}
+		if s.UsageCount > 0 {
+			s.ErrorRate = float64(s.ErrorCount) / float64(s.UsageCount)
+		}
+		stats = append(stats, s)
 	}
+	return stats, rows.Err()
 }
 
 // DisableKey marks a key as inactive (e.g. after too many errors)
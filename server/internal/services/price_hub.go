@@ -0,0 +1,137 @@
+package services
+
+import (
+	"sync"
+	"time"
+)
+
+// priceSubscriberBuffer is how many updates a subscriber may fall behind
+// before it is dropped as a slow consumer
+const priceSubscriberBuffer = 64
+
+// LivePrice is a price update fanned out to live subscribers
+type LivePrice struct {
+	ItemID   int64     `json:"item_id"`
+	Type     string    `json:"price_type"` // "market" or "bazaar"
+	Price    int64     `json:"price"`
+	Quantity int64     `json:"quantity"`
+	Time     time.Time `json:"time"`
+}
+
+// PriceHub is an in-process registry of live price subscribers, keyed by item
+type PriceHub struct {
+	mu    sync.Mutex
+	items map[int64]map[*PriceSubscriber]struct{}
+}
+
+// PriceSubscriber receives updates for the items it watches on C. C is closed
+// when the subscriber is closed or dropped for falling behind.
+type PriceSubscriber struct {
+	C      <-chan LivePrice
+	ch     chan LivePrice
+	hub    *PriceHub
+	items  map[int64]struct{} // Guarded by hub.mu
+	closed bool               // Guarded by hub.mu
+}
+
+// NewPriceHub creates an empty PriceHub
+func NewPriceHub() *PriceHub {
+	return &PriceHub{items: make(map[int64]map[*PriceSubscriber]struct{})}
+}
+
+// Subscribe registers a subscriber watching no items yet
+func (h *PriceHub) Subscribe() *PriceSubscriber {
+	ch := make(chan LivePrice, priceSubscriberBuffer)
+	return &PriceSubscriber{C: ch, ch: ch, hub: h, items: make(map[int64]struct{})}
+}
+
+// Publish delivers an update to every subscriber watching its item without
+// blocking; subscribers whose buffer is full are dropped. Safe on a nil hub.
+func (h *PriceHub) Publish(p LivePrice) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for sub := range h.items[p.ItemID] {
+		select {
+		case sub.ch <- p:
+		default:
+			h.removeLocked(sub)
+		}
+	}
+}
+
+// removeLocked unregisters sub from every item and closes its channel
+func (h *PriceHub) removeLocked(sub *PriceSubscriber) {
+	if sub.closed {
+		return
+	}
+	for id := range sub.items {
+		if subs := h.items[id]; subs != nil {
+			delete(subs, sub)
+			if len(subs) == 0 {
+				delete(h.items, id)
+			}
+		}
+	}
+	sub.items = nil
+	sub.closed = true
+	close(sub.ch)
+}
+
+// Watch adds items to the subscription and returns how many are now watched.
+// Ignored once the subscriber is closed.
+func (s *PriceSubscriber) Watch(ids ...int64) int {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+
+	if s.closed {
+		return 0
+	}
+	for _, id := range ids {
+		s.items[id] = struct{}{}
+		subs := s.hub.items[id]
+		if subs == nil {
+			subs = make(map[*PriceSubscriber]struct{})
+			s.hub.items[id] = subs
+		}
+		subs[s] = struct{}{}
+	}
+	return len(s.items)
+}
+
+// Unwatch removes items from the subscription and returns how many remain
+func (s *PriceSubscriber) Unwatch(ids ...int64) int {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+
+	for _, id := range ids {
+		if _, ok := s.items[id]; !ok {
+			continue
+		}
+		delete(s.items, id)
+		if subs := s.hub.items[id]; subs != nil {
+			delete(subs, s)
+			if len(subs) == 0 {
+				delete(s.hub.items, id)
+			}
+		}
+	}
+	return len(s.items)
+}
+
+// Count returns how many items are watched
+func (s *PriceSubscriber) Count() int {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	return len(s.items)
+}
+
+// Close unregisters the subscriber; safe to call more than once
+func (s *PriceSubscriber) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	s.hub.removeLocked(s)
+}
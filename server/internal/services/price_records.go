@@ -0,0 +1,158 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// recordReseedInterval bounds how long a running min/max is trusted before it is
+// re-read from the aggregates (so records age out of the lookback window)
+const recordReseedInterval = 1 * time.Hour
+
+// recordRawTail is how far back seeding reads raw prices instead of the daily
+// aggregate, covering its lag (1 day end_offset plus a daily schedule)
+const recordRawTail = 3 * 24 * time.Hour
+
+// priceRecord is the running min/max for one item and source
+type priceRecord struct {
+	Min      int64
+	Max      int64
+	SeededAt time.Time
+
+	// Extremes of the ticks observed since SeededAt. They may not be stored
+	// (the price throttle skips small moves), so a reseed folds them back in.
+	SeenMin int64
+	SeenMax int64
+}
+
+type recordKey struct {
+	ItemID int64
+	Type   string
+}
+
+// priceRecordTracker keeps per-item price records in memory. Records are seeded
+// from the daily continuous aggregates and then updated from incoming ticks,
+// so the hot path never scans history.
+type priceRecordTracker struct {
+	db       *pgxpool.Pool
+	lookback time.Duration // 0 means all history
+
+	mu      sync.Mutex
+	records map[recordKey]*priceRecord
+}
+
+func newPriceRecordTracker(db *pgxpool.Pool, lookback time.Duration) *priceRecordTracker {
+	return &priceRecordTracker{
+		db:       db,
+		lookback: lookback,
+		records:  make(map[recordKey]*priceRecord),
+	}
+}
+
+// Observe folds the price into the item's record and reports whether it set a new
+// low or high. The first observation of an unseen item never counts as a record.
+func (t *priceRecordTracker) Observe(ctx context.Context, itemID int64, source string, price int64) (isLow, isHigh bool, err error) {
+	if price <= 0 {
+		return false, false, nil
+	}
+
+	key := recordKey{ItemID: itemID, Type: source}
+
+	t.mu.Lock()
+	rec, ok := t.records[key]
+	t.mu.Unlock()
+
+	if !ok || time.Since(rec.SeededAt) > recordReseedInterval {
+		seeded, err := t.seed(ctx, key)
+		if err != nil {
+			return false, false, err
+		}
+		t.mu.Lock()
+		if ok {
+			// Keep extremes seen since the last seed so they don't fire again
+			seeded = mergeSeen(seeded, rec)
+		}
+		if seeded == nil {
+			// No history yet: start the record at this price
+			seeded = &priceRecord{Min: price, Max: price, SeededAt: time.Now()}
+		}
+		t.records[key] = seeded
+		rec = seeded
+		t.mu.Unlock()
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if rec.SeenMin == 0 || price < rec.SeenMin {
+		rec.SeenMin = price
+	}
+	if price > rec.SeenMax {
+		rec.SeenMax = price
+	}
+	if price < rec.Min {
+		rec.Min = price
+		isLow = true
+	}
+	if price > rec.Max {
+		rec.Max = price
+		isHigh = true
+	}
+	return isLow, isHigh, nil
+}
+
+// mergeSeen widens a fresh seed with the ticks prev observed since its own
+// seed. seeded may be nil when nothing is stored; prev must be locked.
+func mergeSeen(seeded, prev *priceRecord) *priceRecord {
+	if prev.SeenMin <= 0 {
+		return seeded
+	}
+	if seeded == nil {
+		return &priceRecord{Min: prev.SeenMin, Max: prev.SeenMax, SeededAt: time.Now()}
+	}
+	seeded.Min = min(seeded.Min, prev.SeenMin)
+	seeded.Max = max(seeded.Max, prev.SeenMax)
+	return seeded
+}
+
+// seed reads min/max over the lookback window: the daily aggregate for older
+// buckets and the raw table for the recent tail it may not have materialized
+func (t *priceRecordTracker) seed(ctx context.Context, key recordKey) (*priceRecord, error) {
+	view, table := "market_prices_1d", "market_prices"
+	if key.Type == "bazaar" {
+		view, table = "bazaar_prices_1d", "bazaar_prices"
+	}
+
+	now := time.Now()
+	since := time.Time{}
+	if t.lookback > 0 {
+		since = now.Add(-t.lookback)
+	}
+	tailStart := now.Add(-recordRawTail).Truncate(24 * time.Hour)
+	if tailStart.Before(since) {
+		tailStart = since
+	}
+
+	var minPrice, maxPrice *int64
+	query := fmt.Sprintf(`
+		SELECT MIN(lo), MAX(hi) FROM (
+			SELECT MIN(low) AS lo, MAX(high) AS hi
+			FROM %s
+			WHERE item_id = $1 AND bucket >= $2 AND bucket < $3 AND low > 0
+			UNION ALL
+			SELECT MIN(price), MAX(price)
+			FROM %s
+			WHERE item_id = $1 AND time >= $3 AND price > 0
+		) r
+	`, view, table)
+	if err := t.db.QueryRow(ctx, query, key.ItemID, since, tailStart).Scan(&minPrice, &maxPrice); err != nil {
+		return nil, fmt.Errorf("failed to seed price record: %w", err)
+	}
+	if minPrice == nil || maxPrice == nil {
+		return nil, nil
+	}
+	return &priceRecord{Min: *minPrice, Max: *maxPrice, SeededAt: now}, nil
+}
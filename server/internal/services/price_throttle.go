@@ -0,0 +1,90 @@
+package services
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// PriceThrottle decides whether a new price point is worth storing. A point is
+// stored when it moves at least minChangePct percent from the last stored
+// price for the same source and item, or when maxGap has passed since then so
+// the series stays alive for stable items. It is shared by every insert path
+// (WebSocket, bazaar poller, crawler) so they agree on the last stored value.
+type PriceThrottle struct {
+	minChangePct float64
+	maxGap       time.Duration
+
+	mu   sync.Mutex
+	last map[priceKey]storedPrice
+}
+
+type priceKey struct {
+	source string // "market" or "bazaar"
+	itemID int64
+}
+
+type storedPrice struct {
+	price int64
+	at    time.Time
+}
+
+// NewPriceThrottle creates a throttle; minChangePct <= 0 stores every point
+func NewPriceThrottle(minChangePct float64, maxGap time.Duration) *PriceThrottle {
+	return &PriceThrottle{
+		minChangePct: minChangePct,
+		maxGap:       maxGap,
+		last:         make(map[priceKey]storedPrice),
+	}
+}
+
+// ShouldStore reports whether price should be written for source/itemID at now
+func (t *PriceThrottle) ShouldStore(source string, itemID, price int64, now time.Time) bool {
+	if t == nil || t.minChangePct <= 0 {
+		return true
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	last, ok := t.last[priceKey{source, itemID}]
+	if !ok || last.price <= 0 {
+		return true
+	}
+	if t.gapExpiredLocked(last, now) {
+		return true
+	}
+
+	changePct := math.Abs(float64(price-last.price)) / float64(last.price) * 100
+	return changePct >= t.minChangePct
+}
+
+// GapExpired reports whether maxGap has passed since the last stored point
+// for source/itemID, so a point is due even if nothing else changed. It is
+// false when nothing has been stored yet or no max gap is configured.
+func (t *PriceThrottle) GapExpired(source string, itemID int64, now time.Time) bool {
+	if t == nil {
+		return false
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	last, ok := t.last[priceKey{source, itemID}]
+	return ok && t.gapExpiredLocked(last, now)
+}
+
+func (t *PriceThrottle) gapExpiredLocked(last storedPrice, now time.Time) bool {
+	return t.maxGap > 0 && now.Sub(last.at) >= t.maxGap
+}
+
+// Stored records a successfully written point
+func (t *PriceThrottle) Stored(source string, itemID, price int64, at time.Time) {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.last[priceKey{source, itemID}] = storedPrice{price: price, at: at}
+}
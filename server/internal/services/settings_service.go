@@ -148,6 +148,34 @@ func (s *SettingsService) Set(ctx context.Context, key, value, description strin
 	return nil
 }
 
+// SetListMember adds or removes item in a comma-separated list setting in a
+// single statement, so concurrent updates to the same list can't lose each
+// other's changes. Returns the stored list.
+func (s *SettingsService) SetListMember(ctx context.Context, key, item string, present bool, description string) (string, error) {
+	query := `
+		INSERT INTO system_settings (key, value, description, is_secret, updated_at)
+		VALUES ($1, CASE WHEN $3::boolean THEN $2::text ELSE '' END, $4, FALSE, NOW())
+		ON CONFLICT (key) DO UPDATE
+		SET value = array_to_string(
+				array_remove(string_to_array(replace(system_settings.value, ' ', ''), ','), $2::text)
+					|| CASE WHEN $3::boolean THEN ARRAY[$2::text] ELSE '{}'::text[] END,
+				','),
+		    description = EXCLUDED.description,
+		    updated_at = NOW()
+		RETURNING value
+	`
+	var value string
+	if err := s.db.QueryRow(ctx, query, key, item, present, description).Scan(&value); err != nil {
+		return "", err
+	}
+
+	s.mu.Lock()
+	s.cache[key] = value
+	s.mu.Unlock()
+
+	return value, nil
+}
+
 // GetAll returns all settings (masking secrets)
 func (s *SettingsService) GetAll(ctx context.Context) ([]Setting, error) {
 	rows, err := s.db.Query(ctx, "SELECT key, value, description, is_secret, updated_at FROM system_settings ORDER BY key")
@@ -18,25 +18,55 @@ const (
 	SubscriptionBatch = 10 // Interval between subscription batches
 )
 
+// WebSocket channel namespaces; a channel is "<namespace>_<itemID>" and pushes
+// carry the namespace so updates can be routed to the right price table
+const (
+	wsNamespaceMarket = "item-market"
+	wsNamespaceBazaar = "item-bazaar"
+)
+
+// wsNamespaceTypes maps a channel namespace to the price type it updates
+var wsNamespaceTypes = map[string]string{
+	wsNamespaceMarket: "market",
+	wsNamespaceBazaar: "bazaar",
+}
+
 type TornWebSocketService struct {
 	config       *config.Config
 	db           *pgxpool.Pool
 	alertService *AlertService
+	settings     *SettingsService
+	throttle     *PriceThrottle
+	hub          *PriceHub // Optional; receives every stored update for live clients
 	conn         *websocket.Conn
 	mu           sync.Mutex
-	subscribed   map[int64]bool // itemID -> true
+	subscribed   map[string]bool // channel -> true
+	nextSubID    int64           // Centrifugo command id for subscribe requests
 	running      bool
+	connected    bool // Authenticated and reading
 }
 
-func NewTornWebSocketService(cfg *config.Config, db *pgxpool.Pool, alertService *AlertService) *TornWebSocketService {
+// NewTornWebSocketService creates the Centrifugo client. hub may be nil when no
+// live clients are served from this process.
+func NewTornWebSocketService(cfg *config.Config, db *pgxpool.Pool, alertService *AlertService, settings *SettingsService, throttle *PriceThrottle, hub *PriceHub) *TornWebSocketService {
 	return &TornWebSocketService{
 		config:       cfg,
 		db:           db,
 		alertService: alertService,
-		subscribed:   make(map[int64]bool),
+		settings:     settings,
+		throttle:     throttle,
+		hub:          hub,
+		subscribed:   make(map[string]bool),
 	}
 }
 
+// Connected reports whether the WebSocket is currently authenticated
+func (s *TornWebSocketService) Connected() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.connected
+}
+
 func (s *TornWebSocketService) Start(ctx context.Context) {
 	s.running = true
 	log.Info().Msg("Starting Torn WebSocket Service...")
@@ -60,8 +90,23 @@ func (s *TornWebSocketService) Start(ctx context.Context) {
 	}
 }
 
+// token returns the current TORN_WS_TOKEN. It is read straight from
+// system_settings on every connect so an admin can rotate it without
+// restarting the worker, falling back to the environment value.
+func (s *TornWebSocketService) token(ctx context.Context) string {
+	if s.settings != nil {
+		val, err := s.settings.GetRaw(ctx, "TORN_WS_TOKEN")
+		if err != nil {
+			log.Warn().Err(err).Msg("Failed to read TORN_WS_TOKEN from settings, using environment value")
+		} else if val != "" {
+			return val
+		}
+	}
+	return s.config.TornWSToken
+}
+
 func (s *TornWebSocketService) run(ctx context.Context) error {
-	token := s.config.TornWSToken
+	token := s.token(ctx)
 	if token == "" {
 		return fmt.Errorf("TORN_WS_TOKEN is not set")
 	}
@@ -82,6 +127,7 @@ func (s *TornWebSocketService) run(ctx context.Context) error {
 			s.conn.Close()
 			s.conn = nil
 		}
+		s.connected = false
 		s.mu.Unlock()
 	}()
 
@@ -136,9 +182,17 @@ func (s *TornWebSocketService) run(ctx context.Context) error {
 	}
 
 	if errVal, ok := authResponse["error"]; ok && errVal != nil {
+		// Centrifugo reports rejected connect tokens here; the socket is
+		// useless until someone updates TORN_WS_TOKEN in the settings.
+		log.Error().
+			Interface("error", errVal).
+			Msg("Torn WebSocket rejected TORN_WS_TOKEN (invalid or expired); update it in the admin settings, it will be picked up on the next reconnect")
 		return fmt.Errorf("auth failed: %v", errVal)
 	}
 	log.Info().Msg("WebSocket authenticated successfully")
+	s.mu.Lock()
+	s.connected = true
+	s.mu.Unlock()
 
 	// Subscribe to watched items
 	if err := s.SubscribeWatchedItems(ctx); err != nil {
@@ -182,41 +236,55 @@ func (s *TornWebSocketService) SubscribeWatchedItems(ctx context.Context) error
 
 	log.Info().Int("count", len(items)).Msg("Subscribing to watched items...")
 
+	namespaces := []string{wsNamespaceMarket}
+	if s.config.TornWSBazaar {
+		namespaces = append(namespaces, wsNamespaceBazaar)
+	}
+
 	for i, id := range items {
-		if err := s.subscribe(id); err != nil {
-			log.Error().Err(err).Int64("id", id).Msg("Failed to subscribe")
+		for _, ns := range namespaces {
+			if err := s.subscribe(ns, id); err != nil {
+				log.Error().Err(err).Int64("id", id).Str("namespace", ns).Msg("Failed to subscribe")
+			}
 		}
 		if i > 0 && i%10 == 0 {
-			time.Sleep(100 * time.Millisecond) // Rate limit protection
+			// Rate limit protection
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(100 * time.Millisecond):
+			}
 		}
 	}
 	return nil
 }
 
-func (s *TornWebSocketService) subscribe(id int64) error {
+// subscribe joins the namespace's channel for an item (e.g. item-market_123)
+func (s *TornWebSocketService) subscribe(namespace string, id int64) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	if s.conn == nil {
 		return fmt.Errorf("no connection")
 	}
-	if s.subscribed[id] {
+	channel := fmt.Sprintf("%s_%d", namespace, id)
+	if s.subscribed[channel] {
 		return nil // Already subscribed
 	}
 
-	channel := fmt.Sprintf("item-market_%d", id)
+	s.nextSubID++
 	payload := map[string]interface{}{
 		"subscribe": map[string]string{
 			"channel": channel,
 		},
-		"id": id + 1000,
+		"id": s.nextSubID + 1000,
 	}
 
 	if err := s.conn.WriteJSON(payload); err != nil {
 		return err
 	}
 
-	s.subscribed[id] = true
+	s.subscribed[channel] = true
 	return nil
 }
 
@@ -239,7 +307,7 @@ func (s *TornWebSocketService) syncSubscriptionsLoop(ctx context.Context) {
 
 func (s *TornWebSocketService) handleMessage(ctx context.Context, data map[string]interface{}) {
 	// Parse Centrifugo push message
-	// expected: push -> pub -> data -> message -> namespace="item-market", action="update"
+	// expected: push -> pub -> data -> message -> namespace="item-market"|"item-bazaar", action="update"
 	push, ok := data["push"].(map[string]interface{})
 	if !ok {
 		// Log if it's not a push message (e.g. connect response or other control msg) but we expect those to be handled earlier or ignored
@@ -261,7 +329,8 @@ func (s *TornWebSocketService) handleMessage(ctx context.Context, data map[strin
 	namespace, _ := message["namespace"].(string)
 	action, _ := message["action"].(string)
 
-	if namespace == "item-market" && action == "update" {
+	priceType, known := wsNamespaceTypes[namespace]
+	if known && action == "update" {
 		updates, ok := message["data"].([]interface{})
 		if !ok {
 			return
@@ -288,43 +357,56 @@ func (s *TornWebSocketService) handleMessage(ctx context.Context, data map[strin
 			}
 
 			if tornID > 0 && minPrice > 0 {
-				s.processUpdate(ctx, tornID, minPrice, quantity)
+				s.processUpdate(ctx, priceType, tornID, minPrice, quantity)
 			}
 		}
 	}
 }
 
-func (s *TornWebSocketService) processUpdate(ctx context.Context, id int64, price int64, quantity int64) {
-	log.Info().Int64("id", id).Int64("price", price).Int64("qty", quantity).Msg("WS Update received")
+// processUpdate stores a pushed minimum price for priceType ("market" or "bazaar")
+// and runs alert checks
+func (s *TornWebSocketService) processUpdate(ctx context.Context, priceType string, id int64, price int64, quantity int64) {
+	log.Info().Str("type", priceType).Int64("id", id).Int64("price", price).Int64("qty", quantity).Msg("WS Update received")
 
 	now := time.Now()
 
-	// Insert into market_prices for historical data
-	_, err := s.db.Exec(ctx, `
-		INSERT INTO market_prices (time, item_id, price, quantity)
-		VALUES ($1, $2, $3, $4)
-	`, now, id, price, quantity)
-	if err != nil {
-		log.Warn().Err(err).Int64("id", id).Msg("Failed to insert market price from WS")
+	// Table and cache columns are fixed per type, never user input
+	table, cacheColumn, observedColumn := "market_prices", "last_market_price", "last_market_observed_at"
+	if priceType == "bazaar" {
+		table, cacheColumn, observedColumn = "bazaar_prices", "last_bazaar_price", "last_bazaar_observed_at"
+	}
+
+	// Insert into the price hypertable for historical data
+	if s.throttle.ShouldStore(priceType, id, price, now) {
+		_, err := s.db.Exec(ctx, fmt.Sprintf(`
+			INSERT INTO %s (time, item_id, price, quantity, source)
+			VALUES ($1, $2, $3, $4, $5)
+		`, table), now, id, price, quantity, models.PriceSourceWS)
+		if err != nil {
+			log.Warn().Err(err).Str("type", priceType).Int64("id", id).Msg("Failed to insert price from WS")
+		} else {
+			s.throttle.Stored(priceType, id, price, now)
+		}
 	}
 
 	// Update items cache
-	_, err = s.db.Exec(ctx, `
+	_, err := s.db.Exec(ctx, fmt.Sprintf(`
 		UPDATE items 
-		SET last_market_price = $1, last_updated_at = $2
+		SET %s = $1, %s = $2, last_updated_at = $2
 		WHERE id = $3
-	`, price, now, id)
+	`, cacheColumn, observedColumn), price, now, id)
 
 	if err != nil {
-		log.Error().Err(err).Int64("id", id).Msg("Failed to update market price from WS")
+		log.Error().Err(err).Str("type", priceType).Int64("id", id).Msg("Failed to update price from WS")
 		return
 	}
 
+	s.hub.Publish(LivePrice{ItemID: id, Type: priceType, Price: price, Quantity: quantity, Time: now})
+
 	// Fetch item for alert check
-	// We need Name and Bazaar price for the alert payload
 	var item models.Item
-	err = s.db.QueryRow(ctx, "SELECT id, name, last_bazaar_price FROM items WHERE id = $1", id).
-		Scan(&item.ID, &item.Name, &item.LastBazaarPrice)
+	err = s.db.QueryRow(ctx, "SELECT id, name FROM items WHERE id = $1", id).
+		Scan(&item.ID, &item.Name)
 
 	if err != nil {
 		log.Error().Err(err).Int64("id", id).Msg("Failed to fetch item for alert check")
@@ -336,7 +418,7 @@ func (s *TornWebSocketService) processUpdate(ctx context.Context, id int64, pric
 		ItemID:    item.ID,
 		ItemName:  item.Name,
 		Price:     price,
-		Type:      "market",
+		Type:      priceType,
 		Quantity:  quantity,
 		SellerID:  0, // WS doesn't provide seller
 		ListingID: 0, // WS doesn't provide listing ID
@@ -347,6 +429,6 @@ func (s *TornWebSocketService) processUpdate(ctx context.Context, id int64, pric
 		log.Error().Err(err).Msg("Alert check failed")
 	}
 	if triggered {
-		log.Info().Int64("id", id).Int64("price", price).Msg("Alert triggered via WebSocket!")
+		log.Info().Str("type", priceType).Int64("id", id).Int64("price", price).Msg("Alert triggered via WebSocket!")
 	}
 }
@@ -0,0 +1,108 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// WriteJob is a fire-and-forget database write
+type WriteJob struct {
+	Name string // For logging
+	Run  func(ctx context.Context) error
+}
+
+// WriteQueue runs fire-and-forget writes on a fixed number of workers.
+// Jobs are dropped (and logged) when the buffer is full rather than spawning
+// unbounded goroutines under load.
+type WriteQueue struct {
+	jobs    chan WriteJob
+	timeout time.Duration
+	wg      sync.WaitGroup
+
+	mu     sync.Mutex // Guards closed and sends on jobs against Close
+	closed bool
+}
+
+// NewWriteQueue creates a queue buffering up to size jobs, each bounded by timeout
+func NewWriteQueue(size int, timeout time.Duration) *WriteQueue {
+	return &WriteQueue{
+		jobs:    make(chan WriteJob, size),
+		timeout: timeout,
+	}
+}
+
+// Start launches the workers; they exit once Close is called and the buffer drains
+func (q *WriteQueue) Start(workers int) {
+	for i := 0; i < workers; i++ {
+		q.wg.Add(1)
+		go q.worker()
+	}
+	log.Info().Int("workers", workers).Int("buffer", cap(q.jobs)).Msg("Background write queue started")
+}
+
+// Enqueue schedules a job without blocking. Returns false if the queue is
+// full or already closed.
+func (q *WriteQueue) Enqueue(job WriteJob) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		log.Warn().Str("job", job.Name).Msg("Background write queue closed, dropping job")
+		return false
+	}
+
+	select {
+	case q.jobs <- job:
+		return true
+	default:
+		log.Warn().Str("job", job.Name).Msg("Background write queue full, dropping job")
+		return false
+	}
+}
+
+// Close stops accepting jobs and waits for queued ones to finish, or until ctx is done
+func (q *WriteQueue) Close(ctx context.Context) error {
+	q.mu.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.jobs)
+	}
+	q.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		q.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (q *WriteQueue) worker() {
+	defer q.wg.Done()
+	for job := range q.jobs {
+		q.run(job)
+	}
+}
+
+func (q *WriteQueue) run(job WriteJob) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Error().Interface("panic", r).Str("job", job.Name).Msg("Recovered from panic in background write")
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), q.timeout)
+	defer cancel()
+
+	if err := job.Run(ctx); err != nil {
+		log.Warn().Err(err).Str("job", job.Name).Msg("Background write failed")
+	}
+}
@@ -0,0 +1,68 @@
+package workers
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog/log"
+
+	"github.com/akagifreeez/torn-market-chart/internal/config"
+)
+
+// AlertStatePruner removes alert_states rows whose user_alerts entry is gone.
+// DeleteAlert clears the state it owns, but alerts removed any other way
+// (user/item deletion, manual cleanup) would otherwise leave dedup state behind.
+type AlertStatePruner struct {
+	db       *pgxpool.Pool
+	interval time.Duration
+}
+
+// NewAlertStatePruner creates a new AlertStatePruner worker
+func NewAlertStatePruner(db *pgxpool.Pool, cfg *config.Config) *AlertStatePruner {
+	return &AlertStatePruner{
+		db:       db,
+		interval: cfg.AlertStatePruneInterval,
+	}
+}
+
+// Start begins the periodic pruning
+func (p *AlertStatePruner) Start(ctx context.Context) {
+	if p.interval <= 0 {
+		log.Info().Msg("Alert state pruner disabled")
+		return
+	}
+	log.Info().Dur("interval", p.interval).Msg("Starting Alert State Pruner worker")
+
+	p.prune(ctx)
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Info().Msg("Alert State Pruner worker stopped")
+			return
+		case <-ticker.C:
+			p.prune(ctx)
+		}
+	}
+}
+
+func (p *AlertStatePruner) prune(ctx context.Context) {
+	tag, err := p.db.Exec(ctx, `
+		DELETE FROM alert_states s
+		WHERE NOT EXISTS (
+			SELECT 1 FROM user_alerts ua
+			WHERE ua.user_id = s.user_id AND ua.item_id = s.item_id
+		)
+	`)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to prune alert states")
+		return
+	}
+	if n := tag.RowsAffected(); n > 0 {
+		log.Info().Int64("removed", n).Msg("Pruned orphaned alert states")
+	}
+}
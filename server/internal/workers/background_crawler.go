@@ -2,6 +2,7 @@ package workers
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"time"
 
@@ -9,6 +10,7 @@ import (
 	"github.com/rs/zerolog/log"
 
 	"github.com/akagifreeez/torn-market-chart/internal/config"
+	"github.com/akagifreeez/torn-market-chart/internal/models"
 	"github.com/akagifreeez/torn-market-chart/internal/services"
 	"github.com/akagifreeez/torn-market-chart/pkg/tornapi"
 )
@@ -18,15 +20,17 @@ type BackgroundCrawler struct {
 	db         *pgxpool.Pool
 	client     *tornapi.Client
 	keyManager *services.KeyManager
+	throttle   *services.PriceThrottle
 	interval   time.Duration
 }
 
 // NewBackgroundCrawler creates a new BackgroundCrawler worker
-func NewBackgroundCrawler(db *pgxpool.Pool, client *tornapi.Client, km *services.KeyManager, cfg *config.Config) *BackgroundCrawler {
+func NewBackgroundCrawler(db *pgxpool.Pool, client *tornapi.Client, km *services.KeyManager, throttle *services.PriceThrottle, cfg *config.Config) *BackgroundCrawler {
 	return &BackgroundCrawler{
 		db:         db,
 		client:     client,
 		keyManager: km,
+		throttle:   throttle,
 		interval:   cfg.BackgroundCrawlInterval,
 	}
 }
@@ -88,23 +92,35 @@ func (c *BackgroundCrawler) crawlNext(ctx context.Context) {
 	if key != "" {
 		marketData, err = c.client.FetchMarketPriceWithKey(ctx, itemID, key)
 	} else {
-		// Fallback to default client keys if key manager has no keys (shouldn't happen if env keys are loaded)
-		log.Warn().Msg("BackgroundCrawler: KeyManager returned empty key, using default client rotation")
+		// Fallback to default client keys if key manager has no usable keys
+		if c.keyManager.PoolSize() == 0 {
+			log.Warn().Msg("BackgroundCrawler: KeyManager returned empty key, using default client rotation")
+		} else {
+			log.Debug().Msg("BackgroundCrawler: Every pool key is rate limited, using default client rotation")
+		}
 		marketData, err = c.client.FetchMarketPrice(ctx, itemID)
 	}
 
 	if err != nil {
-		log.Error().Err(err).Int64("id", itemID).Msg("BackgroundCrawler: Failed to fetch market data")
-		// If key was used, record error
+		switch {
+		case tornapi.IsRateLimited(err):
+			// RecordUsage benches the key; the next tick uses another one
+			log.Warn().Err(err).Int64("id", itemID).Msg("BackgroundCrawler: Rate limited by Torn, benching key")
+		case tornapi.IsKeyRejected(err):
+			log.Warn().Err(err).Int64("id", itemID).Msg("BackgroundCrawler: Torn rejected the API key")
+		default:
+			log.Error().Err(err).Int64("id", itemID).Msg("BackgroundCrawler: Failed to fetch market data")
+		}
+		// If key was used, record error (KeyManager disables keys Torn keeps rejecting)
 		if key != "" {
-			c.keyManager.RecordUsage(key, false)
+			c.keyManager.RecordUsage(key, err)
 		}
 		return
 	}
 
 	// Record success
 	if key != "" {
-		c.keyManager.RecordUsage(key, true)
+		c.keyManager.RecordUsage(key, nil)
 	}
 
 	// 3. Store data
@@ -116,12 +132,32 @@ func (c *BackgroundCrawler) crawlNext(ctx context.Context) {
 	if marketData.ItemMarket != nil && len(marketData.ItemMarket.Listings) > 0 {
 		minPrice = marketData.ItemMarket.Listings[0].Price
 		// Insert into market_prices
-		_, err = c.db.Exec(ctx, `
-			INSERT INTO market_prices (time, item_id, price, quantity)
-			VALUES ($1, $2, $3, $4)
-		`, now, itemID, minPrice, marketData.ItemMarket.Listings[0].Quantity)
-		if err != nil {
-			log.Warn().Err(err).Msg("BackgroundCrawler: Failed to insert market price")
+		if c.throttle.ShouldStore("market", itemID, minPrice, now) {
+			_, err = c.db.Exec(ctx, `
+				INSERT INTO market_prices (time, item_id, price, quantity, source)
+				VALUES ($1, $2, $3, $4, $5)
+			`, now, itemID, minPrice, marketData.ItemMarket.Listings[0].Quantity, models.PriceSourceCrawler)
+			if err != nil {
+				log.Warn().Err(err).Msg("BackgroundCrawler: Failed to insert market price")
+			} else {
+				c.throttle.Stored("market", itemID, minPrice, now)
+			}
+		}
+	}
+
+	// Store price by stack size so bulk pricing is visible
+	if marketData.ItemMarket != nil {
+		if tiers := computePriceTiers(marketData.ItemMarket.Listings); len(tiers) > 0 {
+			if tiersJSON, err := json.Marshal(tiers); err == nil {
+				_, err = c.db.Exec(ctx, `
+					INSERT INTO price_tiers (item_id, tiers, updated_at)
+					VALUES ($1, $2, $3)
+					ON CONFLICT (item_id) DO UPDATE SET tiers = EXCLUDED.tiers, updated_at = EXCLUDED.updated_at
+				`, itemID, tiersJSON, now)
+				if err != nil {
+					log.Warn().Err(err).Msg("BackgroundCrawler: Failed to store price tiers")
+				}
+			}
 		}
 	}
 
@@ -129,12 +165,16 @@ func (c *BackgroundCrawler) crawlNext(ctx context.Context) {
 	if marketData.Bazaar != nil && len(marketData.Bazaar.Listings) > 0 {
 		minBazaar = marketData.Bazaar.Listings[0].Price
 		// Insert into bazaar_prices
-		_, err = c.db.Exec(ctx, `
-			INSERT INTO bazaar_prices (time, item_id, price, quantity)
-			VALUES ($1, $2, $3, $4)
-		`, now, itemID, minBazaar, marketData.Bazaar.Listings[0].Quantity)
-		if err != nil {
-			log.Warn().Err(err).Msg("BackgroundCrawler: Failed to insert bazaar price")
+		if c.throttle.ShouldStore("bazaar", itemID, minBazaar, now) {
+			_, err = c.db.Exec(ctx, `
+				INSERT INTO bazaar_prices (time, item_id, price, quantity, source)
+				VALUES ($1, $2, $3, $4, $5)
+			`, now, itemID, minBazaar, marketData.Bazaar.Listings[0].Quantity, models.PriceSourceCrawler)
+			if err != nil {
+				log.Warn().Err(err).Msg("BackgroundCrawler: Failed to insert bazaar price")
+			} else {
+				c.throttle.Stored("bazaar", itemID, minBazaar, now)
+			}
 		}
 	}
 
@@ -145,12 +185,12 @@ func (c *BackgroundCrawler) crawlNext(ctx context.Context) {
 	argIdx := 2
 
 	if minPrice > 0 {
-		query += fmt.Sprintf(", last_market_price = $%d", argIdx)
+		query += fmt.Sprintf(", last_market_price = $%d, last_market_observed_at = $1", argIdx)
 		args = append(args, minPrice)
 		argIdx++
 	}
 	if minBazaar > 0 {
-		query += fmt.Sprintf(", last_bazaar_price = $%d", argIdx)
+		query += fmt.Sprintf(", last_bazaar_price = $%d, last_bazaar_observed_at = $1", argIdx)
 		args = append(args, minBazaar)
 		argIdx++
 	}
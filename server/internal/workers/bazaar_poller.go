@@ -2,6 +2,8 @@ package workers
 
 import (
 	"context"
+	"encoding/json"
+	"sort"
 	"sync"
 	"time"
 
@@ -9,14 +11,47 @@ import (
 	"github.com/rs/zerolog/log"
 
 	"github.com/akagifreeez/torn-market-chart/internal/config"
+	"github.com/akagifreeez/torn-market-chart/internal/models"
 	"github.com/akagifreeez/torn-market-chart/internal/services"
 	"github.com/akagifreeez/torn-market-chart/pkg/tornapi"
 )
 
+// Smart suspension tuning.
+// After failThreshold consecutive failures an item enters cooldown. The failure
+// count is kept until a success, so a single failure right after a cooldown
+// starts the next one. Each further cooldown cycle escalates along
+// cooldownSchedule (capped at the last entry) and the escalation is only
+// forgotten after recoveryStreak consecutive successes, so permanently dead
+// items (e.g. delisted) stop burning Weav3r calls.
+const (
+	failThreshold  = 3
+	recoveryStreak = 5
+)
+
+var cooldownSchedule = []time.Duration{
+	1 * time.Hour,
+	4 * time.Hour,
+	24 * time.Hour,
+	72 * time.Hour,
+}
+
 // ItemState tracks the health of an item for smart suspension
 type ItemState struct {
-	FailCount     int
-	CooldownUntil time.Time
+	FailCount      int
+	CooldownCycles int // Consecutive cooldowns without a sustained recovery
+	SuccessStreak  int
+	CooldownUntil  time.Time
+}
+
+// cooldownFor returns the cooldown duration for the given (1-based) cycle
+func cooldownFor(cycle int) time.Duration {
+	if cycle < 1 {
+		cycle = 1
+	}
+	if cycle > len(cooldownSchedule) {
+		cycle = len(cooldownSchedule)
+	}
+	return cooldownSchedule[cycle-1]
 }
 
 // BazaarPoller handles high-frequency bazaar price fetching using Weav3r.dev API
@@ -24,25 +59,79 @@ type BazaarPoller struct {
 	db              *pgxpool.Pool
 	weav3rClient    *services.ExternalPriceClient
 	alertService    *services.AlertService
+	throttle        *services.PriceThrottle
 	interval        time.Duration
 	maxConcurrent   int
 	bazaarRateLimit int
+	watchedShare    float64 // 0 = watched items are unlimited
+	stalePhase      bool
 	itemStates      map[int64]*ItemState
 	statesMu        sync.RWMutex
-	limiter         *tornapi.RateLimiter
+	limiter         tornapi.Limiter
+
+	lastCycle   CycleStats
+	lastCycleMu sync.RWMutex
+
+	// Cheapest listing last written to bazaar_prices, to skip unchanged rows
+	lastStored   map[int64]storedListing
+	lastStoredMu sync.Mutex
+
+	// Previous cycle's listings per item, diffed to infer sales
+	prevListings   map[int64][]services.Weav3rListing
+	prevListingsMu sync.Mutex
+}
+
+// storedListing identifies a stored cheapest listing
+type storedListing struct {
+	Price    int64
+	Quantity int64
+	SellerID int64
+}
+
+// CycleStats summarizes the most recent poll cycle
+type CycleStats struct {
+	StartedAt time.Time `json:"started_at"`
+	ElapsedMs int64     `json:"elapsed_ms"`
+	Watched   int       `json:"watched"`
+	Stale     int       `json:"stale"`
+	Budget    int       `json:"budget"`
+}
+
+// ItemCooldown describes an item currently suspended by smart suspension
+type ItemCooldown struct {
+	ItemID         int64     `json:"item_id"`
+	CooldownCycles int       `json:"cooldown_cycles"`
+	CooldownUntil  time.Time `json:"cooldown_until"`
+}
+
+// PollerSnapshot is a point-in-time view of the poller's internal state
+type PollerSnapshot struct {
+	TrackedStates int            `json:"tracked_states"`
+	InCooldown    int            `json:"in_cooldown"`
+	Cooldowns     []ItemCooldown `json:"cooldowns"`
+	LastCycle     CycleStats     `json:"last_cycle"`
 }
 
-// NewBazaarPoller creates a new BazaarPoller worker
-func NewBazaarPoller(db *pgxpool.Pool, cfg *config.Config, alertService *services.AlertService, limiter *tornapi.RateLimiter) *BazaarPoller {
+// NewBazaarPoller creates a new BazaarPoller worker (nil limiter disables limiting)
+func NewBazaarPoller(db *pgxpool.Pool, cfg *config.Config, alertService *services.AlertService, throttle *services.PriceThrottle, limiter tornapi.Limiter) *BazaarPoller {
+	if limiter == nil {
+		limiter = tornapi.NoopLimiter{}
+	}
+
 	return &BazaarPoller{
 		db:              db,
-		weav3rClient:    services.NewExternalPriceClient(),
+		weav3rClient:    services.NewExternalPriceClient(cfg.TornExchangeCacheTTL, cfg.TornExchangeInterval, cfg.ExternalAPITimeout),
 		alertService:    alertService,
+		throttle:        throttle,
 		interval:        cfg.BazaarPollInterval,
 		maxConcurrent:   cfg.MaxConcurrentFetches,
 		bazaarRateLimit: cfg.BazaarRateLimit,
+		watchedShare:    cfg.BazaarWatchedShare,
+		stalePhase:      cfg.BazaarStalePhase,
 		itemStates:      make(map[int64]*ItemState),
 		limiter:         limiter,
+		lastStored:      make(map[int64]storedListing),
+		prevListings:    make(map[int64][]services.Weav3rListing),
 	}
 }
 
@@ -73,20 +162,27 @@ func (b *BazaarPoller) Start(ctx context.Context) {
 func (b *BazaarPoller) pollAll(ctx context.Context) {
 	start := time.Now()
 
-	// Phase 1: Watched items (high priority)
+	// Rate budget per cycle = (rateLimit / 60) * interval_seconds
+	intervalSec := b.interval.Seconds()
+	budgetPerCycle := int(float64(b.bazaarRateLimit) / 60.0 * intervalSec)
+
+	// Phase 1: Watched items (high priority), optionally capped to their share
+	// of the budget. Watched items come stalest first, so a cap rotates through them.
 	watchedItems := b.getWatchedItems(ctx)
+	if b.watchedShare > 0 && b.watchedShare < 1 {
+		if limit := int(float64(budgetPerCycle) * b.watchedShare); len(watchedItems) > limit {
+			watchedItems = watchedItems[:limit]
+		}
+	}
 	watchedCount := b.fetchItems(ctx, watchedItems, "Phase1-Watched")
 
 	// Phase 2: Fill remaining rate budget with stale tracked items
-	// Calculate how many requests we can still make this cycle
-	// Rate budget per cycle = (rateLimit / 60) * interval_seconds
-	intervalSec := b.interval.Seconds()
-	budgetPerCycle := int(float64(b.bazaarRateLimit) / 60.0 * intervalSec)
 	remaining := budgetPerCycle - watchedCount
-	if remaining > 0 {
+	if b.stalePhase && remaining > 0 {
 		staleItems := b.getStaleTrackedItems(ctx, remaining)
 		if len(staleItems) > 0 {
 			staleCount := b.fetchItems(ctx, staleItems, "Phase2-Stale")
+			b.recordCycle(start, watchedCount, staleCount, budgetPerCycle)
 			log.Debug().
 				Int("watched", watchedCount).
 				Int("stale", staleCount).
@@ -97,6 +193,7 @@ func (b *BazaarPoller) pollAll(ctx context.Context) {
 		}
 	}
 
+	b.recordCycle(start, watchedCount, 0, budgetPerCycle)
 	log.Debug().
 		Int("watched", watchedCount).
 		Int("budget", budgetPerCycle).
@@ -104,13 +201,56 @@ func (b *BazaarPoller) pollAll(ctx context.Context) {
 		Msg("Bazaar poll cycle completed")
 }
 
+// recordCycle stores the stats of a completed poll cycle
+func (b *BazaarPoller) recordCycle(start time.Time, watched, stale, budget int) {
+	b.lastCycleMu.Lock()
+	b.lastCycle = CycleStats{
+		StartedAt: start,
+		ElapsedMs: time.Since(start).Milliseconds(),
+		Watched:   watched,
+		Stale:     stale,
+		Budget:    budget,
+	}
+	b.lastCycleMu.Unlock()
+}
+
+// Snapshot returns the current cooldown states and last cycle stats
+func (b *BazaarPoller) Snapshot() PollerSnapshot {
+	now := time.Now()
+	snap := PollerSnapshot{Cooldowns: make([]ItemCooldown, 0)}
+
+	b.statesMu.RLock()
+	snap.TrackedStates = len(b.itemStates)
+	for id, state := range b.itemStates {
+		if now.Before(state.CooldownUntil) {
+			snap.Cooldowns = append(snap.Cooldowns, ItemCooldown{
+				ItemID:         id,
+				CooldownCycles: state.CooldownCycles,
+				CooldownUntil:  state.CooldownUntil,
+			})
+		}
+	}
+	b.statesMu.RUnlock()
+	snap.InCooldown = len(snap.Cooldowns)
+
+	sort.Slice(snap.Cooldowns, func(i, j int) bool {
+		return snap.Cooldowns[i].CooldownUntil.Before(snap.Cooldowns[j].CooldownUntil)
+	})
+
+	b.lastCycleMu.RLock()
+	snap.LastCycle = b.lastCycle
+	b.lastCycleMu.RUnlock()
+
+	return snap
+}
+
 // getWatchedItems returns items in user watchlists
 func (b *BazaarPoller) getWatchedItems(ctx context.Context) []itemInfo {
 	rows, err := b.db.Query(ctx, `
-		SELECT DISTINCT i.id, i.name 
+		SELECT i.id, i.name
 		FROM items i
-		JOIN user_watchlists uw ON i.id = uw.item_id
-		ORDER BY i.id
+		WHERE EXISTS (SELECT 1 FROM user_watchlists uw WHERE uw.item_id = i.id)
+		ORDER BY i.last_updated_at ASC NULLS FIRST, i.id
 	`)
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to fetch watched items")
@@ -162,6 +302,7 @@ func (b *BazaarPoller) scanItems(rows interface {
 		b.statesMu.RUnlock()
 
 		if state != nil && time.Now().Before(state.CooldownUntil) {
+			b.forgetListings(item.ID)
 			continue
 		}
 
@@ -183,22 +324,30 @@ func (b *BazaarPoller) fetchItems(ctx context.Context, items []itemInfo, phase s
 	failCount := 0
 	var countMu sync.Mutex
 
+	// Stop handing out work on shutdown; in-flight fetches see the same ctx.
+	// Cancelled items are neither successes nor failures.
+dispatch:
 	for _, item := range items {
+		select {
+		case <-ctx.Done():
+			break dispatch
+		case sem <- struct{}{}: // Acquire
+		}
 		wg.Add(1)
-		sem <- struct{}{} // Acquire
 
 		go func(item itemInfo) {
 			defer wg.Done()
 			defer func() { <-sem }() // Release
 
 			// Rate Limiting
-			if b.limiter != nil {
-				if err := b.limiter.WaitForTicket(ctx, 1); err != nil {
-					return
-				}
+			if err := b.limiter.WaitForTicket(ctx, 1); err != nil {
+				return
 			}
 
 			if err := b.fetchAndStore(ctx, item.ID); err != nil {
+				if ctx.Err() != nil {
+					return // Shutdown, not the item's fault
+				}
 				countMu.Lock()
 				failCount++
 				countMu.Unlock()
@@ -227,6 +376,49 @@ func (b *BazaarPoller) fetchItems(ctx context.Context, items []itemInfo, phase s
 	return successCount
 }
 
+// listingChanged reports whether listing differs from the last stored one
+func (b *BazaarPoller) listingChanged(itemID int64, listing storedListing) bool {
+	b.lastStoredMu.Lock()
+	defer b.lastStoredMu.Unlock()
+	last, ok := b.lastStored[itemID]
+	return !ok || last != listing
+}
+
+// markStored records listing as the last row written for itemID
+func (b *BazaarPoller) markStored(itemID int64, listing storedListing) {
+	b.lastStoredMu.Lock()
+	defer b.lastStoredMu.Unlock()
+	b.lastStored[itemID] = listing
+}
+
+// forgetListings drops itemID's previous listings after a cycle that didn't
+// observe it (cooldown or failed fetch). Diffing across the gap would count
+// every listing that ended in the meantime, including delistings, as sold.
+func (b *BazaarPoller) forgetListings(itemID int64) {
+	b.prevListingsMu.Lock()
+	defer b.prevListingsMu.Unlock()
+	delete(b.prevListings, itemID)
+}
+
+// recordSales diffs listings against the previous cycle and stores the
+// quantities that disappeared as probable sales
+func (b *BazaarPoller) recordSales(ctx context.Context, itemID int64, listings []services.Weav3rListing, now time.Time) {
+	b.prevListingsMu.Lock()
+	prev := b.prevListings[itemID]
+	b.prevListings[itemID] = listings
+	b.prevListingsMu.Unlock()
+
+	for _, sale := range diffListings(prev, listings) {
+		_, err := b.db.Exec(ctx, `
+			INSERT INTO sold_listings (time, item_id, price, quantity, seller_id)
+			VALUES ($1, $2, $3, $4, $5)
+		`, now, itemID, sale.Price, sale.Quantity, sale.SellerID)
+		if err != nil {
+			log.Warn().Err(err).Int64("item_id", itemID).Msg("Failed to record sold listing")
+		}
+	}
+}
+
 // fetchAndStore retrieves market data from Weav3r.dev and stores it
 // itemID IS the Torn item ID now
 func (b *BazaarPoller) fetchAndStore(ctx context.Context, itemID int64) error {
@@ -255,18 +447,43 @@ func (b *BazaarPoller) fetchAndStore(ctx context.Context, itemID int64) error {
 			}
 		}
 
-		// Insert into bazaar_prices
-		_, err = b.db.Exec(ctx, `
-			INSERT INTO bazaar_prices (time, item_id, price, quantity, seller_id)
-			VALUES ($1, $2, $3, $4, $5)
-		`, now, itemID, minPrice, minQty, sellerID)
-		if err != nil {
-			log.Warn().Err(err).Int64("item_id", itemID).Msg("Failed to insert bazaar price")
+		// Insert into bazaar_prices, unless the cheapest listing is unchanged
+		// since the last stored row (avoids inflating the series and volume).
+		// A stable listing is still stored once the throttle's max gap passes
+		// so the series stays alive.
+		listing := storedListing{Price: minPrice, Quantity: minQty, SellerID: sellerID}
+		changed := b.listingChanged(itemID, listing) && b.throttle.ShouldStore("bazaar", itemID, minPrice, now)
+		if changed || b.throttle.GapExpired("bazaar", itemID, now) {
+			_, err = b.db.Exec(ctx, `
+				INSERT INTO bazaar_prices (time, item_id, price, quantity, seller_id, source)
+				VALUES ($1, $2, $3, $4, $5, $6)
+			`, now, itemID, minPrice, minQty, sellerID, models.PriceSourcePoller)
+			if err != nil {
+				log.Warn().Err(err).Int64("item_id", itemID).Msg("Failed to insert bazaar price")
+			} else {
+				b.markStored(itemID, listing)
+				b.throttle.Stored("bazaar", itemID, minPrice, now)
+			}
 		}
 
-		// Update cache
+		// Infer sales from listings that vanished since the last cycle
+		b.recordSales(ctx, itemID, weav3rData.Listings, now)
+
+		// Store full listing depth for cost calculations
+		if listingsJSON, err := json.Marshal(weav3rData.Listings); err == nil {
+			_, err = b.db.Exec(ctx, `
+				INSERT INTO bazaar_listing_snapshots (item_id, listings, updated_at)
+				VALUES ($1, $2, $3)
+				ON CONFLICT (item_id) DO UPDATE SET listings = EXCLUDED.listings, updated_at = EXCLUDED.updated_at
+			`, itemID, listingsJSON, now)
+			if err != nil {
+				log.Warn().Err(err).Int64("item_id", itemID).Msg("Failed to store bazaar listing snapshot")
+			}
+		}
+
+		// Update cache (always, so last_updated_at keeps the crawler rotation moving)
 		_, err = b.db.Exec(ctx, `
-			UPDATE items SET last_bazaar_price = $1, last_updated_at = $2 WHERE id = $3
+			UPDATE items SET last_bazaar_price = $1, last_bazaar_observed_at = $2, last_updated_at = $2 WHERE id = $3
 		`, minPrice, now, itemID)
 
 		if err != nil {
@@ -308,6 +525,8 @@ func (b *BazaarPoller) fetchAndStore(ctx context.Context, itemID int64) error {
 
 // handleFailure implements smart suspension logic
 func (b *BazaarPoller) handleFailure(itemID int64, err error) {
+	b.forgetListings(itemID)
+
 	b.statesMu.Lock()
 	defer b.statesMu.Unlock()
 
@@ -318,24 +537,36 @@ func (b *BazaarPoller) handleFailure(itemID int64, err error) {
 	}
 
 	state.FailCount++
+	state.SuccessStreak = 0
 
-	// After 3 consecutive failures, put item in cooldown
-	if state.FailCount >= 3 {
-		state.CooldownUntil = time.Now().Add(1 * time.Hour)
+	// After enough consecutive failures, put item in an escalating cooldown.
+	// FailCount is left as is so only a success resets it.
+	if state.FailCount >= failThreshold {
+		state.CooldownCycles++
+		state.CooldownUntil = time.Now().Add(cooldownFor(state.CooldownCycles))
 		log.Warn().
 			Int64("item_id", itemID).
-			Int("fail_count", state.FailCount).
+			Int("cooldown_cycle", state.CooldownCycles).
 			Time("cooldown_until", state.CooldownUntil).
 			Msg("Item put in cooldown due to repeated failures")
 	}
 }
 
-// resetFailure clears failure state on successful fetch
+// resetFailure records a successful fetch. The failure counter is cleared
+// immediately, but the cooldown escalation is only dropped once the item has
+// stayed healthy for recoveryStreak consecutive fetches.
 func (b *BazaarPoller) resetFailure(itemID int64) {
 	b.statesMu.Lock()
 	defer b.statesMu.Unlock()
 
-	if state, exists := b.itemStates[itemID]; exists {
-		state.FailCount = 0
+	state, exists := b.itemStates[itemID]
+	if !exists {
+		return
+	}
+
+	state.FailCount = 0
+	state.SuccessStreak++
+	if state.SuccessStreak >= recoveryStreak {
+		delete(b.itemStates, itemID)
 	}
 }
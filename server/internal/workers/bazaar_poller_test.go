@@ -0,0 +1,34 @@
+package workers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/akagifreeez/torn-market-chart/pkg/tornapi/tornapitest"
+)
+
+func TestFetchItemsSkipsItemsWithoutTicket(t *testing.T) {
+	limiter := tornapitest.NewFakeLimiter(0)
+	b := &BazaarPoller{
+		maxConcurrent: 2,
+		limiter:       limiter,
+		itemStates:    make(map[int64]*ItemState),
+	}
+
+	items := []itemInfo{{ID: 1}, {ID: 2}, {ID: 3}}
+	if got := b.fetchItems(context.Background(), items, "test"); got != 0 {
+		t.Fatalf("fetchItems = %d successes, want 0", got)
+	}
+	if calls := limiter.Calls(); calls != len(items) {
+		t.Fatalf("limiter asked %d times, want %d", calls, len(items))
+	}
+	for _, n := range limiter.KeyCounts {
+		if n != 1 {
+			t.Fatalf("ticket keyCount = %d, want 1", n)
+		}
+	}
+	// A denied ticket is not the item's fault
+	if len(b.itemStates) != 0 {
+		t.Fatalf("denied items recorded as failures: %v", b.itemStates)
+	}
+}
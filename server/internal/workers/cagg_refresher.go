@@ -0,0 +1,86 @@
+package workers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog/log"
+
+	"github.com/akagifreeez/torn-market-chart/internal/config"
+)
+
+// caggWindow is a continuous aggregate and how far back a manual refresh reaches.
+// The windows mirror the start_offset of each view's refresh policy.
+type caggWindow struct {
+	view   string
+	window string // Postgres interval literal
+}
+
+var caggWindows = []caggWindow{
+	{view: "market_prices_1m", window: "1 hour"},
+	{view: "market_prices_1h", window: "1 day"},
+	{view: "market_prices_1d", window: "1 month"},
+	{view: "bazaar_prices_1m", window: "1 hour"},
+	{view: "bazaar_prices_1h", window: "1 day"},
+	{view: "bazaar_prices_1d", window: "1 month"},
+}
+
+// CaggRefresher periodically refreshes the recent window of every continuous
+// aggregate. It complements the TimescaleDB refresh policies on deployments
+// where the background job scheduler falls behind, which would otherwise push
+// more work onto GetHistory's realtime UNION.
+type CaggRefresher struct {
+	db       *pgxpool.Pool
+	interval time.Duration
+}
+
+// NewCaggRefresher creates a new CaggRefresher worker
+func NewCaggRefresher(db *pgxpool.Pool, cfg *config.Config) *CaggRefresher {
+	return &CaggRefresher{
+		db:       db,
+		interval: cfg.CaggRefreshInterval,
+	}
+}
+
+// Start begins the periodic refresh; it returns immediately when disabled
+func (c *CaggRefresher) Start(ctx context.Context) {
+	if c.interval <= 0 {
+		log.Info().Msg("Continuous aggregate refresher disabled")
+		return
+	}
+	log.Info().Dur("interval", c.interval).Msg("Starting Continuous Aggregate Refresher worker")
+
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Info().Msg("Continuous Aggregate Refresher worker stopped")
+			return
+		case <-ticker.C:
+			c.refresh(ctx)
+		}
+	}
+}
+
+func (c *CaggRefresher) refresh(ctx context.Context) {
+	start := time.Now()
+	for _, cw := range caggWindows {
+		// refresh_continuous_aggregate takes "any"-typed window bounds, which
+		// can't be bound as parameters; view and window are fixed above
+		_, err := c.db.Exec(ctx, fmt.Sprintf(
+			"CALL refresh_continuous_aggregate('%s', NOW() - INTERVAL '%s', NOW())",
+			cw.view, cw.window,
+		))
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Warn().Err(err).Str("view", cw.view).Msg("Failed to refresh continuous aggregate")
+		}
+	}
+	log.Debug().Dur("elapsed", time.Since(start)).Msg("Continuous aggregates refreshed")
+}
@@ -2,8 +2,11 @@ package workers
 
 import (
 	"context"
+	"sort"
+	"sync"
 	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/rs/zerolog/log"
 
@@ -11,11 +14,36 @@ import (
 	"github.com/akagifreeez/torn-market-chart/pkg/tornapi"
 )
 
-// GlobalSync handles daily synchronization of the item catalog
+// catalogFetcher loads the Torn item catalog (*tornapi.Client)
+type catalogFetcher interface {
+	FetchItems(ctx context.Context, opts tornapi.CatalogOptions) (map[int64]tornapi.TornItem, error)
+}
+
+// catalogStore is the only database access GlobalSync has (*pgxpool.Pool)
+type catalogStore interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+}
+
+// GlobalSync handles daily synchronization of the item catalog.
+// It only writes catalog metadata and the torn_market_value reference column on
+// items; it must never insert into the market_prices/bazaar_prices hypertables,
+// which hold observed prices only (see global_sync_test.go).
 type GlobalSync struct {
-	db       *pgxpool.Pool
-	client   *tornapi.Client
+	db       catalogStore
+	client   catalogFetcher
 	interval time.Duration
+	catalog  tornapi.CatalogOptions
+
+	// Newly seen items are tracked only above this circulation; existing
+	// items keep their is_tracked flag
+	trackMinCirculation int64
+
+	batchSize  int           // Items upserted per round trip
+	batchPause time.Duration // Pause between batches to spread database load
+
+	hooksMu  sync.Mutex
+	onSync   []func() // Called after each successful sync
+	lastSync time.Time
 }
 
 // NewGlobalSync creates a new GlobalSync worker
@@ -24,9 +52,29 @@ func NewGlobalSync(db *pgxpool.Pool, client *tornapi.Client, cfg *config.Config)
 		db:       db,
 		client:   client,
 		interval: cfg.GlobalSyncInterval,
+		catalog: tornapi.CatalogOptions{
+			ItemIDs: cfg.GlobalSyncItemIDs,
+		},
+		trackMinCirculation: cfg.TrackMinCirculation,
+		batchSize:           max(cfg.GlobalSyncBatchSize, 1),
+		batchPause:          cfg.GlobalSyncBatchPause,
 	}
 }
 
+// OnSync registers fn to run after every successful sync (e.g. cache invalidation)
+func (g *GlobalSync) OnSync(fn func()) {
+	g.hooksMu.Lock()
+	defer g.hooksMu.Unlock()
+	g.onSync = append(g.onSync, fn)
+}
+
+// LastSync returns when the last successful sync finished (zero if none yet)
+func (g *GlobalSync) LastSync() time.Time {
+	g.hooksMu.Lock()
+	defer g.hooksMu.Unlock()
+	return g.lastSync
+}
+
 // Start begins the periodic synchronization
 func (g *GlobalSync) Start(ctx context.Context) {
 	log.Info().Dur("interval", g.interval).Msg("Starting Global Sync worker")
@@ -54,60 +102,44 @@ func (g *GlobalSync) Start(ctx context.Context) {
 
 // sync performs the actual synchronization
 // Note: id column IS the Torn item ID now (no separate torn_id)
+// Torn's market_value goes to torn_market_value only; last_market_price,
+// last_updated_at and the price hypertables are reserved for observed prices.
 func (g *GlobalSync) sync(ctx context.Context) error {
 	log.Info().Msg("Starting item catalog sync...")
 	start := time.Now()
 
-	items, err := g.client.FetchAllItems(ctx)
+	items, err := g.client.FetchItems(ctx, g.catalog)
 	if err != nil {
 		return err
 	}
 
-	// Upsert items into database
+	// Upsert in sorted batches, pausing between them so a full catalog sync
+	// doesn't saturate the database, and stopping promptly on shutdown
+	ids := make([]int64, 0, len(items))
+	for itemID := range items {
+		ids = append(ids, itemID)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
 	updated := 0
 	inserted := 0
 
-	for itemID, item := range items {
-		// Check if item exists (id IS the Torn item ID)
-		var exists bool
-		err := g.db.QueryRow(ctx, "SELECT EXISTS(SELECT 1 FROM items WHERE id = $1)", itemID).Scan(&exists)
-		if err != nil {
-			log.Error().Err(err).Int64("item_id", itemID).Msg("Failed to check item existence")
-			continue
-		}
-
-		if exists {
-			// Update existing item
-			_, err = g.db.Exec(ctx, `
-				UPDATE items SET
-					name = $1,
-					description = $2,
-					type = $3,
-					circulation = $4,
-					last_market_price = CASE WHEN $5::bigint > 0 THEN $5 ELSE last_market_price END,
-					last_updated_at = NOW(),
-					is_tracked = CASE WHEN $4::bigint = 0 THEN false ELSE is_tracked END
-				WHERE id = $6
-			`, item.Name, item.Description, item.Type, item.Circulation, item.MarketValue, itemID)
-
-			if err != nil {
-				log.Error().Err(err).Int64("item_id", itemID).Msg("Failed to update item")
-			} else {
-				updated++
-			}
-		} else {
-			// Insert new item (id = Torn item ID, not auto-increment)
-			_, err = g.db.Exec(ctx, `
-				INSERT INTO items (id, name, description, type, circulation, last_market_price, is_tracked)
-				VALUES ($1, $2, $3, $4, $5, $6, $7)
-			`, itemID, item.Name, item.Description, item.Type, item.Circulation, item.MarketValue, item.Circulation > 0)
-
-			if err != nil {
-				log.Error().Err(err).Int64("item_id", itemID).Msg("Failed to insert item")
-			} else {
-				inserted++
+	for offset := 0; offset < len(ids); offset += g.batchSize {
+		if offset > 0 && g.batchPause > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(g.batchPause):
 			}
 		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		end := min(offset+g.batchSize, len(ids))
+		ins, upd := g.upsertBatch(ctx, ids[offset:end], items)
+		inserted += ins
+		updated += upd
 	}
 
 	elapsed := time.Since(start)
@@ -118,9 +150,76 @@ func (g *GlobalSync) sync(ctx context.Context) error {
 		Dur("elapsed", elapsed).
 		Msg("Item catalog sync completed")
 
+	g.hooksMu.Lock()
+	g.lastSync = time.Now()
+	hooks := append([]func(){}, g.onSync...)
+	g.hooksMu.Unlock()
+	for _, fn := range hooks {
+		fn()
+	}
+
 	return nil
 }
 
+// upsertBatch upserts the given items in one multi-row statement and returns
+// how many were inserted and updated. The statement succeeds or fails as a
+// whole; a failed batch is logged and its items are retried on the next sync.
+func (g *GlobalSync) upsertBatch(ctx context.Context, ids []int64, items map[int64]tornapi.TornItem) (inserted, updated int) {
+	names := make([]string, len(ids))
+	descriptions := make([]string, len(ids))
+	types := make([]string, len(ids))
+	circulations := make([]int64, len(ids))
+	marketValues := make([]int64, len(ids))
+	tracked := make([]bool, len(ids))
+	for i, itemID := range ids {
+		item := items[itemID]
+		names[i] = item.Name
+		descriptions[i] = item.Description
+		types[i] = item.Type
+		circulations[i] = item.Circulation
+		marketValues[i] = item.MarketValue
+		tracked[i] = item.Circulation > g.trackMinCirculation
+	}
+
+	// Insert new items (id = Torn item ID, not auto-increment); existing items
+	// keep is_tracked unless they left circulation. xmax = 0 marks an insert.
+	rows, err := g.db.Query(ctx, `
+		INSERT INTO items (id, name, description, type, circulation, torn_market_value, is_tracked)
+		SELECT * FROM unnest($1::bigint[], $2::text[], $3::text[], $4::text[], $5::bigint[], $6::bigint[], $7::bool[])
+		ON CONFLICT (id) DO UPDATE SET
+			name = EXCLUDED.name,
+			description = EXCLUDED.description,
+			type = EXCLUDED.type,
+			circulation = EXCLUDED.circulation,
+			torn_market_value = EXCLUDED.torn_market_value,
+			is_tracked = CASE WHEN EXCLUDED.circulation = 0 THEN false ELSE items.is_tracked END
+		RETURNING (xmax = 0)
+	`, ids, names, descriptions, types, circulations, marketValues, tracked)
+	if err != nil {
+		log.Error().Err(err).Int64("first_id", ids[0]).Int("count", len(ids)).Msg("Failed to upsert item batch")
+		return 0, 0
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var isInsert bool
+		if err := rows.Scan(&isInsert); err != nil {
+			log.Error().Err(err).Msg("Failed to read item upsert result")
+			continue
+		}
+		if isInsert {
+			inserted++
+		} else {
+			updated++
+		}
+	}
+	if err := rows.Err(); err != nil {
+		log.Error().Err(err).Int64("first_id", ids[0]).Int("count", len(ids)).Msg("Failed to upsert item batch")
+		return 0, 0
+	}
+	return inserted, updated
+}
+
 // RunOnce performs a single sync (useful for testing)
 func (g *GlobalSync) RunOnce(ctx context.Context) error {
 	return g.sync(ctx)
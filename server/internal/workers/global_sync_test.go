@@ -0,0 +1,94 @@
+package workers
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/akagifreeez/torn-market-chart/pkg/tornapi"
+)
+
+// fakeCatalog serves a fixed item catalog
+type fakeCatalog map[int64]tornapi.TornItem
+
+func (f fakeCatalog) FetchItems(ctx context.Context, opts tornapi.CatalogOptions) (map[int64]tornapi.TornItem, error) {
+	return f, nil
+}
+
+// recordingStore records every statement sent and reports each row as inserted
+type recordingStore struct {
+	statements []string
+}
+
+func (s *recordingStore) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	s.statements = append(s.statements, sql)
+	return &insertedRows{left: len(args[0].([]int64))}, nil
+}
+
+// insertedRows yields one (xmax = 0) = true row per upserted item
+type insertedRows struct {
+	left int
+}
+
+func (r *insertedRows) Close()                                       {}
+func (r *insertedRows) Err() error                                   { return nil }
+func (r *insertedRows) CommandTag() pgconn.CommandTag                { return pgconn.CommandTag{} }
+func (r *insertedRows) FieldDescriptions() []pgconn.FieldDescription { return nil }
+func (r *insertedRows) Values() ([]any, error)                       { return []any{true}, nil }
+func (r *insertedRows) RawValues() [][]byte                          { return nil }
+func (r *insertedRows) Conn() *pgx.Conn                              { return nil }
+
+func (r *insertedRows) Next() bool {
+	if r.left == 0 {
+		return false
+	}
+	r.left--
+	return true
+}
+
+func (r *insertedRows) Scan(dest ...any) error {
+	*dest[0].(*bool) = true
+	return nil
+}
+
+var (
+	writeTarget  = regexp.MustCompile(`(?i)\b(?:INSERT\s+INTO|UPDATE|DELETE\s+FROM|COPY)\s+(\w+)`)
+	upsertClause = regexp.MustCompile(`(?i)\bDO\s+UPDATE\s+SET\b`) // Part of an INSERT, not a separate write
+)
+
+func TestGlobalSyncWritesNoPriceRows(t *testing.T) {
+	catalog := fakeCatalog{}
+	for id := int64(1); id <= 25; id++ {
+		catalog[id] = tornapi.TornItem{ID: id, Name: "Item", Type: "Drug", Circulation: 1000, MarketValue: 12345}
+	}
+	store := &recordingStore{}
+	g := &GlobalSync{db: store, client: catalog, batchSize: 10}
+
+	if err := g.RunOnce(context.Background()); err != nil {
+		t.Fatalf("sync failed: %v", err)
+	}
+	if len(store.statements) == 0 {
+		t.Fatal("sync sent no statements")
+	}
+
+	for _, sql := range store.statements {
+		lower := strings.ToLower(sql)
+		if strings.Contains(lower, "market_prices") || strings.Contains(lower, "bazaar_prices") {
+			t.Errorf("sync touched a price hypertable:\n%s", sql)
+		}
+		for _, m := range writeTarget.FindAllStringSubmatch(upsertClause.ReplaceAllString(sql, ""), -1) {
+			if table := strings.ToLower(m[1]); table != "items" {
+				t.Errorf("sync wrote to %q, want only items", table)
+			}
+		}
+		for _, col := range []string{"last_market_price", "last_bazaar_price", "last_updated_at"} {
+			if strings.Contains(lower, col) {
+				t.Errorf("sync set observed-price column %s", col)
+			}
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package workers
+
+import (
+	"github.com/akagifreeez/torn-market-chart/internal/models"
+	"github.com/akagifreeez/torn-market-chart/pkg/tornapi"
+)
+
+// priceTierBrackets are the lower bounds of each listing-size bracket; a
+// bracket runs up to the next bound, the last one is open-ended
+var priceTierBrackets = []int64{1, 10, 100, 1000}
+
+// computePriceTiers groups market listings by stack size and keeps the cheapest
+// price in each bracket, so bulk listings priced differently from singles show
+// up. Brackets with no listings are omitted.
+func computePriceTiers(listings []tornapi.TornMarketV2Listing) []models.PriceTier {
+	tiers := make([]models.PriceTier, len(priceTierBrackets))
+	for i, min := range priceTierBrackets {
+		tiers[i].MinQuantity = min
+		if i+1 < len(priceTierBrackets) {
+			tiers[i].MaxQuantity = priceTierBrackets[i+1] - 1
+		}
+	}
+
+	for _, l := range listings {
+		if l.Quantity <= 0 || l.Price <= 0 {
+			continue
+		}
+		for i := len(tiers) - 1; i >= 0; i-- {
+			if l.Quantity < tiers[i].MinQuantity {
+				continue
+			}
+			if tiers[i].Price == 0 || l.Price < tiers[i].Price {
+				tiers[i].Price = l.Price
+			}
+			tiers[i].Listed += l.Quantity
+			break
+		}
+	}
+
+	result := make([]models.PriceTier, 0, len(tiers))
+	for _, t := range tiers {
+		if t.Price > 0 {
+			result = append(result, t)
+		}
+	}
+	return result
+}
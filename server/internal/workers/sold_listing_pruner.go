@@ -0,0 +1,65 @@
+package workers
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog/log"
+
+	"github.com/akagifreeez/torn-market-chart/internal/config"
+)
+
+// soldListingPruneInterval is how often expired sold_listings rows are deleted
+const soldListingPruneInterval = time.Hour
+
+// SoldListingPruner deletes inferred sales older than the retention period.
+// sold_listings is a plain table, not a hypertable, so it has no TimescaleDB
+// retention policy of its own.
+type SoldListingPruner struct {
+	db        *pgxpool.Pool
+	retention time.Duration
+}
+
+// NewSoldListingPruner creates a new SoldListingPruner worker
+func NewSoldListingPruner(db *pgxpool.Pool, cfg *config.Config) *SoldListingPruner {
+	return &SoldListingPruner{
+		db:        db,
+		retention: cfg.SoldListingsRetention,
+	}
+}
+
+// Start begins the periodic pruning
+func (p *SoldListingPruner) Start(ctx context.Context) {
+	if p.retention <= 0 {
+		log.Info().Msg("Sold listing pruner disabled")
+		return
+	}
+	log.Info().Dur("retention", p.retention).Msg("Starting Sold Listing Pruner worker")
+
+	p.prune(ctx)
+
+	ticker := time.NewTicker(soldListingPruneInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Info().Msg("Sold Listing Pruner worker stopped")
+			return
+		case <-ticker.C:
+			p.prune(ctx)
+		}
+	}
+}
+
+func (p *SoldListingPruner) prune(ctx context.Context) {
+	tag, err := p.db.Exec(ctx, `DELETE FROM sold_listings WHERE time < $1`, time.Now().Add(-p.retention))
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to prune sold listings")
+		return
+	}
+	if n := tag.RowsAffected(); n > 0 {
+		log.Info().Int64("removed", n).Msg("Pruned expired sold listings")
+	}
+}
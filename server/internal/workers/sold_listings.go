@@ -0,0 +1,74 @@
+package workers
+
+import (
+	"github.com/akagifreeez/torn-market-chart/internal/services"
+)
+
+// listingKey identifies a bazaar listing across snapshots. Weav3r has no
+// listing IDs, so seller and price stand in for one.
+type listingKey struct {
+	SellerID int64
+	Price    int64
+}
+
+// inferredSale is a quantity that left a listing between two snapshots
+type inferredSale struct {
+	SellerID int64
+	Price    int64
+	Quantity int64
+}
+
+// diffListings compares two snapshots of an item's bazaar listings and returns
+// the quantity that disappeared, as probable sales. Listings priced above the
+// current snapshot's most expensive entry are ignored: Weav3r returns a limited
+// depth, so those may simply have been pushed out by cheaper listings.
+// Quantity a seller gained in other listings offsets what vanished from theirs,
+// so a seller relisting at a new price is a reprice rather than a sale.
+func diffListings(prev, curr []services.Weav3rListing) []inferredSale {
+	if len(prev) == 0 || len(curr) == 0 {
+		return nil
+	}
+
+	maxPrice := int64(0)
+	remaining := make(map[listingKey]int64, len(curr))
+	for _, l := range curr {
+		remaining[listingKey{l.SellerID, l.Price}] += l.Quantity
+		if l.Price > maxPrice {
+			maxPrice = l.Price
+		}
+	}
+
+	var keys []listingKey
+	before := make(map[listingKey]int64, len(prev))
+	for _, l := range prev {
+		key := listingKey{l.SellerID, l.Price}
+		if _, ok := before[key]; !ok {
+			keys = append(keys, key)
+		}
+		before[key] += l.Quantity
+	}
+
+	moved := make(map[int64]int64) // Per seller, quantity that appeared since prev
+	for key, qty := range remaining {
+		if added := qty - before[key]; added > 0 {
+			moved[key.SellerID] += added
+		}
+	}
+
+	var sales []inferredSale
+	for _, key := range keys {
+		if key.Price > maxPrice {
+			continue
+		}
+		sold := before[key] - remaining[key]
+		if sold <= 0 {
+			continue
+		}
+		repriced := min(sold, moved[key.SellerID])
+		moved[key.SellerID] -= repriced
+		if sold -= repriced; sold > 0 {
+			sales = append(sales, inferredSale{SellerID: key.SellerID, Price: key.Price, Quantity: sold})
+		}
+	}
+	return sales
+}
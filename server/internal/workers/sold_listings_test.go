@@ -0,0 +1,72 @@
+package workers
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/akagifreeez/torn-market-chart/internal/services"
+)
+
+func TestDiffListings(t *testing.T) {
+	listing := func(seller, price, qty int64) services.Weav3rListing {
+		return services.Weav3rListing{SellerID: seller, Price: price, Quantity: qty}
+	}
+	tests := []struct {
+		name string
+		prev []services.Weav3rListing
+		curr []services.Weav3rListing
+		want []inferredSale
+	}{
+		{
+			name: "unchanged",
+			prev: []services.Weav3rListing{listing(1, 100, 5)},
+			curr: []services.Weav3rListing{listing(1, 100, 5)},
+		},
+		{
+			name: "partial quantity drop",
+			prev: []services.Weav3rListing{listing(1, 100, 5), listing(2, 120, 1)},
+			curr: []services.Weav3rListing{listing(1, 100, 2), listing(2, 120, 1)},
+			want: []inferredSale{{SellerID: 1, Price: 100, Quantity: 3}},
+		},
+		{
+			name: "full removal",
+			prev: []services.Weav3rListing{listing(1, 100, 5), listing(2, 120, 1)},
+			curr: []services.Weav3rListing{listing(2, 120, 1)},
+			want: []inferredSale{{SellerID: 1, Price: 100, Quantity: 5}},
+		},
+		{
+			name: "new listing",
+			prev: []services.Weav3rListing{listing(1, 100, 5)},
+			curr: []services.Weav3rListing{listing(1, 100, 5), listing(2, 90, 3)},
+		},
+		{
+			name: "reprice",
+			prev: []services.Weav3rListing{listing(1, 100, 5), listing(2, 120, 1)},
+			curr: []services.Weav3rListing{listing(1, 95, 5), listing(2, 120, 1)},
+		},
+		{
+			name: "reprice after partial sale",
+			prev: []services.Weav3rListing{listing(1, 100, 5), listing(2, 120, 1)},
+			curr: []services.Weav3rListing{listing(1, 95, 3), listing(2, 120, 1)},
+			want: []inferredSale{{SellerID: 1, Price: 100, Quantity: 2}},
+		},
+		{
+			name: "another seller's listing is not a reprice",
+			prev: []services.Weav3rListing{listing(1, 100, 5), listing(2, 120, 1)},
+			curr: []services.Weav3rListing{listing(3, 100, 5), listing(2, 120, 1)},
+			want: []inferredSale{{SellerID: 1, Price: 100, Quantity: 5}},
+		},
+		{
+			name: "pushed past the snapshot depth",
+			prev: []services.Weav3rListing{listing(1, 100, 5), listing(2, 200, 1)},
+			curr: []services.Weav3rListing{listing(1, 100, 5), listing(3, 90, 1)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := diffListings(tt.prev, tt.curr); !slices.Equal(got, tt.want) {
+				t.Fatalf("diffListings = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
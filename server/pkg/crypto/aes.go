@@ -6,25 +6,26 @@ import (
 	"crypto/rand"
 	"encoding/base64"
 	"errors"
+	"fmt"
 	"io"
+	"strings"
 )
 
-// Encrypt encrypts a plain text string using AES-GCM and returns a base64 encoded string.
-// The key should be 32 bytes for AES-256.
-func Encrypt(keyString string, stringToEncrypt string) (string, error) {
-	key := []byte(keyString)
-	plaintext := []byte(stringToEncrypt)
+// CurrentVersion is the ciphertext format written by Encrypt.
+// Ciphertext is stored as "<version>:<base64(nonce|sealed)>" so the format (and
+// later the key or algorithm) can change without breaking existing values.
+const CurrentVersion = "v1"
 
-	if len(key) != 32 {
-		return "", errors.New("key length must be 32 bytes")
-	}
+// legacyVersion identifies values written before versioning existed (bare base64, AES-GCM).
+const legacyVersion = ""
 
-	block, err := aes.NewCipher(key)
-	if err != nil {
-		return "", err
-	}
+// ErrUnsupportedVersion is returned when ciphertext carries an unknown version header
+var ErrUnsupportedVersion = errors.New("unsupported ciphertext version")
 
-	aesGCM, err := cipher.NewGCM(block)
+// Encrypt encrypts a plain text string using AES-GCM and returns a versioned base64 encoded string.
+// The key should be 32 bytes for AES-256.
+func Encrypt(keyString string, stringToEncrypt string) (string, error) {
+	aesGCM, err := newGCM(keyString)
 	if err != nil {
 		return "", err
 	}
@@ -34,28 +35,67 @@ func Encrypt(keyString string, stringToEncrypt string) (string, error) {
 		return "", err
 	}
 
-	ciphertext := aesGCM.Seal(nonce, nonce, plaintext, nil)
-	return base64.StdEncoding.EncodeToString(ciphertext), nil
+	ciphertext := aesGCM.Seal(nonce, nonce, []byte(stringToEncrypt), nil)
+	return CurrentVersion + ":" + base64.StdEncoding.EncodeToString(ciphertext), nil
 }
 
-// Decrypt decrypts a base64 encoded string using AES-GCM.
+// Decrypt decrypts a string produced by Encrypt, dispatching on its version header.
+// Unprefixed legacy values are still accepted.
 func Decrypt(keyString string, encryptedString string) (string, error) {
-	key := []byte(keyString)
-	enc, err := base64.StdEncoding.DecodeString(encryptedString)
-	if err != nil {
-		return "", err
+	version, payload := splitVersion(encryptedString)
+
+	switch version {
+	case CurrentVersion, legacyVersion:
+		// v1 only added the header; the payload format is identical to legacy
+		return decryptGCM(keyString, payload)
+	default:
+		return "", fmt.Errorf("%w: %q", ErrUnsupportedVersion, version)
 	}
+}
+
+// Version returns the version header of a ciphertext ("" for legacy values)
+func Version(encryptedString string) string {
+	version, _ := splitVersion(encryptedString)
+	return version
+}
+
+// NeedsUpgrade reports whether a ciphertext was written by an older format
+// and should be re-encrypted with Encrypt.
+func NeedsUpgrade(encryptedString string) bool {
+	return Version(encryptedString) != CurrentVersion
+}
+
+// splitVersion separates the version header from the payload.
+// Base64 never contains ':', so a missing separator means a legacy value.
+func splitVersion(encryptedString string) (string, string) {
+	version, payload, found := strings.Cut(encryptedString, ":")
+	if !found {
+		return legacyVersion, encryptedString
+	}
+	return version, payload
+}
 
+func newGCM(keyString string) (cipher.AEAD, error) {
+	key := []byte(keyString)
 	if len(key) != 32 {
-		return "", errors.New("key length must be 32 bytes")
+		return nil, errors.New("key length must be 32 bytes")
 	}
 
 	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+func decryptGCM(keyString string, payload string) (string, error) {
+	enc, err := base64.StdEncoding.DecodeString(payload)
 	if err != nil {
 		return "", err
 	}
 
-	aesGCM, err := cipher.NewGCM(block)
+	aesGCM, err := newGCM(keyString)
 	if err != nil {
 		return "", err
 	}
@@ -0,0 +1,414 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// migrations is the ordered list of schema versions. Append new entries; never edit applied ones.
+var migrations = []migration{
+	{Version: 1, Name: "baseline schema", Up: migrateBaseline, NoTx: true},
+	// The system api_keys table was replaced by per-user keys (users.encrypted_api_key).
+	// It used to be dropped on every boot; now it is torn down exactly once.
+	{Version: 2, Name: "drop legacy api_keys table", Up: execAll(
+		`DROP TABLE IF EXISTS api_keys CASCADE;`,
+	)},
+	// Pinned items are a small quick-access set, independent of watchlists and alerts
+	{Version: 3, Name: "add user_pins", Up: execAll(
+		`CREATE TABLE IF NOT EXISTS user_pins (
+			user_id BIGINT REFERENCES users(id),
+			item_id BIGINT REFERENCES items(id),
+			pinned_at TIMESTAMPTZ DEFAULT NOW(),
+			PRIMARY KEY (user_id, item_id)
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_user_pins_user_time ON user_pins(user_id, pinned_at DESC);`,
+	)},
+	{Version: 4, Name: "add user_alerts.alert_on_record", Up: execAll(
+		`ALTER TABLE user_alerts ADD COLUMN IF NOT EXISTS alert_on_record BOOLEAN NOT NULL DEFAULT false;`,
+	)},
+	// Torn's reference market_value, written only by the catalog sync
+	{Version: 5, Name: "add items.torn_market_value", Up: execAll(
+		`ALTER TABLE items ADD COLUMN IF NOT EXISTS torn_market_value BIGINT;`,
+	)},
+	// Per-user log of triggered alerts, with an inbox-style unread flag
+	{Version: 6, Name: "add alert_history", Up: execAll(
+		`CREATE TABLE IF NOT EXISTS alert_history (
+			id BIGSERIAL PRIMARY KEY,
+			user_id BIGINT REFERENCES users(id),
+			item_id BIGINT REFERENCES items(id),
+			price BIGINT NOT NULL,
+			source VARCHAR(20) NOT NULL,
+			reason TEXT NOT NULL,
+			unread BOOLEAN NOT NULL DEFAULT true,
+			created_at TIMESTAMPTZ DEFAULT NOW()
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_alert_history_user_time ON alert_history(user_id, created_at DESC);`,
+		`CREATE INDEX IF NOT EXISTS idx_alert_history_unread ON alert_history(user_id) WHERE unread;`,
+	)},
+	// Private per-user notes and tags on items
+	{Version: 7, Name: "add user_item_notes", Up: execAll(
+		`CREATE TABLE IF NOT EXISTS user_item_notes (
+			user_id BIGINT REFERENCES users(id),
+			item_id BIGINT REFERENCES items(id),
+			note TEXT NOT NULL DEFAULT '',
+			tags TEXT[] NOT NULL DEFAULT '{}',
+			updated_at TIMESTAMPTZ DEFAULT NOW(),
+			PRIMARY KEY (user_id, item_id)
+		);`,
+	)},
+	// Bazaar listings inferred as sold (gone or shrunk between poller cycles)
+	{Version: 8, Name: "add sold_listings", Up: execAll(
+		`CREATE TABLE IF NOT EXISTS sold_listings (
+			id BIGSERIAL PRIMARY KEY,
+			time TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			item_id BIGINT NOT NULL REFERENCES items(id),
+			price BIGINT NOT NULL,
+			quantity BIGINT NOT NULL,
+			seller_id BIGINT
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_sold_listings_item_time ON sold_listings(item_id, time DESC);`,
+	)},
+	{Version: 9, Name: "add user_alerts.alert_change_absolute", Up: execAll(
+		`ALTER TABLE user_alerts ADD COLUMN IF NOT EXISTS alert_change_absolute BIGINT;`,
+	)},
+	{Version: 10, Name: "add external_prices", Up: execAll(
+		`CREATE TABLE IF NOT EXISTS external_prices (
+			time TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			item_id BIGINT NOT NULL REFERENCES items(id),
+			tornexchange_buy_price BIGINT,
+			torn_market_price BIGINT,
+			weav3r_min_bazaar BIGINT
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_external_prices_item_time ON external_prices(item_id, time DESC);`,
+	)},
+	{Version: 11, Name: "add price_tiers", Up: execAll(
+		`CREATE TABLE IF NOT EXISTS price_tiers (
+			item_id BIGINT PRIMARY KEY REFERENCES items(id),
+			tiers JSONB NOT NULL,
+			updated_at TIMESTAMPTZ DEFAULT NOW()
+		);`,
+	)},
+	// Which writer produced a price point: ws, poller, crawler, webhook or external.
+	// Rows written before this migration have no source.
+	{Version: 12, Name: "add price source columns", Up: execAll(
+		`ALTER TABLE market_prices ADD COLUMN IF NOT EXISTS source VARCHAR(16);`,
+		`ALTER TABLE bazaar_prices ADD COLUMN IF NOT EXISTS source VARCHAR(16);`,
+	)},
+	// Continuous aggregates can't gain columns in place: drop them and rebuild
+	// with vwap, then backfill from the raw tables.
+	{Version: 13, Name: "add vwap to continuous aggregates", Up: rebuildContinuousAggregates, NoTx: true},
+	// Per-alert cooldown override in seconds; NULL uses the global ALERT_COOLDOWN
+	{Version: 14, Name: "add user_alerts.cooldown_seconds", Up: execAll(
+		`ALTER TABLE user_alerts ADD COLUMN IF NOT EXISTS cooldown_seconds INTEGER;`,
+	)},
+	// Per-user API key health, written in batches by KeyManager
+	{Version: 15, Name: "add key_usage", Up: execAll(
+		`CREATE TABLE IF NOT EXISTS key_usage (
+			user_id BIGINT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+			usage_count BIGINT NOT NULL DEFAULT 0,
+			error_count BIGINT NOT NULL DEFAULT 0,
+			last_used_at TIMESTAMPTZ,
+			last_error_at TIMESTAMPTZ
+		);`,
+	)},
+	// When each side's price was last observed, even if the throttle skipped
+	// writing a row. Arbitrage freshness uses these so stable items stay listed.
+	{Version: 16, Name: "add items last observed columns", Up: execAll(
+		`ALTER TABLE items ADD COLUMN IF NOT EXISTS last_market_observed_at TIMESTAMPTZ;`,
+		`ALTER TABLE items ADD COLUMN IF NOT EXISTS last_bazaar_observed_at TIMESTAMPTZ;`,
+		`UPDATE items SET
+			last_market_observed_at = (SELECT MAX(time) FROM market_prices WHERE item_id = items.id),
+			last_bazaar_observed_at = (SELECT MAX(time) FROM bazaar_prices WHERE item_id = items.id);`,
+	)},
+	// SoldListingPruner deletes by time alone
+	{Version: 17, Name: "add sold_listings time index", Up: execAll(
+		`CREATE INDEX IF NOT EXISTS idx_sold_listings_time ON sold_listings(time);`,
+	)},
+}
+
+// migrateBaseline is migration v1: the schema as it existed before versioned
+// migrations were introduced. Every statement is idempotent so it can be applied
+// to databases created by the old run-everything-on-boot Migrate.
+func migrateBaseline(ctx context.Context, q Querier) error {
+	statements := []string{
+		// Enable TimescaleDB extension
+		`CREATE EXTENSION IF NOT EXISTS timescaledb CASCADE;`,
+
+		// Items table
+		`CREATE TABLE IF NOT EXISTS items (
+			id BIGINT PRIMARY KEY, -- This IS the Torn item ID
+			name VARCHAR(255) NOT NULL,
+			description TEXT,
+			type VARCHAR(100),
+			circulation BIGINT DEFAULT 0,
+			is_tracked BOOLEAN DEFAULT false,
+			is_watched BOOLEAN DEFAULT false,
+			last_market_price BIGINT DEFAULT 0,
+			last_bazaar_price BIGINT DEFAULT 0,
+			last_updated_at TIMESTAMPTZ DEFAULT NOW(),
+			created_at TIMESTAMPTZ DEFAULT NOW(),
+			alert_price_above BIGINT DEFAULT NULL,
+			alert_price_below BIGINT DEFAULT NULL,
+			alert_change_percent REAL DEFAULT NULL
+		);`,
+
+		// Add alert columns to existing items table (for existing databases)
+		`ALTER TABLE items ADD COLUMN IF NOT EXISTS alert_price_above BIGINT DEFAULT NULL;`,
+		`ALTER TABLE items ADD COLUMN IF NOT EXISTS alert_price_below BIGINT DEFAULT NULL;`,
+		`ALTER TABLE items ADD COLUMN IF NOT EXISTS alert_change_percent REAL DEFAULT NULL;`,
+
+		// Market prices hypertable
+		`CREATE TABLE IF NOT EXISTS market_prices (
+			time TIMESTAMPTZ NOT NULL,
+			item_id BIGINT NOT NULL REFERENCES items(id),
+			price BIGINT NOT NULL,
+			quantity BIGINT DEFAULT 0
+		);`,
+
+		// Bazaar prices hypertable
+		`CREATE TABLE IF NOT EXISTS bazaar_prices (
+			time TIMESTAMPTZ NOT NULL,
+			item_id BIGINT NOT NULL REFERENCES items(id),
+			price BIGINT NOT NULL,
+			quantity BIGINT DEFAULT 0,
+			seller_id BIGINT,
+			listing_id BIGINT
+		);`,
+
+		// Alert states for deduplication
+		`CREATE TABLE IF NOT EXISTS alert_states (
+			id BIGSERIAL PRIMARY KEY,
+			item_id BIGINT NOT NULL REFERENCES items(id),
+			user_id BIGINT NOT NULL,
+			last_price BIGINT DEFAULT 0,
+			last_hash VARCHAR(64),
+			last_triggered_at TIMESTAMPTZ DEFAULT NOW(),
+			UNIQUE(item_id, user_id)
+		);`,
+
+		// API keys table (Recreated for encryption support)
+		`DROP TABLE IF EXISTS api_keys CASCADE;`,
+		`CREATE TABLE IF NOT EXISTS api_keys (
+			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			encrypted_key TEXT NOT NULL,
+			label TEXT,
+			is_active BOOLEAN DEFAULT TRUE,
+			created_at TIMESTAMPTZ DEFAULT NOW(),
+			last_used_at TIMESTAMPTZ,
+			usage_count BIGINT DEFAULT 0,
+			error_count INT DEFAULT 0
+		);`,
+
+		// Users table
+		`CREATE TABLE IF NOT EXISTS users (
+			id BIGINT PRIMARY KEY,           -- Torn User ID
+			name VARCHAR(255) NOT NULL,
+			api_key_hash TEXT NOT NULL,      -- Hashed API key (for quick lookup/auth)
+			encrypted_api_key TEXT,          -- Encrypted API key (for background crawling)
+			created_at TIMESTAMPTZ DEFAULT NOW(),
+			last_login_at TIMESTAMPTZ DEFAULT NOW(),
+			discord_id VARCHAR(255) UNIQUE,
+			discord_username VARCHAR(255),
+			discord_avatar TEXT
+		);`,
+		// Add encrypted_api_key column to existing users table
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS encrypted_api_key TEXT;`,
+		// Add discord columns to existing users table
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS discord_id VARCHAR(255) UNIQUE;`,
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS discord_username VARCHAR(255);`,
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS discord_avatar TEXT;`,
+
+		// User watchlists (replaces item.is_watched for multi-user)
+		`CREATE TABLE IF NOT EXISTS user_watchlists (
+			user_id BIGINT REFERENCES users(id),
+			item_id BIGINT REFERENCES items(id),
+			created_at TIMESTAMPTZ DEFAULT NOW(),
+			PRIMARY KEY (user_id, item_id)
+		);`,
+
+		// User alerts (replaces item.alert_* for multi-user)
+		`CREATE TABLE IF NOT EXISTS user_alerts (
+			id BIGSERIAL PRIMARY KEY,
+			user_id BIGINT REFERENCES users(id),
+			item_id BIGINT REFERENCES items(id),
+			alert_price_above BIGINT,
+			alert_price_below BIGINT,
+			alert_change_percent REAL,
+			created_at TIMESTAMPTZ DEFAULT NOW(),
+			UNIQUE(user_id, item_id)
+		);`,
+
+		// Latest full bazaar listing depth per item (written by the bazaar poller)
+		`CREATE TABLE IF NOT EXISTS bazaar_listing_snapshots (
+			item_id BIGINT PRIMARY KEY REFERENCES items(id),
+			listings JSONB NOT NULL,
+			updated_at TIMESTAMPTZ DEFAULT NOW()
+		);`,
+
+		// Drop system api_keys table as requested
+		`DROP TABLE IF EXISTS api_keys CASCADE;`,
+
+		// Create indexes
+		`CREATE INDEX IF NOT EXISTS idx_items_is_tracked ON items(is_tracked) WHERE is_tracked = true;`,
+		`CREATE INDEX IF NOT EXISTS idx_items_is_watched ON items(is_watched) WHERE is_watched = true;`,
+		`CREATE INDEX IF NOT EXISTS idx_alert_states_item_user ON alert_states(item_id, user_id);`,
+		`CREATE INDEX IF NOT EXISTS idx_user_watchlists_user ON user_watchlists(user_id);`,
+		`CREATE INDEX IF NOT EXISTS idx_users_encrypted_key ON users(encrypted_api_key) WHERE encrypted_api_key IS NOT NULL;`,
+		`CREATE INDEX IF NOT EXISTS idx_market_prices_item_time ON market_prices (item_id, time DESC);`,
+		`CREATE INDEX IF NOT EXISTS idx_bazaar_prices_item_time ON bazaar_prices (item_id, time DESC);`,
+	}
+
+	for _, stmt := range statements {
+		if _, err := q.Exec(ctx, stmt); err != nil {
+			return fmt.Errorf("migration failed: %w\nQuery: %s", err, stmt)
+		}
+	}
+
+	// Create hypertables (TimescaleDB specific)
+	hypertables := []struct {
+		table   string
+		timeCol string
+	}{
+		{"market_prices", "time"},
+		{"bazaar_prices", "time"},
+	}
+
+	for _, ht := range hypertables {
+		query := fmt.Sprintf(`
+			SELECT create_hypertable('%s', '%s', 
+				chunk_time_interval => INTERVAL '1 week',
+				if_not_exists => TRUE
+			);
+		`, ht.table, ht.timeCol)
+		if _, err := q.Exec(ctx, query); err != nil {
+			// Ignore error if hypertable already exists
+			fmt.Printf("Note: %v (may already be a hypertable)\n", err)
+		}
+	}
+
+	return createContinuousAggregates(ctx, q, baselineCandleColumns)
+}
+
+// Hypertables lists the TimescaleDB hypertables holding raw price ticks
+var Hypertables = []string{"market_prices", "bazaar_prices"}
+
+// SetChunkInterval sets chunk_time_interval on all hypertables. TimescaleDB only
+// applies it to chunks created afterwards; existing chunks keep their size.
+func (db *DB) SetChunkInterval(ctx context.Context, interval time.Duration) error {
+	if interval <= 0 {
+		return fmt.Errorf("invalid chunk interval: %s", interval)
+	}
+	for _, table := range Hypertables {
+		if _, err := db.Pool.Exec(ctx,
+			"SELECT set_chunk_time_interval($1::regclass, $2::bigint * INTERVAL '1 microsecond')",
+			table, interval.Microseconds(),
+		); err != nil {
+			return fmt.Errorf("failed to set chunk interval on %s: %w", table, err)
+		}
+	}
+	return nil
+}
+
+// ContinuousAggregateViews lists the TimescaleDB continuous aggregates used for charting
+var ContinuousAggregateViews = []string{
+	"market_prices_1m",
+	"market_prices_1h",
+	"market_prices_1d",
+	"bazaar_prices_1m",
+	"bazaar_prices_1h",
+	"bazaar_prices_1d",
+}
+
+// rebuildContinuousAggregates drops every continuous aggregate (their refresh
+// policies go with them), recreates them from the current definitions and
+// refreshes them over the full history so charts don't lose past buckets.
+func rebuildContinuousAggregates(ctx context.Context, q Querier) error {
+	for _, view := range ContinuousAggregateViews {
+		if _, err := q.Exec(ctx, fmt.Sprintf(`DROP MATERIALIZED VIEW IF EXISTS %s CASCADE;`, view)); err != nil {
+			return fmt.Errorf("drop %s: %w", view, err)
+		}
+	}
+	if err := createContinuousAggregates(ctx, q, candleColumns); err != nil {
+		return err
+	}
+	for _, view := range ContinuousAggregateViews {
+		if _, err := q.Exec(ctx, fmt.Sprintf(`CALL refresh_continuous_aggregate('%s', NULL, NULL);`, view)); err != nil {
+			return fmt.Errorf("refresh %s: %w", view, err)
+		}
+	}
+	return nil
+}
+
+// EnsureContinuousAggregates (re)creates any missing continuous aggregates and their
+// refresh policies. Existing views are left untouched.
+func (db *DB) EnsureContinuousAggregates(ctx context.Context) error {
+	return createContinuousAggregates(ctx, db.Pool, candleColumns)
+}
+
+// baselineCandleColumns are the continuous aggregate columns as of v1. The
+// baseline must keep creating exactly these; later columns come from the
+// migrations that added them.
+const baselineCandleColumns = `
+			first(price, time) AS open,
+			max(price) AS high,
+			min(price) AS low,
+			last(price, time) AS close,
+			avg(price)::BIGINT AS avg_price,
+			avg(quantity)::BIGINT AS volume`
+
+// candleColumns are the current continuous aggregate columns (vwap since v13)
+const candleColumns = baselineCandleColumns + `,
+			COALESCE(sum(price * quantity)::DOUBLE PRECISION / NULLIF(sum(quantity), 0), avg(price)::DOUBLE PRECISION) AS vwap`
+
+// createContinuousAggregates creates the charting aggregates with the given
+// candle columns, and their refresh policies. Must run outside a transaction.
+func createContinuousAggregates(ctx context.Context, q Querier, columns string) error {
+	// Create continuous aggregates for fast charting
+	buckets := []struct {
+		view, interval, table string
+	}{
+		{"market_prices_1m", "1 minute", "market_prices"},
+		{"market_prices_1h", "1 hour", "market_prices"},
+		{"market_prices_1d", "1 day", "market_prices"},
+		{"bazaar_prices_1m", "1 minute", "bazaar_prices"},
+		{"bazaar_prices_1h", "1 hour", "bazaar_prices"},
+		{"bazaar_prices_1d", "1 day", "bazaar_prices"},
+	}
+	aggregates := make([]string, 0, len(buckets))
+	for _, b := range buckets {
+		aggregates = append(aggregates, fmt.Sprintf(`CREATE MATERIALIZED VIEW IF NOT EXISTS %s
+		WITH (timescaledb.continuous) AS
+		SELECT
+			time_bucket('%s', time) AS bucket,
+			item_id,%s
+		FROM %s
+		GROUP BY bucket, item_id
+		WITH NO DATA;`, b.view, b.interval, columns, b.table))
+	}
+
+	for _, agg := range aggregates {
+		if _, err := q.Exec(ctx, agg); err != nil {
+			fmt.Printf("Note: %v (continuous aggregate may already exist)\n", err)
+		}
+	}
+
+	// Add refresh policies
+	policies := []string{
+		"SELECT add_continuous_aggregate_policy('market_prices_1m', start_offset => INTERVAL '1 hour', end_offset => INTERVAL '1 minute', schedule_interval => INTERVAL '1 minute');",
+		"SELECT add_continuous_aggregate_policy('market_prices_1h', start_offset => INTERVAL '1 day', end_offset => INTERVAL '1 hour', schedule_interval => INTERVAL '1 hour');",
+		"SELECT add_continuous_aggregate_policy('market_prices_1d', start_offset => INTERVAL '1 month', end_offset => INTERVAL '1 day', schedule_interval => INTERVAL '1 day');",
+		"SELECT add_continuous_aggregate_policy('bazaar_prices_1m', start_offset => INTERVAL '1 hour', end_offset => INTERVAL '1 minute', schedule_interval => INTERVAL '1 minute');",
+		"SELECT add_continuous_aggregate_policy('bazaar_prices_1h', start_offset => INTERVAL '1 day', end_offset => INTERVAL '1 hour', schedule_interval => INTERVAL '1 hour');",
+		"SELECT add_continuous_aggregate_policy('bazaar_prices_1d', start_offset => INTERVAL '1 month', end_offset => INTERVAL '1 day', schedule_interval => INTERVAL '1 day');",
+	}
+
+	for _, policy := range policies {
+		if _, err := q.Exec(ctx, policy); err != nil {
+			// Ignore error if policy already exists
+			fmt.Printf("Note: %v (policy may already exist)\n", err)
+		}
+	}
+
+	return nil
+}
@@ -3,9 +3,12 @@ package tornapi
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -14,71 +17,219 @@ import (
 
 // Client wraps Torn API calls with key rotation and rate limiting
 type Client struct {
-	httpClient *http.Client
-	keys       []string
-	keyIndex   int
-	mu         sync.Mutex
-	baseURL    string
-	limiter    *RateLimiter
-}
-
-// NewClient creates a new Torn API client
-func NewClient(apiKeys []string, redisURL string) *Client {
-	// Initialize RateLimiter
-	var limiter *RateLimiter
-	if redisURL != "" {
-		l, err := NewRateLimiter(redisURL, 100, "torn_api:rate_limit") // Default 100 req/min
-		if err != nil {
-			log.Error().Err(err).Msg("Failed to initialize RateLimiter, proceeding without limits")
-		} else {
-			limiter = l
-			log.Info().Msg("RateLimiter initialized")
-		}
+	httpClient   *http.Client
+	keys         []string
+	keyIndex     int
+	limitedUntil map[string]time.Time // Keys Torn reported as over their request limit
+	fallback     KeySource            // Used when keys is empty or every key is limited
+	mu           sync.Mutex
+	baseURL      string
+	limiter      Limiter
+}
+
+// KeySource supplies additional API keys, such as the user-contributed pool
+type KeySource interface {
+	GetNextKey() string
+	PoolSize() int
+}
+
+// keyLimitCooldown is how long a key is skipped after Torn reports it over
+// its limit; Torn counts requests per key per minute
+const keyLimitCooldown = time.Minute
+
+// Torn error codes (returned with HTTP 200 in the body's error object)
+const (
+	TornErrIncorrectKey    = 2  // Key is wrong or was deleted
+	TornErrTooManyRequests = 5  // Key is over its per-minute request limit
+	TornErrKeyOwnerJailed  = 10 // Key owner is in federal jail
+	TornErrKeyDisabled     = 13 // Key disabled because its owner was inactive
+)
+
+// tornRetryDelay is the wait before the first retry of a request Torn
+// rejected with code 5; it doubles on each further attempt
+var tornRetryDelay = 2 * time.Second
+
+// maxTornRetries bounds how often a code 5 rejection is retried
+const maxTornRetries = 2
+
+// TornError is the error object Torn returns (with HTTP 200) for a failed call
+type TornError struct {
+	Code    int    `json:"code"`
+	Message string `json:"error"`
+}
+
+func (e *TornError) Error() string {
+	return fmt.Sprintf("torn API error %d: %s", e.Code, e.Message)
+}
+
+// TornErrorCode returns the Torn error code carried by err, or 0 when err is
+// not a Torn error object (e.g. a network failure or non-200 status)
+func TornErrorCode(err error) int {
+	var tornErr *TornError
+	if !errors.As(err, &tornErr) {
+		return 0
+	}
+	return tornErr.Code
+}
+
+// IsKeyRejected reports whether err is Torn refusing the key itself (incorrect
+// key, owner in federal jail, or key disabled for owner inactivity), so the
+// key should be disabled rather than retried
+func IsKeyRejected(err error) bool {
+	switch TornErrorCode(err) {
+	case TornErrIncorrectKey, TornErrKeyOwnerJailed, TornErrKeyDisabled:
+		return true
+	}
+	return false
+}
+
+// IsRateLimited reports whether err is Torn saying the key is over its limit,
+// so callers should back off
+func IsRateLimited(err error) bool {
+	return TornErrorCode(err) == TornErrTooManyRequests
+}
+
+// DefaultTimeout bounds a single Torn API request when no timeout is configured
+const DefaultTimeout = 30 * time.Second
+
+// NewClient creates a new Torn API client whose requests time out after timeout
+// (DefaultTimeout when <= 0).
+// The limiter may be any Limiter implementation; nil disables limiting.
+func NewClient(apiKeys []string, limiter Limiter, timeout time.Duration) *Client {
+	if limiter == nil {
+		limiter = NoopLimiter{}
+	}
+	if timeout <= 0 {
+		timeout = DefaultTimeout
 	}
 
 	return &Client{
 		httpClient: &http.Client{
-			Timeout: 30 * time.Second,
+			Timeout: timeout,
 		},
-		keys:    apiKeys,
-		baseURL: "https://api.torn.com",
-		limiter: limiter,
+		keys:         append([]string(nil), apiKeys...),
+		limitedUntil: make(map[string]time.Time),
+		baseURL:      "https://api.torn.com",
+		limiter:      limiter,
 	}
 }
 
 // UpdateRateLimit updates the rate limiter target
 func (c *Client) UpdateRateLimit(limit int) {
-	if c.limiter != nil {
-		c.limiter.SetLimit(limit)
-	}
+	c.limiter.SetLimit(limit)
 }
 
-// getNextKey rotates to the next available API key
-func (c *Client) getNextKey() string {
+// SetKeys replaces the API keys used for rotation. Safe to call while requests
+// are in flight; rotation restarts from the first new key.
+func (c *Client) SetKeys(apiKeys []string) {
+	keys := make([]string, len(apiKeys))
+	copy(keys, apiKeys)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.keys = keys
+	c.keyIndex = 0
+	c.limitedUntil = make(map[string]time.Time)
+}
+
+// SetKeySource sets a pool to draw keys from when the client's own keys are
+// missing or all rate-limited; nil disables the fallback
+func (c *Client) SetKeySource(src KeySource) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
+	c.fallback = src
+}
 
-	if len(c.keys) == 0 {
-		return ""
+// getNextKey rotates to the next API key, skipping keys Torn recently limited.
+// When none of the client's keys are usable it draws from the fallback pool;
+// without a pool it keeps rotating through the limited keys as before.
+func (c *Client) getNextKey() string {
+	c.mu.Lock()
+	now := time.Now()
+	var limited string
+	for range c.keys {
+		key := c.keys[c.keyIndex]
+		c.keyIndex = (c.keyIndex + 1) % len(c.keys)
+		if now.After(c.limitedUntil[key]) {
+			c.mu.Unlock()
+			return key
+		}
+		if limited == "" {
+			limited = key
+		}
 	}
+	fallback := c.fallback
+	c.mu.Unlock()
 
-	key := c.keys[c.keyIndex]
-	c.keyIndex = (c.keyIndex + 1) % len(c.keys)
-	return key
+	if fallback != nil {
+		if key := fallback.GetNextKey(); key != "" {
+			return key
+		}
+	}
+	return limited
 }
 
-// getKeyCount returns the number of active keys
+// getKeyCount returns the number of keys requests are spread over, counting
+// the fallback pool when the client has no keys of its own
 func (c *Client) getKeyCount() int {
 	c.mu.Lock()
-	defer c.mu.Unlock()
-	return len(c.keys)
+	count := len(c.keys)
+	fallback := c.fallback
+	c.mu.Unlock()
+
+	if count == 0 && fallback != nil {
+		return fallback.PoolSize()
+	}
+	return count
 }
 
-// waitRateLimit blocks until a request is allowed
-func (c *Client) waitRateLimit(ctx context.Context) error {
-	if c.limiter == nil {
+// checkTornError turns a Torn error object into an error. When Torn says the
+// key is over its request limit, the key is benched and the limiter told to
+// back off, both for keyLimitCooldown.
+func (c *Client) checkTornError(ctx context.Context, key string, tornErr *TornError) error {
+	if tornErr == nil {
 		return nil
 	}
+	if tornErr.Code == TornErrTooManyRequests {
+		c.mu.Lock()
+		c.limitedUntil[key] = time.Now().Add(keyLimitCooldown)
+		c.mu.Unlock()
+
+		if bl, ok := c.limiter.(BackoffLimiter); ok {
+			if err := bl.Backoff(ctx, keyLimitCooldown); err != nil {
+				log.Warn().Err(err).Msg("Failed to signal rate limiter backoff")
+			}
+		}
+	}
+	return tornErr
+}
+
+// withTornRetry runs fn, retrying with exponential delay while Torn rejects
+// the request as over its limit (code 5). Other errors are returned at once.
+// Only use it where fn picks a fresh key per attempt; a pinned key is benched
+// after code 5 and retrying it just burns the attempts.
+func (c *Client) withTornRetry(ctx context.Context, fn func() error) error {
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if !IsRateLimited(err) || attempt >= maxTornRetries {
+			return err
+		}
+
+		delay := tornRetryDelay << attempt
+		log.Warn().Int("attempt", attempt+1).Dur("delay", delay).Msg("Torn API rate limited the request, retrying")
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// waitRateLimit blocks until a request is allowed
+func (c *Client) waitRateLimit(ctx context.Context) error {
 	keyCount := c.getKeyCount()
 	if keyCount == 0 {
 		keyCount = 1 // Prevent potential division/logic errors, though getNextKey would fail anyway
@@ -96,11 +247,6 @@ type TornItem struct {
 	MarketValue int64  `json:"market_value"`
 }
 
-// TornItemsResponse represents the response from torn/items endpoint
-type TornItemsResponse struct {
-	Items map[string]TornItem `json:"items"`
-}
-
 // TornMarketListing represents a single market listing
 type TornMarketListing struct {
 	Cost     int64 `json:"cost"`
@@ -126,15 +272,10 @@ type TornMarketV2Section struct {
 
 // UnmarshalJSON handles the case where Torn API returns an empty array [] instead of an object
 func (s *TornMarketV2Section) UnmarshalJSON(data []byte) error {
-	// If it's an array (likely empty), we treat it as an empty section
-	if len(data) > 0 && data[0] == '[' {
-		return nil
-	}
-
 	// Define a type alias to avoid infinite recursion
 	type Alias TornMarketV2Section
 	var a Alias
-	if err := json.Unmarshal(data, &a); err != nil {
+	if err := decodeObjectOrEmpty(data, &a); err != nil {
 		return err
 	}
 	*s = TornMarketV2Section(a)
@@ -146,10 +287,91 @@ type TornMarketResponse struct {
 	// API v2 format
 	ItemMarket *TornMarketV2Section `json:"itemmarket,omitempty"`
 	Bazaar     *TornMarketV2Section `json:"bazaar,omitempty"`
+	Error      *TornError           `json:"error,omitempty"`
+}
+
+// CatalogOptions controls what FetchItems requests from the torn endpoint
+type CatalogOptions struct {
+	// ItemIDs restricts the fetch to a subset of items; empty fetches the full catalog
+	ItemIDs []int64
+}
+
+// catalogPageSize caps how many item IDs go into a single subset request
+const catalogPageSize = 100
+
+// TornItemsResponse represents the response from torn/items endpoint
+type TornItemsResponse struct {
+	Items    TornItemMap `json:"items"`
+	Metadata struct {
+		Links struct {
+			Next string `json:"next"`
+		} `json:"links"`
+	} `json:"_metadata"`
+	Error *TornError `json:"error,omitempty"`
 }
 
 // FetchAllItems retrieves the complete item catalog
 func (c *Client) FetchAllItems(ctx context.Context) (map[int64]TornItem, error) {
+	return c.FetchItems(ctx, CatalogOptions{})
+}
+
+// FetchItems retrieves the item catalog, split into pages of ItemIDs and
+// following the response's next link when Torn paginates
+func (c *Client) FetchItems(ctx context.Context, opts CatalogOptions) (map[int64]TornItem, error) {
+
+	// One page per chunk of IDs, or a single full-catalog page
+	var idPages []string
+	for start := 0; start < len(opts.ItemIDs); start += catalogPageSize {
+		end := min(start+catalogPageSize, len(opts.ItemIDs))
+		ids := make([]string, 0, end-start)
+		for _, id := range opts.ItemIDs[start:end] {
+			ids = append(ids, strconv.FormatInt(id, 10))
+		}
+		idPages = append(idPages, strings.Join(ids, ","))
+	}
+	if len(idPages) == 0 {
+		idPages = []string{""}
+	}
+
+	result := make(map[int64]TornItem)
+	for _, ids := range idPages {
+		url := fmt.Sprintf("%s/torn/%s?selections=items", c.baseURL, ids)
+		seen := make(map[string]bool)
+		for url != "" {
+			// A next link pointing back at a fetched page would loop forever
+			if seen[url] {
+				log.Warn().Str("url", url).Msg("Torn item catalog pagination repeated a page, stopping")
+				break
+			}
+			seen[url] = true
+
+			var response *TornItemsResponse
+			err := c.withTornRetry(ctx, func() error {
+				var err error
+				response, err = c.fetchItemsPage(ctx, url)
+				return err
+			})
+			if err != nil {
+				return nil, err
+			}
+
+			// Convert map keys to int64
+			for idStr, item := range response.Items {
+				var id int64
+				fmt.Sscanf(idStr, "%d", &id)
+				item.ID = id
+				result[id] = item
+			}
+			url = response.Metadata.Links.Next
+		}
+	}
+
+	log.Info().Int("count", len(result)).Msg("Fetched item catalog from Torn API")
+	return result, nil
+}
+
+// fetchItemsPage fetches a single catalog page, adding a key to the URL
+func (c *Client) fetchItemsPage(ctx context.Context, pageURL string) (*TornItemsResponse, error) {
 	if err := c.waitRateLimit(ctx); err != nil {
 		return nil, err
 	}
@@ -159,7 +381,11 @@ func (c *Client) FetchAllItems(ctx context.Context) (map[int64]TornItem, error)
 		return nil, fmt.Errorf("no API keys available")
 	}
 
-	url := fmt.Sprintf("%s/torn/?selections=items&key=%s", c.baseURL, key)
+	sep := "?"
+	if strings.Contains(pageURL, "?") {
+		sep = "&"
+	}
+	url := pageURL + sep + "key=" + key
 
 	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
@@ -186,37 +412,43 @@ func (c *Client) FetchAllItems(ctx context.Context) (map[int64]TornItem, error)
 	if err := json.Unmarshal(body, &response); err != nil {
 		return nil, fmt.Errorf("failed to parse response: %w", err)
 	}
-
-	// Convert map keys to int64
-	result := make(map[int64]TornItem, len(response.Items))
-	for idStr, item := range response.Items {
-		var id int64
-		fmt.Sscanf(idStr, "%d", &id)
-		item.ID = id
-		result[id] = item
+	if err := c.checkTornError(ctx, key, response.Error); err != nil {
+		return nil, err
 	}
-
-	log.Info().Int("count", len(result)).Msg("Fetched item catalog from Torn API")
-	return result, nil
+	return &response, nil
 }
 
-// FetchMarketPrice retrieves the current market price for an item
+// FetchMarketPrice retrieves the current market price for an item. A request
+// Torn rate-limits is retried with the next key.
 func (c *Client) FetchMarketPrice(ctx context.Context, itemID int64) (*TornMarketResponse, error) {
-	key := c.getNextKey()
-	if key == "" {
-		return nil, fmt.Errorf("no API keys available")
-	}
-	return c.FetchMarketPriceWithKey(ctx, itemID, key)
+	var response *TornMarketResponse
+	err := c.withTornRetry(ctx, func() error {
+		key := c.getNextKey()
+		if key == "" {
+			return fmt.Errorf("no API keys available")
+		}
+		var err error
+		response, err = c.fetchMarketPrice(ctx, itemID, key)
+		return err
+	})
+	return response, err
 }
 
-// FetchMarketPriceWithKey retrieves the current market price using a specific key
+// FetchMarketPriceWithKey retrieves the current market price using a specific
+// key. A rate-limited (code 5) response is returned as is rather than
+// retried: the key is benched and would only be rejected again.
 func (c *Client) FetchMarketPriceWithKey(ctx context.Context, itemID int64, key string) (*TornMarketResponse, error) {
+	return c.fetchMarketPrice(ctx, itemID, key)
+}
+
+// fetchMarketPrice makes a single market request with the given key
+func (c *Client) fetchMarketPrice(ctx context.Context, itemID int64, key string) (*TornMarketResponse, error) {
 	if err := c.waitRateLimit(ctx); err != nil {
 		return nil, err
 	}
 
 	// API v2 is required for itemmarket and bazaar selections
-	url := fmt.Sprintf("https://api.torn.com/v2/market/%d?selections=itemmarket,bazaar&key=%s", itemID, key)
+	url := fmt.Sprintf("%s/v2/market/%d?selections=itemmarket,bazaar&key=%s", c.baseURL, itemID, key)
 
 	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
@@ -242,6 +474,9 @@ func (c *Client) FetchMarketPriceWithKey(ctx context.Context, itemID int64, key
 	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
 		return nil, fmt.Errorf("failed to parse response: %w", err)
 	}
+	if err := c.checkTornError(ctx, key, response.Error); err != nil {
+		return nil, err
+	}
 
 	return &response, nil
 }
@@ -257,11 +492,32 @@ type TornInventoryItem struct {
 
 // TornInventoryResponse represents the response from user/inventory endpoint
 type TornInventoryResponse struct {
-	Inventory map[string]TornInventoryItem `json:"inventory"`
+	Inventory TornInventoryMap `json:"inventory"`
+	Error     *TornError       `json:"error,omitempty"`
+}
+
+// TornItemMap is the items object keyed by item ID, tolerating Torn's [] for empty
+type TornItemMap map[string]TornItem
+
+func (m *TornItemMap) UnmarshalJSON(data []byte) error {
+	return decodeObjectOrEmpty(data, (*map[string]TornItem)(m))
 }
 
-// FetchInventoryWithKey retrieves the user's inventory using a specific key
+// TornInventoryMap is the inventory object keyed by item ID, tolerating Torn's [] for empty
+type TornInventoryMap map[string]TornInventoryItem
+
+func (m *TornInventoryMap) UnmarshalJSON(data []byte) error {
+	return decodeObjectOrEmpty(data, (*map[string]TornInventoryItem)(m))
+}
+
+// FetchInventoryWithKey retrieves the user's inventory using a specific key.
+// Like FetchMarketPriceWithKey, a rate-limited response is not retried.
 func (c *Client) FetchInventoryWithKey(ctx context.Context, key string) ([]TornInventoryItem, error) {
+	return c.fetchInventory(ctx, key)
+}
+
+// fetchInventory makes a single inventory request with the given key
+func (c *Client) fetchInventory(ctx context.Context, key string) ([]TornInventoryItem, error) {
 	if err := c.waitRateLimit(ctx); err != nil {
 		return nil, err
 	}
@@ -287,37 +543,17 @@ func (c *Client) FetchInventoryWithKey(ctx context.Context, key string) ([]TornI
 	// Debug logging
 	// log.Debug().Str("body", string(body)).Msg("Inventory response") // Uncomment if needed, but might be huge
 
-	// Helper struct to handle potential empty array response for inventory
-	type rawResponse struct {
-		Inventory json.RawMessage `json:"inventory"`
-	}
-	var raw rawResponse
-	if err := json.Unmarshal(body, &raw); err != nil {
-		log.Error().Str("body", string(body)).Err(err).Msg("Failed to parse raw inventory response")
-		return nil, fmt.Errorf("failed to parse response structure: %w", err)
-	}
-
-	// Check if inventory is empty (array []) or map
-	if len(raw.Inventory) > 0 && raw.Inventory[0] == '[' {
-		return []TornInventoryItem{}, nil
-	}
-
-	// Check if inventory is a string (e.g. error message)
-	if len(raw.Inventory) > 0 && raw.Inventory[0] == '"' {
-		var errorMsg string
-		if err := json.Unmarshal(raw.Inventory, &errorMsg); err == nil {
-			return nil, fmt.Errorf("inventory API returned message: %s", errorMsg)
-		}
+	var response TornInventoryResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		log.Error().Str("body", string(body)).Err(err).Msg("Failed to parse inventory response")
+		return nil, fmt.Errorf("failed to parse inventory: %w", err)
 	}
-
-	var invMap map[string]TornInventoryItem
-	if err := json.Unmarshal(raw.Inventory, &invMap); err != nil {
-		log.Error().Str("raw_inventory", string(raw.Inventory)).Err(err).Msg("Failed to parse inventory map")
-		return nil, fmt.Errorf("failed to parse inventory map: %w", err)
+	if err := c.checkTornError(ctx, key, response.Error); err != nil {
+		return nil, err
 	}
 
-	var items []TornInventoryItem
-	for _, item := range invMap {
+	items := make([]TornInventoryItem, 0, len(response.Inventory))
+	for _, item := range response.Inventory {
 		items = append(items, item)
 	}
 
@@ -0,0 +1,191 @@
+package tornapi_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/akagifreeez/torn-market-chart/pkg/tornapi"
+	"github.com/akagifreeez/torn-market-chart/pkg/tornapi/tornapitest"
+)
+
+// tornServer answers the nth request (0-based) with body(n) and records the
+// key each request was made with
+type tornServer struct {
+	*httptest.Server
+	mu   sync.Mutex
+	keys []string
+}
+
+func newTornServer(t *testing.T, body func(n int) string) *tornServer {
+	t.Helper()
+	s := &tornServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		n := len(s.keys)
+		s.keys = append(s.keys, r.URL.Query().Get("key"))
+		s.mu.Unlock()
+		fmt.Fprint(w, body(n))
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *tornServer) requestKeys() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.keys...)
+}
+
+func tornErrorBody(code int) string {
+	return fmt.Sprintf(`{"error":{"code":%d,"error":"error %d"}}`, code, code)
+}
+
+// newTestClient returns a client with keys a and b talking to srv, with
+// retries sped up for the test
+func newTestClient(t *testing.T, srv *tornServer, limiter *tornapitest.FakeLimiter) *tornapi.Client {
+	t.Helper()
+	t.Cleanup(tornapi.SetRetryDelay(time.Millisecond))
+	c := tornapi.NewClient([]string{"a", "b"}, limiter, time.Second)
+	c.SetBaseURL(srv.URL)
+	return c
+}
+
+func TestFetchMarketPriceRetriesRateLimited(t *testing.T) {
+	srv := newTornServer(t, func(n int) string {
+		if n == 0 {
+			return tornErrorBody(tornapi.TornErrTooManyRequests)
+		}
+		return `{}`
+	})
+	limiter := tornapitest.NewFakeLimiter(-1)
+	c := newTestClient(t, srv, limiter)
+
+	if _, err := c.FetchMarketPrice(context.Background(), 1); err != nil {
+		t.Fatalf("FetchMarketPrice: %v", err)
+	}
+	// The limited key is benched, so the retry rotates to the next one
+	if got, want := srv.requestKeys(), []string{"a", "b"}; !slices.Equal(got, want) {
+		t.Fatalf("request keys = %v, want %v", got, want)
+	}
+	if got, want := limiter.BackoffCalls(), []time.Duration{tornapi.KeyLimitCooldown}; !slices.Equal(got, want) {
+		t.Fatalf("backoffs = %v, want %v", got, want)
+	}
+}
+
+func TestFetchMarketPriceGivesUpAfterMaxRetries(t *testing.T) {
+	srv := newTornServer(t, func(n int) string {
+		return tornErrorBody(tornapi.TornErrTooManyRequests)
+	})
+	limiter := tornapitest.NewFakeLimiter(-1)
+	c := newTestClient(t, srv, limiter)
+
+	_, err := c.FetchMarketPrice(context.Background(), 1)
+	if !tornapi.IsRateLimited(err) {
+		t.Fatalf("err = %v, want a rate limit error", err)
+	}
+	attempts := tornapi.MaxTornRetries + 1
+	if got := len(srv.requestKeys()); got != attempts {
+		t.Fatalf("requests = %d, want %d", got, attempts)
+	}
+	want := slices.Repeat([]time.Duration{tornapi.KeyLimitCooldown}, attempts)
+	if got := limiter.BackoffCalls(); !slices.Equal(got, want) {
+		t.Fatalf("backoffs = %v, want %v", got, want)
+	}
+}
+
+func TestFetchItemsRetriesRateLimited(t *testing.T) {
+	srv := newTornServer(t, func(n int) string {
+		if n == 0 {
+			return tornErrorBody(tornapi.TornErrTooManyRequests)
+		}
+		return `{"items":{"1":{"id":1,"name":"Hammer"}}}`
+	})
+	limiter := tornapitest.NewFakeLimiter(-1)
+	c := newTestClient(t, srv, limiter)
+
+	items, err := c.FetchAllItems(context.Background())
+	if err != nil {
+		t.Fatalf("FetchAllItems: %v", err)
+	}
+	if items[1].Name != "Hammer" {
+		t.Fatalf("items = %v, want item 1 Hammer", items)
+	}
+	if got := len(srv.requestKeys()); got != 2 {
+		t.Fatalf("requests = %d, want 2", got)
+	}
+	if got, want := limiter.BackoffCalls(), []time.Duration{tornapi.KeyLimitCooldown}; !slices.Equal(got, want) {
+		t.Fatalf("backoffs = %v, want %v", got, want)
+	}
+}
+
+func TestKeyRejectionNotRetried(t *testing.T) {
+	for _, code := range []int{tornapi.TornErrIncorrectKey, tornapi.TornErrKeyOwnerJailed, tornapi.TornErrKeyDisabled} {
+		t.Run(fmt.Sprint(code), func(t *testing.T) {
+			srv := newTornServer(t, func(n int) string {
+				return tornErrorBody(code)
+			})
+			limiter := tornapitest.NewFakeLimiter(-1)
+			c := newTestClient(t, srv, limiter)
+
+			_, err := c.FetchMarketPrice(context.Background(), 1)
+			if !tornapi.IsKeyRejected(err) || tornapi.TornErrorCode(err) != code {
+				t.Fatalf("err = %v, want key rejection with code %d", err, code)
+			}
+			if got := len(srv.requestKeys()); got != 1 {
+				t.Fatalf("requests = %d, want 1", got)
+			}
+			if got := limiter.BackoffCalls(); len(got) != 0 {
+				t.Fatalf("backoffs = %v, want none", got)
+			}
+		})
+	}
+}
+
+func TestFetchMarketPriceWithKeyNotRetried(t *testing.T) {
+	srv := newTornServer(t, func(n int) string {
+		return tornErrorBody(tornapi.TornErrTooManyRequests)
+	})
+	limiter := tornapitest.NewFakeLimiter(-1)
+	c := newTestClient(t, srv, limiter)
+
+	_, err := c.FetchMarketPriceWithKey(context.Background(), 1, "pinned")
+	if !tornapi.IsRateLimited(err) {
+		t.Fatalf("err = %v, want a rate limit error", err)
+	}
+	if got, want := srv.requestKeys(), []string{"pinned"}; !slices.Equal(got, want) {
+		t.Fatalf("request keys = %v, want %v", got, want)
+	}
+	if got, want := limiter.BackoffCalls(), []time.Duration{tornapi.KeyLimitCooldown}; !slices.Equal(got, want) {
+		t.Fatalf("backoffs = %v, want %v", got, want)
+	}
+}
+
+func TestFetchItemsStopsOnRepeatedPage(t *testing.T) {
+	var srv *tornServer
+	srv = newTornServer(t, func(n int) string {
+		// Page 2 links back to page 1
+		next := srv.URL + "/torn/?selections=items&page=2"
+		if n > 0 {
+			next = srv.URL + "/torn/?selections=items"
+		}
+		return fmt.Sprintf(`{"items":{"%d":{"id":%d}},"_metadata":{"links":{"next":%q}}}`, n+1, n+1, next)
+	})
+	c := newTestClient(t, srv, tornapitest.NewFakeLimiter(-1))
+
+	items, err := c.FetchAllItems(context.Background())
+	if err != nil {
+		t.Fatalf("FetchAllItems: %v", err)
+	}
+	if got := len(srv.requestKeys()); got != 2 {
+		t.Fatalf("requests = %d, want 2", got)
+	}
+	if len(items) != 2 {
+		t.Fatalf("items = %v, want 2 items", items)
+	}
+}
@@ -0,0 +1,45 @@
+package tornapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// TornMessageError is returned when Torn sends a bare message string where an
+// object was expected (e.g. an inventory that is unavailable)
+type TornMessageError struct {
+	Message string
+}
+
+func (e *TornMessageError) Error() string {
+	return fmt.Sprintf("torn API returned message: %s", e.Message)
+}
+
+// decodeObjectOrEmpty decodes a Torn field that is normally a JSON object.
+// Torn encodes empty objects as [] and sometimes substitutes a message string;
+// [] and null leave v untouched, a string becomes a *TornMessageError.
+func decodeObjectOrEmpty(data []byte, v any) error {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 || bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+	switch data[0] {
+	case '[':
+		var arr []json.RawMessage
+		if err := json.Unmarshal(data, &arr); err != nil {
+			return err
+		}
+		if len(arr) > 0 {
+			return fmt.Errorf("expected object or empty array, got array of %d elements", len(arr))
+		}
+		return nil
+	case '"':
+		var msg string
+		if err := json.Unmarshal(data, &msg); err != nil {
+			return err
+		}
+		return &TornMessageError{Message: msg}
+	}
+	return json.Unmarshal(data, v)
+}
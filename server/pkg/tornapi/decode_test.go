@@ -0,0 +1,95 @@
+package tornapi
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestDecodeObjectOrEmpty(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		want    map[string]int
+		wantMsg string // Expected TornMessageError message
+		wantErr bool   // Expected any other error
+	}{
+		{name: "object", body: `{"a":1,"b":2}`, want: map[string]int{"a": 1, "b": 2}},
+		{name: "empty object", body: `{}`, want: map[string]int{}},
+		{name: "empty array", body: `[]`, want: nil},
+		{name: "empty array with whitespace", body: " [ ] \n", want: nil},
+		{name: "null", body: `null`, want: nil},
+		{name: "blank", body: ``, want: nil},
+		{name: "error string", body: `"The inventory selection is no longer available"`, wantMsg: "The inventory selection is no longer available"},
+		{name: "non-empty array", body: `[1,2]`, wantErr: true},
+		{name: "malformed array", body: `[`, wantErr: true},
+		{name: "wrong value type", body: `{"a":"x"}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got map[string]int
+			err := decodeObjectOrEmpty([]byte(tt.body), &got)
+
+			var msgErr *TornMessageError
+			switch {
+			case tt.wantMsg != "":
+				if !errors.As(err, &msgErr) {
+					t.Fatalf("err = %v, want *TornMessageError", err)
+				}
+				if msgErr.Message != tt.wantMsg {
+					t.Errorf("message = %q, want %q", msgErr.Message, tt.wantMsg)
+				}
+				return
+			case tt.wantErr:
+				if err == nil || errors.As(err, &msgErr) {
+					t.Fatalf("err = %v, want a decode error", err)
+				}
+				return
+			case err != nil:
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if (got == nil) != (tt.want == nil) || len(got) != len(tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			for k, v := range tt.want {
+				if got[k] != v {
+					t.Errorf("got[%q] = %d, want %d", k, got[k], v)
+				}
+			}
+		})
+	}
+}
+
+func TestInventoryMapUnmarshal(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		wantLen int
+		wantMsg bool
+	}{
+		{"populated", `{"inventory":{"206":{"ID":206,"name":"Xanax","type":"Drug","quantity":3,"market_price":800000}}}`, 1, false},
+		{"empty array", `{"inventory":[]}`, 0, false},
+		{"error string", `{"inventory":"The inventory selection is no longer available"}`, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var resp TornInventoryResponse
+			err := json.Unmarshal([]byte(tt.body), &resp)
+
+			var msgErr *TornMessageError
+			if tt.wantMsg {
+				if !errors.As(err, &msgErr) {
+					t.Fatalf("err = %v, want *TornMessageError", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(resp.Inventory) != tt.wantLen {
+				t.Errorf("len = %d, want %d", len(resp.Inventory), tt.wantLen)
+			}
+		})
+	}
+}
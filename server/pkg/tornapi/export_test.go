@@ -0,0 +1,21 @@
+package tornapi
+
+import "time"
+
+// Internals exposed to the external tornapi_test package
+const (
+	KeyLimitCooldown = keyLimitCooldown
+	MaxTornRetries   = maxTornRetries
+)
+
+// SetBaseURL points c at a test server
+func (c *Client) SetBaseURL(url string) {
+	c.baseURL = url
+}
+
+// SetRetryDelay overrides tornRetryDelay and returns a func restoring it
+func SetRetryDelay(d time.Duration) func() {
+	old := tornRetryDelay
+	tornRetryDelay = d
+	return func() { tornRetryDelay = old }
+}
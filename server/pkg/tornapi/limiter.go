@@ -9,6 +9,33 @@ import (
 	"github.com/rs/zerolog/log"
 )
 
+// Limiter is the contract shared by every rate limiter implementation
+// (Redis-backed, in-process, no-op and the test fake). Code that needs rate
+// limiting should depend on this interface rather than a concrete limiter.
+type Limiter interface {
+	WaitForTicket(ctx context.Context, keyCount int) error
+	SetLimit(limit int)
+	Close() error
+}
+
+var (
+	_ Limiter = (*RateLimiter)(nil)
+	_ Limiter = (*LocalRateLimiter)(nil)
+	_ Limiter = NoopLimiter{}
+)
+
+// incrWithExpire increments the window counter and sets its TTL atomically.
+// A separate INCR + EXPIRE could leave a key without TTL if the process died in
+// between, blocking that window forever. The TTL is (re)applied whenever the key
+// has none, which also heals counters left behind by older versions.
+var incrWithExpire = redis.NewScript(`
+local count = redis.call("INCR", KEYS[1])
+if redis.call("PTTL", KEYS[1]) < 0 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[1])
+end
+return count
+`)
+
 // RateLimiter enforces API rate limits using Redis
 type RateLimiter struct {
 	client  *redis.Client
@@ -17,8 +44,16 @@ type RateLimiter struct {
 	baseKey string
 }
 
-// NewRateLimiter creates a new RateLimiter
-func NewRateLimiter(redisURL string, limit int, baseKey string) (*RateLimiter, error) {
+// DefaultWindow is the fixed window used by Torn's per-minute limits
+const DefaultWindow = 60 * time.Second
+
+// NewRateLimiter creates a new RateLimiter allowing limit requests per window.
+// A non-positive window falls back to DefaultWindow.
+func NewRateLimiter(redisURL string, limit int, window time.Duration, baseKey string) (*RateLimiter, error) {
+	if window <= 0 {
+		window = DefaultWindow
+	}
+
 	opts, err := redis.ParseURL(redisURL)
 	if err != nil {
 		return nil, fmt.Errorf("invalid redis url: %w", err)
@@ -36,11 +71,35 @@ func NewRateLimiter(redisURL string, limit int, baseKey string) (*RateLimiter, e
 	return &RateLimiter{
 		client:  client,
 		limit:   limit,
-		window:  60 * time.Second, // 1 minute fixed window
+		window:  window,
 		baseKey: baseKey,
 	}, nil
 }
 
+// NewLimiterWithFallback creates a Redis-backed limiter, falling back to an in-process
+// LocalRateLimiter when Redis is unreachable. If requireRedis is set the fallback is
+// disabled and the connection error is returned instead, so production deployments
+// never run with a per-process budget that could exceed Torn's limits.
+func NewLimiterWithFallback(redisURL string, limit int, window time.Duration, baseKey string, requireRedis bool) (Limiter, error) {
+	var redisErr error
+	if redisURL != "" {
+		l, err := NewRateLimiter(redisURL, limit, window, baseKey)
+		if err == nil {
+			return l, nil
+		}
+		redisErr = err
+	} else {
+		redisErr = fmt.Errorf("redis url is not configured")
+	}
+
+	if requireRedis {
+		return nil, fmt.Errorf("redis rate limiter required for %s: %w", baseKey, redisErr)
+	}
+
+	log.Warn().Err(redisErr).Str("key", baseKey).Msg("Redis unavailable, using in-process rate limiter")
+	return NewLocalRateLimiter(limit, window), nil
+}
+
 // SetLimit updates the rate limit dynamically
 func (r *RateLimiter) SetLimit(limit int) {
 	r.limit = limit
@@ -57,11 +116,14 @@ func (r *RateLimiter) WaitForTicket(ctx context.Context, keyCount int) error {
 	if effectiveLimit <= 0 {
 		effectiveLimit = 50 // Safe fallback
 	}
+	if r.backingOff(ctx) {
+		effectiveLimit = max(effectiveLimit/backoffDivisor, 1)
+	}
 
 	// Simple Fixed Window Counter
-	// Key: torn_api:rate_limit:<minute_timestamp>
+	// Key: torn_api:rate_limit:<window_index> (window index = unix time / window)
 	now := time.Now()
-	minuteKey := fmt.Sprintf("%s:%d", r.baseKey, now.Unix()/60)
+	counterKey := r.windowKey(now)
 
 	for {
 		select {
@@ -70,9 +132,8 @@ func (r *RateLimiter) WaitForTicket(ctx context.Context, keyCount int) error {
 		default:
 		}
 
-		// Increment counter
-		// We use Lua script or transaction for atomicity if needed, but simple INCR is fine for this scale
-		count, err := r.client.Incr(ctx, minuteKey).Result()
+		// Increment counter and ensure it has a TTL in a single atomic step
+		count, err := incrWithExpire.Run(ctx, r.client, []string{counterKey}, (2 * r.window).Milliseconds()).Int64()
 		if err != nil {
 			log.Error().Err(err).Msg("RateLimiter: Redis error")
 			// Fail open or closed? Let's sleep and retry to avoid flooding if Redis is down
@@ -80,11 +141,6 @@ func (r *RateLimiter) WaitForTicket(ctx context.Context, keyCount int) error {
 			continue
 		}
 
-		// Set expiry on first increment
-		if count == 1 {
-			r.client.Expire(ctx, minuteKey, 2*time.Minute)
-		}
-
 		if count <= int64(effectiveLimit) {
 			// Allowed
 			return nil
@@ -96,9 +152,9 @@ func (r *RateLimiter) WaitForTicket(ctx context.Context, keyCount int) error {
 			Int("limit", effectiveLimit).
 			Msg("Rate limit exceeded, waiting...")
 
-		// Wait until next minute + small jitter
-		nextMinute := now.Truncate(time.Minute).Add(time.Minute).Add(100 * time.Millisecond)
-		waitDuration := time.Until(nextMinute)
+		// Wait until next window + small jitter
+		nextWindow := now.Truncate(r.window).Add(r.window).Add(100 * time.Millisecond)
+		waitDuration := time.Until(nextWindow)
 		if waitDuration < 0 {
 			waitDuration = 1 * time.Second
 		}
@@ -109,13 +165,18 @@ func (r *RateLimiter) WaitForTicket(ctx context.Context, keyCount int) error {
 			timer.Stop()
 			return ctx.Err()
 		case <-timer.C:
-			// Retry loop with new minute key
+			// Retry loop with new window key
 			now = time.Now()
-			minuteKey = fmt.Sprintf("%s:%d", r.baseKey, now.Unix()/60)
+			counterKey = r.windowKey(now)
 		}
 	}
 }
 
+// windowKey returns the counter key for the fixed window containing t
+func (r *RateLimiter) windowKey(t time.Time) string {
+	return fmt.Sprintf("%s:%d", r.baseKey, t.UnixNano()/int64(r.window))
+}
+
 // Close closes the Redis client
 func (r *RateLimiter) Close() error {
 	return r.client.Close()
@@ -0,0 +1,47 @@
+package tornapi
+
+import (
+	"context"
+	"time"
+)
+
+// backoffDivisor is how much the effective limit is cut while backing off
+const backoffDivisor = 2
+
+// BackoffLimiter is implemented by limiters that can temporarily shrink their
+// budget when Torn itself rejects requests (error code 5), rather than relying
+// only on the pre-emptive count
+type BackoffLimiter interface {
+	Limiter
+	Backoff(ctx context.Context, d time.Duration) error
+}
+
+var (
+	_ BackoffLimiter = (*RateLimiter)(nil)
+	_ BackoffLimiter = (*LocalRateLimiter)(nil)
+)
+
+// backoffKey marks the shared budget as reduced for as long as it exists
+func (r *RateLimiter) backoffKey() string {
+	return r.baseKey + ":backoff"
+}
+
+// Backoff halves the effective limit for d, across every process sharing the
+// base key. Repeated calls extend the period.
+func (r *RateLimiter) Backoff(ctx context.Context, d time.Duration) error {
+	return r.client.Set(ctx, r.backoffKey(), 1, d).Err()
+}
+
+// backingOff reports whether a backoff is in effect; Redis errors count as no
+func (r *RateLimiter) backingOff(ctx context.Context) bool {
+	n, err := r.client.Exists(ctx, r.backoffKey()).Result()
+	return err == nil && n > 0
+}
+
+// Backoff halves the effective limit for d
+func (l *LocalRateLimiter) Backoff(ctx context.Context, d time.Duration) error {
+	l.mu.Lock()
+	l.backoffUntil = time.Now().Add(d)
+	l.mu.Unlock()
+	return nil
+}
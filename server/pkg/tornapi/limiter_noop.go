@@ -0,0 +1,17 @@
+package tornapi
+
+import "context"
+
+// NoopLimiter never blocks. It is used when rate limiting is intentionally disabled.
+type NoopLimiter struct{}
+
+// WaitForTicket always allows the request (unless the context is already done)
+func (NoopLimiter) WaitForTicket(ctx context.Context, keyCount int) error {
+	return ctx.Err()
+}
+
+// SetLimit is a no-op
+func (NoopLimiter) SetLimit(limit int) {}
+
+// Close is a no-op
+func (NoopLimiter) Close() error { return nil }
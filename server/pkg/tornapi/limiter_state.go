@@ -0,0 +1,78 @@
+package tornapi
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/time/rate"
+)
+
+// LimiterState is a snapshot of a limiter's current window, for diagnostics
+type LimiterState struct {
+	Backend string        `json:"backend"` // "redis" or "local"
+	BaseKey string        `json:"base_key,omitempty"`
+	Limit   int           `json:"limit"` // Configured base limit per key per window
+	Window  time.Duration `json:"window_ns"`
+	Count   int64         `json:"count"` // Requests counted in the current window
+}
+
+// InspectableLimiter is implemented by limiters that can report and reset
+// their current window, so operators can observe throttling without redis-cli
+type InspectableLimiter interface {
+	Limiter
+	State(ctx context.Context) (LimiterState, error)
+	Reset(ctx context.Context) error
+}
+
+var (
+	_ InspectableLimiter = (*RateLimiter)(nil)
+	_ InspectableLimiter = (*LocalRateLimiter)(nil)
+)
+
+// State reads the counter for the current window
+func (r *RateLimiter) State(ctx context.Context) (LimiterState, error) {
+	count, err := r.client.Get(ctx, r.windowKey(time.Now())).Int64()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return LimiterState{}, err
+	}
+	return LimiterState{
+		Backend: "redis",
+		BaseKey: r.baseKey,
+		Limit:   r.limit,
+		Window:  r.window,
+		Count:   count,
+	}, nil
+}
+
+// Reset deletes the current window's counter, shared by every process using
+// the same base key
+func (r *RateLimiter) Reset(ctx context.Context) error {
+	return r.client.Del(ctx, r.windowKey(time.Now())).Err()
+}
+
+// State estimates the current window's usage from the token bucket
+func (l *LocalRateLimiter) State(ctx context.Context) (LimiterState, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var used int64
+	if l.current > 0 {
+		used = max(int64(float64(l.current)-l.limiter.Tokens()), 0)
+	}
+	return LimiterState{
+		Backend: "local",
+		Limit:   l.limit,
+		Window:  l.window,
+		Count:   used,
+	}, nil
+}
+
+// Reset refills the token bucket
+func (l *LocalRateLimiter) Reset(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.limiter = rate.NewLimiter(l.limiter.Limit(), l.limiter.Burst())
+	return nil
+}
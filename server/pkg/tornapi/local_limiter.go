@@ -0,0 +1,68 @@
+package tornapi
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// LocalRateLimiter is an in-process token bucket limiter used when Redis is unavailable.
+// Unlike RateLimiter it is not shared between processes, so each process gets the full budget.
+type LocalRateLimiter struct {
+	mu      sync.Mutex
+	limiter *rate.Limiter
+	limit   int
+	window  time.Duration
+	current int // effective limit the token bucket is currently tuned for
+
+	backoffUntil time.Time // effective limit is reduced until then (see Backoff)
+}
+
+// NewLocalRateLimiter creates a new LocalRateLimiter allowing limit requests per window.
+// A non-positive window falls back to DefaultWindow.
+func NewLocalRateLimiter(limit int, window time.Duration) *LocalRateLimiter {
+	if window <= 0 {
+		window = DefaultWindow
+	}
+
+	return &LocalRateLimiter{
+		limiter: rate.NewLimiter(rate.Inf, 1),
+		limit:   limit,
+		window:  window,
+	}
+}
+
+// SetLimit updates the rate limit dynamically
+func (l *LocalRateLimiter) SetLimit(limit int) {
+	l.mu.Lock()
+	l.limit = limit
+	l.mu.Unlock()
+}
+
+// WaitForTicket blocks until a request is allowed
+func (l *LocalRateLimiter) WaitForTicket(ctx context.Context, keyCount int) error {
+	l.mu.Lock()
+	effectiveLimit := l.limit * keyCount
+	if effectiveLimit <= 0 {
+		effectiveLimit = 50 // Safe fallback, mirrors RateLimiter
+	}
+	if time.Now().Before(l.backoffUntil) {
+		effectiveLimit = max(effectiveLimit/backoffDivisor, 1)
+	}
+	if effectiveLimit != l.current {
+		l.limiter.SetLimit(rate.Limit(float64(effectiveLimit) / l.window.Seconds()))
+		l.limiter.SetBurst(effectiveLimit)
+		l.current = effectiveLimit
+	}
+	limiter := l.limiter
+	l.mu.Unlock()
+
+	return limiter.Wait(ctx)
+}
+
+// Close is a no-op for the in-process limiter
+func (l *LocalRateLimiter) Close() error {
+	return nil
+}
@@ -0,0 +1,91 @@
+// Package tornapitest provides test doubles for the tornapi package.
+package tornapitest
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/akagifreeez/torn-market-chart/pkg/tornapi"
+)
+
+var (
+	_ tornapi.Limiter        = (*FakeLimiter)(nil)
+	_ tornapi.BackoffLimiter = (*FakeLimiter)(nil)
+)
+
+// ErrFakeLimitExceeded is returned by FakeLimiter once its ticket allowance is used up
+var ErrFakeLimitExceeded = errors.New("fake limiter: limit exceeded")
+
+// FakeLimiter is a deterministic tornapi.Limiter for tests.
+// It grants up to Allow tickets (unlimited when Allow < 0) and then fails
+// immediately with ErrFakeLimitExceeded instead of blocking, recording every call.
+type FakeLimiter struct {
+	mu        sync.Mutex
+	Allow     int
+	Limit     int
+	KeyCounts []int // keyCount of every WaitForTicket call, in order
+	Granted   int
+	Closed    bool
+	Backoffs  []time.Duration // Durations passed to Backoff, in order
+}
+
+// NewFakeLimiter creates a FakeLimiter granting allow tickets
+func NewFakeLimiter(allow int) *FakeLimiter {
+	return &FakeLimiter{Allow: allow}
+}
+
+// WaitForTicket records the call and grants a ticket while the allowance lasts
+func (f *FakeLimiter) WaitForTicket(ctx context.Context, keyCount int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.KeyCounts = append(f.KeyCounts, keyCount)
+	if f.Allow >= 0 && f.Granted >= f.Allow {
+		return ErrFakeLimitExceeded
+	}
+	f.Granted++
+	return nil
+}
+
+// SetLimit records the requested limit
+func (f *FakeLimiter) SetLimit(limit int) {
+	f.mu.Lock()
+	f.Limit = limit
+	f.mu.Unlock()
+}
+
+// Close marks the limiter as closed
+func (f *FakeLimiter) Close() error {
+	f.mu.Lock()
+	f.Closed = true
+	f.mu.Unlock()
+	return nil
+}
+
+// Calls returns how many times WaitForTicket was invoked
+func (f *FakeLimiter) Calls() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.KeyCounts)
+}
+
+// Backoff records the requested backoff
+func (f *FakeLimiter) Backoff(ctx context.Context, d time.Duration) error {
+	f.mu.Lock()
+	f.Backoffs = append(f.Backoffs, d)
+	f.mu.Unlock()
+	return nil
+}
+
+// BackoffCalls returns a copy of the durations passed to Backoff
+func (f *FakeLimiter) BackoffCalls() []time.Duration {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]time.Duration(nil), f.Backoffs...)
+}
@@ -53,8 +53,8 @@ export function ApiKeyManager() {
             });
 
             if (!res.ok) {
-                const text = await res.text();
-                throw new Error(text || 'Failed to add key');
+                const body = await res.json().catch(() => null);
+                throw new Error(body?.error?.message || 'Failed to add key');
             }
 
             setNewKey('');
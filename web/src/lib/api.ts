@@ -24,6 +24,7 @@ export interface PriceCandle {
   close: number;
   avg_price: number;
   volume: number;
+  vwap: number;
 }
 
 export interface Listing {
@@ -142,6 +143,13 @@ class ApiClient {
     return this.request<Item>(`/api/v1/items/${id}/latest`);
   }
 
+  async getItems(ids: number[]): Promise<Item[]> {
+    return this.request<Item[]>('/api/v1/items/latest', {
+      method: 'POST',
+      body: JSON.stringify({ ids }),
+    });
+  }
+
   async toggleWatchlist(id: number): Promise<{ item_id: number; is_watched: boolean }> {
     return this.request<{ item_id: number; is_watched: boolean }>(`/api/v1/items/${id}/watch`, {
       method: 'POST',